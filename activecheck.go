@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// offersActivate lists the commands that, run interactively, offer to
+// activate another version when the active one is gone. remove and
+// reset are left out, as they may be removing the one it'd offer.
+var offersActivate = map[string]bool{
+	"add":     true,
+	"clean":   true,
+	"label":   true,
+	"note":    true,
+	"rebuild": true,
+	"update":  true,
+	"upgrade": true,
+}
+
+// reportsActive lists the commands that don't warn about a dangling
+// bin at startup, because they report or replace it themselves.
+var reportsActive = map[string]bool{
+	"activate":   true,
+	"deactivate": true,
+	"doctor":     true,
+	"init":       true,
+	"prune":      true,
+	"reset":      true,
+	"status":     true,
+}
+
+// danglingActive reports the active version and the missing directory
+// groot's bin leads to when the version was removed outside groot, or
+// the link's target isn't mounted. target is empty if bin resolves,
+// and also if bin is absent, which is how deactivate leaves it.
+func (g *groot) danglingActive() (name, target string) {
+	name, err := g.activeVersion()
+	if err != nil || name == "" {
+		return "", ""
+	}
+
+	activePath := g.paths.active
+	finfo, err := os.Lstat(activePath)
+	if err != nil {
+		return name, ""
+	}
+
+	dir := activePath
+	if finfo.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(activePath)
+		if err != nil {
+			return name, ""
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(activePath), link)
+		}
+		// A symlink may point outside the versions directory.
+		if _, err := os.Stat(link); os.IsNotExist(err) {
+			return name, link
+		}
+		dir = link
+	}
+
+	// Shims, directly or as a generation, run the version's bin.
+	if _, err := readShimMarker(dir); err == nil {
+		bin := filepath.Join(g.versionDir(name), "bin")
+		if _, err := os.Stat(bin); os.IsNotExist(err) {
+			return name, bin
+		}
+	}
+	return name, ""
+}
+
+// danglingError describes bin leading to the missing directory target.
+func (g *groot) danglingError(target string) error {
+	return fmt.Errorf("%s points to %s, which no longer exists, so `go` is broken; run `groot activate version`",
+		g.tildePath(g.paths.active), g.tildePath(target))
+}
+
+// checkDanglingActive warns if the active version is gone, and for the
+// commands in offersActivate run from a terminal, offers to activate
+// the newest installed release instead.
+func (g *groot) checkDanglingActive(name string) {
+	_, target := g.danglingActive()
+	if target == "" {
+		return
+	}
+	warnln(g.danglingError(target))
+
+	if !offersActivate[name] || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return
+	}
+	newest := g.newestInstalled()
+	if newest == "" || !confirm(fmt.Sprintf("Activate %s instead?", newest)) {
+		return
+	}
+	err := g.activate(newest)
+	if err != nil {
+		warnln("Activating", newest+":", err)
+		return
+	}
+	infoln("Activated", newest)
+}
+
+// newestInstalled returns the installed version of the newest stable
+// release that's built, preferring release tags over custom names of
+// the same release. It's empty if there's none.
+func (g *groot) newestInstalled() string {
+	names, err := g.installed()
+	if err != nil {
+		return ""
+	}
+	var newest string
+	var newestV version
+	for _, name := range names {
+		inst, err := g.installInfo(name)
+		if err != nil {
+			continue
+		}
+		v, ok := parseVersion(inst.Tag)
+		if !ok || !v.stable() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(g.versionDir(name), "bin", exeName("go"))); err != nil {
+			continue
+		}
+		if newest == "" || newestV.less(v) || v == newestV && name == inst.Tag {
+			newest, newestV = name, v
+		}
+	}
+	return newest
+}
+
+// checkActiveLink is the doctor check of groot's bin.
+func checkActiveLink(g *groot) (string, error) {
+	if !g.initialized() {
+		return "groot is not initialized", nil
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return "", err
+	}
+	if _, target := g.danglingActive(); target != "" {
+		return "", g.danglingError(target)
+	}
+	if active == "" {
+		return "no version is active", nil
+	}
+	return active + " is active", nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitBackend is the set of repository operations groot performs.
+// execGit, running the git binary, is the default; goGit is used
+// where git isn't installed.
+type gitBackend interface {
+	// clone clones url into the bare repo dir, borrowing objects
+	// from the repo reference if it isn't empty.
+	clone(url, dir, reference string) error
+	// fetch updates the branches and tags of the bare repo from url.
+	fetch(url string) error
+	// fetchTag fetches only the tag from url.
+	fetchTag(url, tag string) error
+	// tags returns the names of the tags starting with "go".
+	tags() ([]string, error)
+	// revParse returns the commit rev refers to.
+	revParse(rev string) (string, error)
+	// addWorktree creates the branch at rev and checks it out in dir.
+	addWorktree(dir, branch, rev string) error
+	// resetWorktree checks out rev in the existing worktree dir.
+	resetWorktree(dir, rev string) error
+	// removeWorktree forgets the deleted worktree dir and its branch.
+	removeWorktree(dir, branch string) error
+	// head returns the commit checked out in the worktree dir.
+	head(dir string) (string, error)
+	// name describes the backend in messages.
+	name() string
+}
+
+// Backend names accepted by GROOT_GIT_BACKEND.
+const (
+	backendExec  = "exec"
+	backendGoGit = "go-git"
+)
+
+// goGitHead is the file recording the commit checked out by the
+// go-git backend, which writes plain directories rather than
+// git worktrees.
+const goGitHead = ".groot-head"
+
+// backend returns the git backend, chosen once per command.
+func (g *groot) backend() gitBackend {
+	if g.gitBackend != nil {
+		return g.gitBackend
+	}
+
+	choice := os.Getenv("GROOT_GIT_BACKEND")
+	switch choice {
+	case "":
+		if _, err := exec.LookPath("git"); err != nil {
+			warnln("git not found, using the built-in go-git backend")
+			choice = backendGoGit
+		} else {
+			choice = backendExec
+		}
+	case backendExec, backendGoGit:
+	default:
+		warnf("Unknown GROOT_GIT_BACKEND %q, using %s", choice, backendExec)
+		choice = backendExec
+	}
+
+	g.gitBackend = &execGit{g}
+	if choice == backendGoGit {
+		b, err := newGoGit(g)
+		if err != nil {
+			warnf("%v; falling back to %s", err, backendExec)
+		} else {
+			g.gitBackend = b
+		}
+	}
+	return g.gitBackend
+}
+
+// requireExecGit returns an error if the git binary isn't the
+// backend in use, for features that rely on git itself.
+func (g *groot) requireExecGit(feature string) error {
+	if _, ok := g.backend().(*execGit); !ok {
+		return fmt.Errorf("%s requires the git binary, not the %s backend", feature, g.backend().name())
+	}
+	return nil
+}
+
+// execGit runs the git binary.
+type execGit struct {
+	g *groot
+}
+
+func (b *execGit) name() string { return backendExec }
+
+func (b *execGit) clone(url, dir, reference string) error {
+	args := []string{"clone", "--bare", "--progress"}
+	if reference != "" {
+		args = append(args, "--reference", reference)
+	}
+	err := b.g.networkGit(append(args, url, dir)...)
+	if err != nil {
+		return err
+	}
+
+	// https://stackoverflow.com/questions/39882988/git-bare-repo-cannot-have-a-worktree-for-master-branch-why
+	return b.g.git("update-ref", "--no-deref", "HEAD", "HEAD^{commit}")
+}
+
+func (b *execGit) fetch(url string) error {
+	// Without --prune, so groot's own branches are left alone.
+	return b.g.networkGit(b.g.gitArgs("fetch", "--progress", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")...)
+}
+
+func (b *execGit) fetchTag(url, tag string) error {
+	ref := "refs/tags/" + tag
+	return b.g.networkGit(b.g.gitArgs("fetch", "--progress", "--no-tags", url, "+"+ref+":"+ref)...)
+}
+
+func (b *execGit) tags() ([]string, error) {
+	out, err := b.g.gitOutput("tag", "--list", "go*")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+func (b *execGit) revParse(rev string) (string, error) {
+	out, err := b.g.gitOutput("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", rev, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (b *execGit) addWorktree(dir, branch, rev string) error {
+	err := b.g.git("branch", branch, rev)
+	if err != nil {
+		return err
+	}
+	err = b.g.git("worktree", "add", dir, branch)
+	if err != nil {
+		return err
+	}
+	return b.g.lockWorktree(dir)
+}
+
+func (b *execGit) resetWorktree(dir, rev string) error {
+	return b.g.runGit("-C", dir, "reset", "--hard", rev)
+}
+
+func (b *execGit) removeWorktree(dir, branch string) error {
+	err := b.g.unlockWorktree(dir)
+	if err != nil {
+		return err
+	}
+	err = b.g.git("worktree", "prune")
+	if err != nil {
+		return err
+	}
+	// The branch is missing if a failed build never created it.
+	if _, err := b.g.gitOutput("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		return nil
+	}
+	return b.g.git("branch", "-D", branch)
+}
+
+func (b *execGit) head(dir string) (string, error) {
+	return worktreeHead(dir)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBackendCommands runs add, activate, list, and remove with each
+// git backend. The go-git case is skipped unless built with -modfile=gogit.mod -tags gogit.
+func TestBackendCommands(t *testing.T) {
+	for _, backend := range []string{backendExec, backendGoGit} {
+		t.Run(backend, func(t *testing.T) {
+			if _, err := newGoGit(&groot{}); backend == backendGoGit && err != nil {
+				t.Skip(err)
+			}
+			home := newTestHome(t)
+			t.Setenv("GROOT_GIT_BACKEND", backend)
+			mustRunGroot(t, home, "add", "go1.21.0")
+			mustRunGroot(t, home, "add", "go1.22.0")
+			dir := filepath.Join(home, "go1.21.0")
+			marker := ".git"
+			if backend == backendGoGit {
+				marker = goGitHead
+			}
+			if _, err := os.Stat(filepath.Join(dir, marker)); err != nil {
+				t.Errorf("%s checkout: %v", backend, err)
+			}
+
+			mustRunGroot(t, home, "activate", "go1.21.0")
+			if got := strings.TrimSpace(mustRunGroot(t, home, "current")); got != "go1.21.0" {
+				t.Errorf("current = %q after activate, want go1.21.0", got)
+			}
+			if target, err := os.Readlink(filepath.Join(home, "bin")); err != nil || target != filepath.Join(dir, "bin") {
+				t.Errorf("bin links to %q (%v), want %s", target, err, filepath.Join(dir, "bin"))
+			}
+
+			list := mustRunGroot(t, home, "list")
+			for _, name := range []string{"* go1.21.0", "  go1.22.0"} {
+				if !strings.Contains(list, name) {
+					t.Errorf("list doesn't show %q:\n%s", name, list)
+				}
+			}
+
+			mustRunGroot(t, home, "remove", "--yes", "go1.22.0")
+			if _, err := os.Stat(filepath.Join(home, "go1.22.0")); !os.IsNotExist(err) {
+				t.Errorf("go1.22.0 is still there after remove: %v", err)
+			}
+			if list := mustRunGroot(t, home, "list"); strings.Contains(list, "go1.22.0") {
+				t.Errorf("list still shows go1.22.0 after remove:\n%s", list)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// benchKey identifies one measurement of a benchmark.
+type benchKey struct {
+	name, unit string
+}
+
+// benchResults are the benchmark measurements parsed from
+// `go test -bench` output.
+type benchResults struct {
+	names  []string // in order of first appearance
+	units  []string
+	values map[benchKey][]float64
+}
+
+// parseBenchmarks parses the benchmark lines of out, such as
+//
+//	BenchmarkDecode-8   	  200000	      6512 ns/op	    1024 B/op	       3 allocs/op
+//
+// Repeated runs, from -count, are collected as samples.
+func parseBenchmarks(out []byte) benchResults {
+	res := benchResults{values: make(map[benchKey][]float64)}
+	seenName := make(map[string]bool)
+	seenUnit := make(map[string]bool)
+
+	for _, line := range splitLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		name := strings.TrimPrefix(fields[0], "Benchmark")
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			unit := fields[i+1]
+
+			if !seenName[name] {
+				seenName[name] = true
+				res.names = append(res.names, name)
+			}
+			if !seenUnit[unit] {
+				seenUnit[unit] = true
+				res.units = append(res.units, unit)
+			}
+			key := benchKey{name, unit}
+			res.values[key] = append(res.values[key], v)
+		}
+	}
+	return res
+}
+
+// unitLabel returns the column heading used by benchstat for unit.
+func unitLabel(unit string) string {
+	switch unit {
+	case "ns/op":
+		return "time/op"
+	case "B/op":
+		return "alloc/op"
+	case "MB/s":
+		return "speed"
+	}
+	return unit
+}
+
+// removeOutliers returns the samples in x within 1.5 interquartile
+// ranges of the quartiles, as benchstat does.
+func removeOutliers(x []float64) []float64 {
+	if len(x) < 4 {
+		return x
+	}
+	s := append([]float64(nil), x...)
+	sort.Float64s(s)
+
+	q1, q3 := quantile(s, 0.25), quantile(s, 0.75)
+	lo, hi := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	var out []float64
+	for _, v := range s {
+		if v >= lo && v <= hi {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// quantile returns the q quantile of the sorted samples s,
+// interpolating between samples.
+func quantile(s []float64, q float64) float64 {
+	pos := q * float64(len(s)-1)
+	i := int(pos)
+	if i+1 >= len(s) {
+		return s[len(s)-1]
+	}
+	return s[i] + (pos-float64(i))*(s[i+1]-s[i])
+}
+
+func mean(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+// formatSample formats the mean of x with its variation, the
+// largest deviation from the mean as a percentage of it.
+func formatSample(x []float64, unit string) string {
+	x = removeOutliers(x)
+	if len(x) == 0 {
+		return "-"
+	}
+
+	m := mean(x)
+	var dev float64
+	for _, v := range x {
+		dev = math.Max(dev, math.Abs(v-m))
+	}
+
+	s := formatBenchValue(m, unit)
+	if m != 0 && len(x) > 1 {
+		s += fmt.Sprintf(" ± %2.0f%%", dev/m*100)
+	}
+	return s
+}
+
+// formatBenchValue formats v, scaling it for the common units.
+func formatBenchValue(v float64, unit string) string {
+	type scale struct {
+		factor float64
+		suffix string
+	}
+	var scales []scale
+	switch unit {
+	case "ns/op":
+		scales = []scale{{1e9, "s"}, {1e6, "ms"}, {1e3, "µs"}, {1, "ns"}}
+	case "B/op":
+		scales = []scale{{1 << 30, "GB"}, {1 << 20, "MB"}, {1 << 10, "kB"}, {1, "B"}}
+	case "MB/s":
+		scales = []scale{{1e3, "GB/s"}, {1, "MB/s"}}
+	default:
+		return threeDigits(v)
+	}
+
+	for _, sc := range scales {
+		if math.Abs(v) >= sc.factor || sc.factor == 1 {
+			return threeDigits(v/sc.factor) + sc.suffix
+		}
+	}
+	return threeDigits(v)
+}
+
+// threeDigits formats v with three significant digits, keeping
+// trailing zeros so columns line up, e.g. 1.00 and 12.5.
+func threeDigits(v float64) string {
+	if v == 0 || math.Abs(v) >= 100 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	prec := 2 - int(math.Floor(math.Log10(math.Abs(v))))
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// significance is the p-value below which a delta is reported.
+const significance = 0.05
+
+// formatDelta formats the change from x to y, or "~" if it
+// isn't statistically significant.
+func formatDelta(x, y []float64) string {
+	x, y = removeOutliers(x), removeOutliers(y)
+	if len(x) == 0 || len(y) == 0 {
+		return ""
+	}
+
+	p := mannWhitneyU(x, y)
+	n := fmt.Sprintf("(p=%.3f n=%d+%d)", p, len(x), len(y))
+	mx, my := mean(x), mean(y)
+	if p >= significance || mx == 0 {
+		return "~     " + n
+	}
+	return fmt.Sprintf("%+.2f%%  %s", (my-mx)/mx*100, n)
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U
+// test that x and y come from the same distribution. The exact
+// distribution of U is used for small samples without ties, the
+// normal approximation otherwise.
+func mannWhitneyU(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+
+	// Rank the pooled samples, averaging the ranks of ties.
+	type sample struct {
+		v     float64
+		fromX bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	var rankX, tieCorrection float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // average of ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankX += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieCorrection += t*t*t - t
+		}
+		i = j
+	}
+	u := rankX - float64(n1*(n1+1))/2
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return exactU(n1, n2, u)
+	}
+
+	n := float64(n1 + n2)
+	mu := float64(n1*n2) / 2
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - tieCorrection/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	// Continuity correction towards the mean.
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// exactU returns the two-sided p-value of u under the exact
+// distribution of the Mann-Whitney U statistic without ties.
+func exactU(n1, n2 int, u float64) float64 {
+	// counts[m][k] is the number of orderings of m samples from x
+	// and j samples from y with U = k, built up one j at a time.
+	maxU := n1 * n2
+	counts := make([][]float64, n1+1)
+	for m := range counts {
+		counts[m] = make([]float64, maxU+1)
+	}
+	for m := range counts {
+		counts[m][0] = 1 // only x samples
+	}
+	for j := 1; j <= n2; j++ {
+		// The largest sample is either from y, leaving U unchanged,
+		// or from x, exceeding all j samples of y.
+		next := make([][]float64, n1+1)
+		for m := 0; m <= n1; m++ {
+			next[m] = make([]float64, maxU+1)
+			for k := 0; k <= maxU; k++ {
+				next[m][k] = counts[m][k]
+				if m > 0 && k >= j {
+					next[m][k] += next[m-1][k-j]
+				}
+			}
+		}
+		counts = next
+	}
+
+	var total, below, above float64
+	for k, c := range counts[n1] {
+		total += c
+		if float64(k) <= u {
+			below += c
+		}
+		if float64(k) >= u {
+			above += c
+		}
+	}
+	return math.Min(1, 2*math.Min(below, above)/total)
+}
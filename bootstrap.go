@@ -0,0 +1,433 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// bootstrapToolchain is a downloaded bootstrap toolchain: the one
+// downloaded by init into .binary, or one added by bootstrap upgrade.
+type bootstrapToolchain struct {
+	version string // e.g. go1.21.3
+	dir     string
+}
+
+// bootstraps returns the downloaded bootstrap toolchains.
+func (g *groot) bootstraps() ([]bootstrapToolchain, error) {
+	var toolchains []bootstrapToolchain
+	if _, err := os.Stat(g.paths.binary); err == nil {
+		v, err := goVersion(g.paths.binary)
+		if err != nil {
+			v = "unknown"
+		}
+		toolchains = append(toolchains, bootstrapToolchain{v, g.paths.binary})
+	}
+
+	finfos, err := ioutil.ReadDir(g.paths.bootstraps)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, finfo := range finfos {
+		if finfo.IsDir() {
+			toolchains = append(toolchains, bootstrapToolchain{finfo.Name(), filepath.Join(g.paths.bootstraps, finfo.Name())})
+		}
+	}
+	return toolchains, nil
+}
+
+// defaultBootstrapDir returns the GOROOT_BOOTSTRAP of builds that
+// don't choose one.
+func (g *groot) defaultBootstrapDir() string {
+	s, err := g.loadState()
+	if err != nil || s.DefaultBootstrap == "" {
+		return g.paths.binary
+	}
+	dir := filepath.Join(g.paths.bootstraps, s.DefaultBootstrap)
+	if _, err := os.Stat(dir); err != nil {
+		return g.paths.binary
+	}
+	return dir
+}
+
+// chooseBootstrap returns the installed version to bootstrap the
+// build of name with, or "" for the downloaded default. The recorded
+// bootstrap of opts is kept unless it's gone or is name itself; the
+// preferred bootstrap is used unless it's gone, is name itself, or is
+// too old for the target. Either is passed over with a warning.
+func (g *groot) chooseBootstrap(name string, opts buildOptions) string {
+	usable := func(b string) bool {
+		_, err := os.Stat(g.versionDir(b))
+		return b != name && err == nil
+	}
+
+	if b := opts.bootstrap; b != "" {
+		if usable(b) {
+			return b
+		}
+		warnf("Bootstrap %s of %s is unavailable, choosing another", b, name)
+	}
+
+	s, err := g.loadState()
+	if err != nil || s.PreferredBootstrap == "" {
+		return ""
+	}
+	pref := s.PreferredBootstrap
+	if !usable(pref) {
+		warnf("Preferred bootstrap %s is being rebuilt or was removed, using the downloaded bootstrap", pref)
+		return ""
+	}
+	v, err := goVersion(g.versionDir(pref))
+	if err != nil {
+		warnf("Preferred bootstrap %s doesn't run (%v), using the downloaded bootstrap", pref, err)
+		return ""
+	}
+	if min, tooOld := bootstrapTooOld(v, opts.tag); tooOld {
+		warnf("Preferred bootstrap %s is too old to build %s, which requires %s; using the downloaded bootstrap", pref, opts.tag, min)
+		return ""
+	}
+	return pref
+}
+
+// staleBootstrapped returns the source installs built by a bootstrap
+// older than the default, the preferred bootstrap if one is set.
+func (g *groot) staleBootstrapped() ([]string, error) {
+	s, err := g.loadState()
+	if err != nil {
+		return nil, err
+	}
+	dir := g.defaultBootstrapDir()
+	if pref := s.PreferredBootstrap; pref != "" && g.exists(pref) {
+		dir = g.versionDir(pref)
+	}
+
+	current, err := goVersion(dir)
+	if err != nil {
+		return nil, fmt.Errorf("determining the default bootstrap version: %v", err)
+	}
+	cv, ok := parseVersion(current)
+	if !ok {
+		return nil, fmt.Errorf("default bootstrap has unrecognized version %q", current)
+	}
+
+	names, err := g.installed()
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, name := range names {
+		inst, err := g.installInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		if inst.Kind != kindSource || inst.Detached || isSnapshot(name) || name == s.PreferredBootstrap {
+			continue
+		}
+		v, ok := parseVersion(inst.BootstrapVersion)
+		if !ok {
+			infof("Skipping %s, its bootstrap wasn't recorded\n", name)
+			continue
+		}
+		if v.less(cv) {
+			stale = append(stale, name)
+		}
+	}
+	return stale, nil
+}
+
+// bootstrapDescription describes the bootstrap that built inst.
+func (inst installState) bootstrapDescription() string {
+	v := inst.BootstrapVersion
+	if v == "" {
+		v = "unknown"
+	}
+	if inst.Bootstrap != "" {
+		return v + " (installed version " + inst.Bootstrap + ")"
+	}
+	return v
+}
+
+// bootstrapCommands are the subcommands of bootstrap.
+var bootstrapCommands = []command{
+	{name: "bootstrap list", setup: noFlags(bootstrapList)},
+	{name: "bootstrap upgrade", usage: []string{"[version]"}, setup: noFlags(bootstrapUpgrade)},
+	{name: "bootstrap rm", usage: []string{"[--force] version"}, minArgs: 1, setup: bootstrapRemove},
+	{name: "bootstrap use", usage: []string{"version", "--clear"}, setup: bootstrapUse},
+	{name: "bootstrap prune", setup: noFlags(bootstrapPrune)},
+}
+
+func bootstrap(g *groot, args []string) error {
+	return g.runSubcommand(bootstrapCommands, args)
+}
+
+func bootstrapList(g *groot, _ []string) error {
+	toolchains, err := g.bootstraps()
+	if err != nil {
+		return err
+	}
+	users, err := g.bootstrapUsers()
+	if err != nil {
+		return err
+	}
+	s, err := g.loadState()
+	if err != nil {
+		return err
+	}
+	def := g.defaultBootstrapDir()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if pref := s.PreferredBootstrap; pref != "" {
+		// Preferred over the downloaded ones, unless too old
+		// for a particular target.
+		fmt.Fprintf(w, "* %s\tinstalled\t%d builds\t%s\n", pref, len(users[pref]), g.versionDir(pref))
+		def = ""
+	}
+	for _, tc := range toolchains {
+		marker := " "
+		if tc.dir == def {
+			marker = "*"
+		}
+		size, err := dirSize(tc.dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%d builds\t%s\n", marker, tc.version, formatSize(size), len(users[tc.version]), tc.dir)
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// bootstrapUsers returns the source installs built by each bootstrap,
+// keyed by installed version name or downloaded bootstrap version.
+func (g *groot) bootstrapUsers() (map[string][]string, error) {
+	s, err := g.loadState()
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string][]string)
+	for name, inst := range s.Installs {
+		switch {
+		case inst.Kind != kindSource:
+		case inst.Bootstrap != "":
+			users[inst.Bootstrap] = append(users[inst.Bootstrap], name)
+		case inst.BootstrapVersion != "":
+			users[inst.BootstrapVersion] = append(users[inst.BootstrapVersion], name)
+		}
+	}
+	return users, nil
+}
+
+func bootstrapUpgrade(g *groot, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	var v string
+	if len(args) == 1 {
+		v = normalizeTag(args[0])
+	} else {
+		var err error
+		v, err = g.latest(true, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := g.downloadBootstrap(v)
+	if err != nil {
+		return err
+	}
+
+	err = g.updateState(func(s *state) error {
+		s.DefaultBootstrap = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(v, "is the default bootstrap; use `groot rebuild --stale-bootstrap` to rebuild versions built with older ones")
+	return nil
+}
+
+// downloadBootstrap downloads the binary release v into the bootstraps
+// directory, unless it's already there.
+func (g *groot) downloadBootstrap(v string) error {
+	dir := filepath.Join(g.paths.bootstraps, v)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	goos, goarch := g.platform()
+	filename := archiveName(v, goos, goarch)
+	hash, err := g.lookupChecksum(filename)
+	if err != nil {
+		return fmt.Errorf("no binary release of %s for %s/%s: %v", v, goos, goarch, err)
+	}
+
+	infoln("Downloading bootstrap", v)
+	err = g.downloadAndExtract(downloadURL+filename, hash, dir, nil)
+	if err == nil {
+		_, err = goVersion(dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+func bootstrapRemove(fs *flag.FlagSet, g *groot) func(args []string) error {
+	force := fs.Bool("force", false, "remove the bootstrap even if installed versions were built with it")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		v := normalizeTag(args[0])
+
+		toolchains, err := g.bootstraps()
+		if err != nil {
+			return err
+		}
+		var dir string
+		for _, tc := range toolchains {
+			if tc.version == v {
+				dir = tc.dir
+			}
+		}
+		if dir == "" {
+			return fmt.Errorf("no downloaded bootstrap %s; see `groot bootstrap list`", v)
+		}
+		if dir == g.defaultBootstrapDir() {
+			return fmt.Errorf("%s is the default bootstrap; use `groot bootstrap upgrade` to replace it first", v)
+		}
+
+		users, err := g.bootstrapUsers()
+		if err != nil {
+			return err
+		}
+		if names := users[v]; len(names) > 0 && !*force {
+			return fmt.Errorf("%s built %s; use --force to remove it anyway", v, strings.Join(names, ", "))
+		}
+
+		infoln("Removing", dir)
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func bootstrapUse(fs *flag.FlagSet, g *groot) func(args []string) error {
+	clear := fs.Bool("clear", false, "go back to using the downloaded bootstrap")
+	return func(args []string) error {
+		var err error
+		if *clear == (len(args) == 1) || len(args) > 1 {
+			return errUsage
+		}
+
+		var name string
+		if !*clear {
+			name = args[0]
+			inst, err := g.installInfo(name)
+			if err == nil && !g.exists(name) {
+				err = fmt.Errorf("%s isn't installed", name)
+			}
+			if err != nil {
+				return err
+			}
+			v, err := goVersion(g.versionDir(name))
+			if err != nil {
+				return fmt.Errorf("%s can't be used as a bootstrap: %v", name, err)
+			}
+			if _, ok := parseVersion(v); !ok || inst.Kind == kindSource && inst.Tag == tipTag {
+				return fmt.Errorf("%s reports %s; only releases can be used as the bootstrap", name, v)
+			}
+
+			// Builds it's too old for still use the downloaded bootstrap.
+			if latest, err := g.latest(false, true); err == nil {
+				if min, tooOld := bootstrapTooOld(v, latest); tooOld {
+					warnf("%s (%s) is too old to build %s, which requires %s; such builds will use the downloaded bootstrap", name, v, latest, min)
+				}
+			}
+		}
+
+		err = g.updateState(func(s *state) error {
+			s.PreferredBootstrap = name
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("Builds will use the downloaded bootstrap")
+		} else {
+			fmt.Println("Builds will use", name, "as the bootstrap")
+		}
+		return nil
+	}
+}
+
+// bootstrapPrune deletes the downloaded bootstraps once an installed
+// version is used instead.
+func bootstrapPrune(g *groot, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	s, err := g.loadState()
+	if err != nil {
+		return err
+	}
+	if s.PreferredBootstrap == "" || !g.exists(s.PreferredBootstrap) {
+		return fmt.Errorf("the downloaded bootstraps are still needed; choose an installed version with `groot bootstrap use` first")
+	}
+
+	toolchains, err := g.bootstraps()
+	if err != nil {
+		return err
+	}
+	var freed int64
+	for _, tc := range toolchains {
+		size, _ := dirSize(tc.dir)
+		infoln("Removing", tc.dir)
+		err = os.RemoveAll(tc.dir)
+		if err != nil {
+			return err
+		}
+		freed += size
+	}
+	err = os.RemoveAll(g.paths.bootstraps)
+	if err != nil {
+		return err
+	}
+
+	err = g.updateState(func(s *state) error {
+		s.DefaultBootstrap = ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Freed", formatSize(freed))
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, finfo os.FileInfo, err error) error {
+		if err == nil && finfo.Mode().IsRegular() {
+			size += finfo.Size()
+		}
+		return err
+	})
+	return size, err
+}
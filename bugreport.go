@@ -0,0 +1,242 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// bugReportFile is a file of a bugreport bundle.
+type bugReportFile struct {
+	name string
+	data []byte
+}
+
+func bugreport(fs *flag.FlagSet, g *groot) func(args []string) error {
+	noDoctor := fs.Bool("no-doctor", false, "leave out doctor's checks, which connect to the download server")
+	return func(args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+
+		files := g.bugReportFiles(!*noDoctor)
+		path, err := writeBugReport(files)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Wrote", path)
+		fmt.Println("Nothing was uploaded; review it before attaching it to an issue.")
+		return nil
+	}
+}
+
+// bugReportFiles gathers what's useful for reconstructing a problem.
+// What can't be read is noted in its place rather than failing the
+// report, since a broken setup is the usual reason for one.
+func (g *groot) bugReportFiles(withDoctor bool) []bugReportFile {
+	files := []bugReportFile{{"info.txt", g.bugReportInfo()}}
+
+	for _, f := range []struct{ name, path string }{
+		{"config.json", g.paths.config},
+		{"state.json", g.statePath()},
+	} {
+		data, err := ioutil.ReadFile(f.path)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			data = []byte(err.Error() + "\n")
+		default:
+			data = redactJSON(data)
+		}
+		files = append(files, bugReportFile{f.name, data})
+	}
+
+	worktrees := "not available with the " + g.backend().name() + " backend\n"
+	if err := g.requireExecGit("worktree list"); err == nil {
+		out, err := g.gitOutput("worktree", "list", "--porcelain")
+		if err != nil {
+			out += err.Error() + "\n"
+		}
+		worktrees = redactString(out)
+	}
+	files = append(files, bugReportFile{"worktrees.txt", []byte(worktrees)})
+
+	if name, data := g.lastBuildLog(); data != nil {
+		files = append(files, bugReportFile{"build-" + name + ".log", data})
+	}
+
+	if withDoctor {
+		var buf bytes.Buffer
+		g.runDoctor(&buf)
+		files = append(files, bugReportFile{"doctor.txt", buf.Bytes()})
+	}
+
+	var ops bytes.Buffer
+	for _, line := range g.readOpLog() {
+		ops.WriteString(redactString(string(line)))
+		ops.WriteByte('\n')
+	}
+	files = append(files, bugReportFile{opLogFile, ops.Bytes()})
+	return files
+}
+
+// bugReportInfo describes groot's build and the system it runs on.
+func (g *groot) bugReportInfo() []byte {
+	var buf bytes.Buffer
+	version := "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		version = bi.Main.Version
+		if version == "" {
+			version = "(devel)"
+		}
+		for _, s := range bi.Settings {
+			if strings.HasPrefix(s.Key, "vcs.") {
+				version += " " + s.Key + "=" + s.Value
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "groot:\t%s\n", version)
+	fmt.Fprintf(&buf, "built with:\t%s\n", runtime.Version())
+	fmt.Fprintf(&buf, "os/arch:\t%s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&buf, "backend:\t%s\n", g.backend().name())
+
+	active, err := g.activeVersion()
+	if err != nil {
+		active = err.Error()
+	}
+	fmt.Fprintf(&buf, "active:\t%s\n", active)
+	fmt.Fprintf(&buf, "base:\t%s\n", g.paths.base)
+	fmt.Fprintf(&buf, "git:\t%s\n", g.paths.git)
+	fmt.Fprintf(&buf, "state:\t%s\n", g.paths.state)
+	fmt.Fprintf(&buf, "cache:\t%s\n", g.paths.cache)
+	return buf.Bytes()
+}
+
+// lastBuildLog returns the most recently written build log of the
+// installed versions, and the version it's of. The data is nil if
+// there's none.
+func (g *groot) lastBuildLog() (string, []byte) {
+	names, err := g.installed()
+	if err != nil {
+		return "", nil
+	}
+	var last string
+	var lastMod time.Time
+	for _, name := range names {
+		finfo, err := os.Stat(g.buildLogPath(name))
+		if err == nil && finfo.ModTime().After(lastMod) {
+			last, lastMod = name, finfo.ModTime()
+		}
+	}
+	if last == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(g.buildLogPath(last))
+	if err != nil {
+		return "", nil
+	}
+	return last, data
+}
+
+// credentialKeyRE matches the JSON keys whose values are redacted
+// from bug reports.
+var credentialKeyRE = regexp.MustCompile(`(?i)token|passw|secret|credential|api_?key`)
+
+// userinfoRE matches the user and password of a URL.
+var userinfoRE = regexp.MustCompile(`(://)[^/\s@"]+@`)
+
+// redactString removes credentials embedded in the URLs in s, such as
+// the repo URL of a private mirror.
+func redactString(s string) string {
+	return userinfoRE.ReplaceAllString(s, "${1}REDACTED@")
+}
+
+// redactJSON returns the JSON document data with the values of keys
+// that look like credentials, and credentials in URLs, redacted.
+// Data that isn't JSON is only stripped of URL credentials.
+func redactJSON(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(redactString(string(data)))
+	}
+	out, err := json.MarshalIndent(redactValue("", v), "", "\t")
+	if err != nil {
+		return []byte(redactString(string(data)))
+	}
+	return append(out, '\n')
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = redactValue(k, e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactValue(key, e)
+		}
+	case string:
+		if v != "" && credentialKeyRE.MatchString(key) {
+			return "REDACTED"
+		}
+		return redactString(v)
+	}
+	return v
+}
+
+// writeBugReport writes files to a new tar.gz in the current directory
+// and returns its path.
+func writeBugReport(files []bugReportFile) (string, error) {
+	now := time.Now()
+	dir := "groot-bugreport-" + now.Format("20060102-150405")
+	path := dir + ".tar.gz"
+	// Only readable by the user, as it describes their setup.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, file := range files {
+		err = tw.WriteHeader(&tar.Header{
+			Name:    dir + "/" + file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+		})
+		if err == nil {
+			_, err = tw.Write(file.data)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := gw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// buildOptions describes how a version is checked out and built.
+type buildOptions struct {
+	tag     string // git tag to check out
+	as      string // name chosen with --name, replacing the default
+	goamd64 string // GOAMD64 microarchitecture level
+	goarm   string // GOARM version
+
+	experiment string // comma separated GOEXPERIMENT names
+
+	bootstrap string   // installed version used as GOROOT_BOOTSTRAP
+	extraEnv  []string // KEY=VALUE pairs from --env-file and --env
+
+	test        bool          // run the std tests after building
+	testTimeout time.Duration // limit on the test run, 0 for none
+
+	minimal        bool // sparse checkout without the files only tests need
+	noBuild        bool // only check out the worktree, leaving make.bash to the user
+	discardObjects bool // remove intermediate build objects after building
+	detach         bool // convert the worktree to a plain directory after building
+
+	logFile string // file make.bash output is copied to
+	quiet   bool   // only write make.bash output to the log file
+
+	stallTimeout time.Duration // abort make.bash after this long without output, 0 to only warn
+
+	gorootFinal string // GOROOT_FINAL, where the toolchain will eventually be installed
+}
+
+// name returns the directory and branch name of the build.
+// Variants are encoded so they can coexist with a plain
+// build of the same tag.
+func (o buildOptions) name() string {
+	if o.as != "" {
+		return o.as
+	}
+	name := o.tag
+	if o.goamd64 != "" {
+		name += "-goamd64" + o.goamd64
+	}
+	if o.goarm != "" {
+		name += "-goarm" + strings.Replace(o.goarm, ",", "", -1)
+	}
+	if o.experiment != "" {
+		name += "-exp-" + strings.Replace(o.experiment, ",", "-", -1)
+	}
+	return name
+}
+
+// env returns the environment variables make.bash is run with,
+// in addition to the inherited environment.
+func (o buildOptions) env() []string {
+	var env []string
+	if o.goamd64 != "" {
+		env = append(env, "GOAMD64="+o.goamd64)
+	}
+	if o.goarm != "" {
+		env = append(env, "GOARM="+o.goarm)
+	}
+	if o.experiment != "" {
+		env = append(env, "GOEXPERIMENT="+o.experiment)
+	}
+	return append(env, o.extraEnv...)
+}
+
+// envFlag is a repeatable KEY=VALUE flag.
+type envFlag struct {
+	env *[]string
+}
+
+func (f envFlag) String() string {
+	if f.env == nil {
+		return ""
+	}
+	return strings.Join(*f.env, " ")
+}
+
+func (f envFlag) Set(s string) error {
+	err := checkEnvVar(s)
+	if err != nil {
+		return err
+	}
+	*f.env = append(*f.env, s)
+	return nil
+}
+
+// envFileFlag reads KEY=VALUE lines from a file. Blank lines
+// and lines starting with # are ignored.
+type envFileFlag struct {
+	env *[]string
+}
+
+func (f envFileFlag) String() string { return "" }
+
+func (f envFileFlag) Set(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := checkEnvVar(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		*f.env = append(*f.env, line)
+	}
+	return nil
+}
+
+// checkEnvVar validates the syntax of a KEY=VALUE pair.
+func checkEnvVar(kv string) error {
+	i := strings.IndexByte(kv, '=')
+	if i < 0 {
+		return fmt.Errorf("%q is not in KEY=VALUE form", kv)
+	}
+
+	key := kv[:i]
+	for j, r := range key {
+		if r != '_' && !(r >= 'A' && r <= 'Z') && !(r >= 'a' && r <= 'z') && !(j > 0 && r >= '0' && r <= '9') {
+			return fmt.Errorf("%q is not a valid environment variable name", key)
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("%q is missing a variable name", kv)
+	}
+	return nil
+}
+
+// validate checks that the options are supported by the tag being built.
+// Tags that aren't releases, such as branches, aren't checked.
+func (o buildOptions) validate() error {
+	v, isRelease := parseVersion(o.tag)
+
+	if o.goamd64 != "" {
+		switch o.goamd64 {
+		case "v1", "v2", "v3", "v4":
+		default:
+			return fmt.Errorf("invalid GOAMD64 %q: must be one of v1, v2, v3, v4", o.goamd64)
+		}
+		if isRelease && v.less(version{major: 1, minor: 18, pre: "beta", preNum: 1}) {
+			return fmt.Errorf("GOAMD64 requires go1.18 or later, %s does not support it", o.tag)
+		}
+	}
+
+	if o.goarm != "" {
+		arm := o.goarm
+		if i := strings.IndexByte(arm, ','); i >= 0 {
+			// Float ABI suffixes were added in go1.22.
+			switch arm[i+1:] {
+			case "softfloat", "hardfloat":
+			default:
+				return fmt.Errorf("invalid GOARM %q: suffix must be softfloat or hardfloat", o.goarm)
+			}
+			if isRelease && v.less(version{major: 1, minor: 22, pre: "beta", preNum: 1}) {
+				return fmt.Errorf("GOARM float suffixes require go1.22 or later, %s does not support them", o.tag)
+			}
+			arm = arm[:i]
+		}
+		switch arm {
+		case "5", "6", "7":
+		default:
+			return fmt.Errorf("invalid GOARM %q: must be one of 5, 6, 7", o.goarm)
+		}
+	}
+
+	if o.gorootFinal != "" {
+		if !filepath.IsAbs(o.gorootFinal) {
+			return fmt.Errorf("invalid GOROOT_FINAL %q: must be an absolute path", o.gorootFinal)
+		}
+		if isRelease && !v.less(version{major: 1, minor: 22, pre: "beta", preNum: 1}) {
+			return fmt.Errorf("GOROOT_FINAL was removed in go1.22, %s does not support it", o.tag)
+		}
+	}
+
+	for _, exp := range strings.Split(o.experiment, ",") {
+		if o.experiment != "" && !isExperimentName(exp) {
+			return fmt.Errorf("invalid GOEXPERIMENT %q: names are lowercase letters and digits, optionally prefixed with no", exp)
+		}
+	}
+
+	return nil
+}
+
+func isExperimentName(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// checkExperiments verifies the experiments of opts are known to the
+// tag being built, according to its internal/goexperiment package.
+// Versions without one, and trees that can't be read without the git
+// binary, aren't checked.
+func (g *groot) checkExperiments(opts buildOptions) error {
+	if _, ok := g.backend().(*execGit); !ok || opts.experiment == "" {
+		return nil
+	}
+	src, err := g.gitOutput("show", opts.ref()+":src/internal/goexperiment/flags.go")
+	if err != nil {
+		warnf("Can't check GOEXPERIMENT against %s, it has no internal/goexperiment package", opts.tag)
+		return nil
+	}
+
+	known := experimentNames(src)
+	for _, exp := range strings.Split(opts.experiment, ",") {
+		if !known[strings.TrimPrefix(exp, "no")] && !known[exp] {
+			names := make([]string, 0, len(known))
+			for name := range known {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("%s has no experiment %q; known experiments are %s", opts.tag, exp, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// experimentNames returns the experiments declared as fields of the
+// Flags struct in the source of internal/goexperiment/flags.go.
+func experimentNames(src string) map[string]bool {
+	names := make(map[string]bool)
+	inFlags := false
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "type Flags struct"):
+			inFlags = true
+		case inFlags && line == "}":
+			return names
+		case inFlags:
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[1] == "bool" {
+				names[strings.ToLower(fields[0])] = true
+			}
+		}
+	}
+	return names
+}
+
+// tipTag is the name used for builds of the master branch.
+const tipTag = "tip"
+
+// ref returns the git revision of the tag being built.
+func (o buildOptions) ref() string {
+	if o.tag == tipTag {
+		return "master"
+	}
+	return o.tag
+}
+
+func (g *groot) branchAndBuild(opts buildOptions) error {
+	name := opts.name()
+	_, err := os.Stat(longPath(g.versionDir(name)))
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	branch := "groot." + name
+
+	worktreePath := g.versionDir(name)
+	if opts.minimal {
+		err = g.addSparseWorktree(worktreePath, branch, opts.ref())
+	} else {
+		err = g.backend().addWorktree(worktreePath, branch, opts.ref())
+	}
+	if err != nil {
+		return err
+	}
+	if err := g.setUpstream(branch, opts); err != nil {
+		warnln("Setting the upstream of", branch+":", err)
+	}
+
+	if opts.noBuild {
+		// The build settings are kept for rebuild.
+		err = g.recordInstall(name, installState{
+			Tag:         opts.tag,
+			Kind:        kindSource,
+			Minimal:     opts.minimal,
+			Bootstrap:   opts.bootstrap,
+			Experiment:  opts.experiment,
+			Env:         opts.env(),
+			Provenance:  g.sourceProvenance(opts),
+			GOROOTFinal: opts.gorootFinal,
+			NotBuilt:    true,
+		})
+		if err != nil {
+			return err
+		}
+		infof("Checked out %s in %s; build it with `groot rebuild %s` or by running make.bash in src\n", name, worktreePath, name)
+		return nil
+	}
+
+	return g.build(name, opts)
+}
+
+// unbuilt reports whether the install name, recorded as inst, was
+// checked out with add --no-build and hasn't been built since, by
+// groot or by hand.
+func (g *groot) unbuilt(name string, inst installState) bool {
+	if !inst.NotBuilt {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(g.versionDir(name), "bin", exeName("go")))
+	return err != nil
+}
+
+// buildStallWarning is how long make.bash may go without output
+// before it's reported as possibly hung. Builds vary widely in how
+// long they take, but rarely go silent for long.
+const buildStallWarning = 10 * time.Minute
+
+// watchBuild watches the output of building name, warning when there
+// has been none for buildStallWarning and calling cancel when there
+// has been none for abort, unless it's 0. The returned function stops
+// watching and reports whether the build was canceled.
+func watchBuild(name string, out *activityWriter, abort time.Duration, cancel func()) (stop func() bool) {
+	warnAfter := buildStallWarning
+	interval := warnAfter / 10
+	if abort > 0 && abort/10 < interval {
+		interval = abort / 10
+	}
+
+	var stalled int32
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				idle := out.idle()
+				switch {
+				case abort > 0 && idle >= abort:
+					atomic.StoreInt32(&stalled, 1)
+					cancel()
+					return
+				case idle >= warnAfter && !warned:
+					warnf("The build of %s has printed nothing for %s and may be hung\n", name, idle.Truncate(time.Second))
+					warned = true
+				case idle < warnAfter:
+					warned = false
+				}
+			}
+		}
+	}()
+	return func() bool {
+		close(done)
+		return atomic.LoadInt32(&stalled) == 1
+	}
+}
+
+// build runs make.bash in the worktree of name.
+func (g *groot) build(name string, opts buildOptions) error {
+	opts.bootstrap = g.chooseBootstrap(name, opts)
+	bootstrap := g.bootstrapDir(opts)
+	if _, err := os.Stat(bootstrap); err != nil {
+		return fmt.Errorf("no bootstrap toolchain at %s; use `groot bootstrap upgrade` or `groot bootstrap use` to set one up", bootstrap)
+	}
+	err := checkCToolchain(opts)
+	if err != nil {
+		return err
+	}
+
+	blog, err := g.openBuildLog(name, opts)
+	if err != nil {
+		return err
+	}
+
+	// Recorded so builds by an outdated bootstrap can be found.
+	bootstrapVersion, _ := goVersion(bootstrap)
+
+	ctx, cancel := context.WithCancel(g.context())
+	defer cancel()
+	out := &activityWriter{w: blog.w}
+	out.touch()
+
+	cmd := exec.CommandContext(ctx, "./make.bash")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// The compilers make.bash runs may outlive a killed make.bash
+	// and keep its output open.
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Dir = filepath.Join(g.versionDir(name), "src")
+	cmd.Env = append(os.Environ(), "GOROOT_BOOTSTRAP="+bootstrap)
+	cmd.Env = append(cmd.Env, gorootEnv(opts.tag, g.versionDir(name), opts.gorootFinal)...)
+	cmd.Env = append(cmd.Env, opts.env()...)
+	stop := watchBuild(name, out, opts.stallTimeout, cancel)
+	start := time.Now()
+	err = cmd.Run()
+	g.recordOp(cmd, start, err)
+	if stop() {
+		err = fmt.Errorf("make.bash stalled: no output for %s (--stall-timeout)", opts.stallTimeout)
+	} else if err != nil {
+		if derr := diagnoseBuild(blog.tail.String(), opts.tag, bootstrapVersion); derr != nil {
+			err = derr
+		}
+	}
+	blog.close(err)
+	if err != nil {
+		return err
+	}
+
+	inst := installState{
+		Tag:              opts.tag,
+		Kind:             kindSource,
+		Minimal:          opts.minimal,
+		Bootstrap:        opts.bootstrap,
+		BootstrapVersion: bootstrapVersion,
+		Experiment:       opts.experiment,
+		Env:              opts.env(),
+		Provenance:       g.sourceProvenance(opts),
+		GOROOTFinal:      opts.gorootFinal,
+	}
+	err = g.smokeTest(name, inst)
+	if err != nil {
+		return g.smokeFailure(name, inst, err)
+	}
+
+	if opts.discardObjects {
+		_, err = g.cleanVersion(name, opts.tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.detach {
+		inst.Detached = true
+		inst.Commit, err = g.detachWorktree(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = g.recordInstall(name, inst)
+	if err != nil {
+		return err
+	}
+
+	if opts.test {
+		return g.runTests(name, opts.env(), opts.testTimeout)
+	}
+	return nil
+}
+
+// checkBranch returns an error if branch is neither in the bare repo
+// nor, after fetching, in the repository groot clones from.
+func (g *groot) checkBranch(branch string) error {
+	if _, err := g.backend().revParse("refs/heads/" + branch); err == nil {
+		return nil
+	}
+	infoln("Fetching, as", branch, "isn't in the local clone")
+	err := g.fetch()
+	if err != nil {
+		return err
+	}
+	if _, err := g.backend().revParse("refs/heads/" + branch); err != nil {
+		return fmt.Errorf("%s isn't a branch of the local clone or of %s", branch, g.repoURL())
+	}
+	return nil
+}
+
+// checkTag returns an error if the release being built isn't tagged
+// in the bare repo, asking the remote whether it's a release newer
+// than the last fetch or doesn't exist at all.
+func (g *groot) checkTag(opts buildOptions) error {
+	if _, ok := parseVersion(opts.tag); !ok {
+		return nil
+	}
+	tags, err := g.tags()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if tag == opts.tag {
+			return nil
+		}
+	}
+
+	// Listing the remote's tags tells a tag that doesn't exist from
+	// one made after the last fetch, which is then fetched alone. If
+	// they can't be listed the fetch is tried regardless.
+	remote, err := g.remoteTags()
+	if err == nil {
+		found := false
+		for _, tag := range remote {
+			found = found || tag == opts.tag
+		}
+		if !found {
+			return fmt.Errorf("%s isn't a tag of %s", opts.tag, g.repoURL())
+		}
+	}
+
+	infoln("Fetching", opts.tag+", which isn't in the local clone")
+	ferr := g.backend().fetchTag(g.repoURL(), opts.tag)
+	if ferr != nil {
+		if err != nil {
+			return fmt.Errorf("%s isn't in the local clone and fetching it failed (%v); listing the remote's tags failed too: %v", opts.tag, ferr, err)
+		}
+		return fmt.Errorf("fetching %s: %v", opts.tag, ferr)
+	}
+	return g.invalidateTags()
+}
+
+// bootstrapDir returns the GOROOT_BOOTSTRAP used to build opts.
+func (g *groot) bootstrapDir(opts buildOptions) string {
+	if opts.bootstrap != "" {
+		return g.versionDir(opts.bootstrap)
+	}
+	return g.defaultBootstrapDir()
+}
+
+// checkBootstrap verifies the bootstrap toolchain of opts
+// exists and is new enough to build the target tag.
+func (g *groot) checkBootstrap(opts buildOptions) error {
+	dir := g.bootstrapDir(opts)
+	name := opts.bootstrap
+	if name == "" {
+		name = "the default bootstrap"
+	}
+
+	bootstrap, err := goVersion(dir)
+	if err != nil {
+		if opts.bootstrap == "" {
+			// Checked again by make.bash, don't prevent a build
+			// if the default bootstrap is merely unusual.
+			return nil
+		}
+		return fmt.Errorf("%s can't be used as a bootstrap: %v", name, err)
+	}
+
+	if min, tooOld := bootstrapTooOld(bootstrap, opts.tag); tooOld {
+		return fmt.Errorf("%s (%s) is too old to build %s, which requires %s or later; use --bootstrap to choose a newer installed version", name, bootstrap, opts.tag, min)
+	}
+	return nil
+}
+
+// bootstrapTooOld reports whether the bootstrap toolchain version
+// can't build tag, and the minimum it requires.
+func bootstrapTooOld(bootstrap, tag string) (version, bool) {
+	target, ok := parseVersion(tag)
+	if !ok {
+		return version{}, false
+	}
+	min, ok := bootstrapMinimum(target)
+	if !ok {
+		return version{}, false
+	}
+	v, ok := parseVersion(bootstrap)
+	return min, ok && v.less(min)
+}
+
+// goVersion returns the version reported by the go binary in GOROOT dir.
+func goVersion(dir string) (string, error) {
+	out, err := exec.Command(filepath.Join(dir, "bin", "go"), "version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// go version go1.20.3 linux/amd64
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected go version output %q", out)
+	}
+	return fields[2], nil
+}
+
+// buildLogPath is where the output of make.bash is written for the
+// version name with --quiet if no --log-file is given. Like test logs,
+// it's kept out of the version's worktree.
+func (g *groot) buildLogPath(name string) string {
+	return filepath.Join(g.paths.state, ".builds", name+".log")
+}
+
+// testLogPath is where the output of the tests of the version name is
+// logged. Logs and results are kept in the state directory rather
+// than the version's, so testing doesn't modify its worktree.
+func (g *groot) testLogPath(name string) string {
+	return filepath.Join(g.paths.state, ".tests", name+".log")
+}
+
+// testResultPath is where the result of the tests of the version name
+// is recorded.
+func (g *groot) testResultPath(name string) string {
+	return filepath.Join(g.paths.state, ".tests", name+".json")
+}
+
+// testResult records the outcome of running a version's tests.
+type testResult struct {
+	Passed   bool      `json:"passed"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+	// Command is the test command that was run, run.bash if empty.
+	Command string `json:"command,omitempty"`
+}
+
+// what returns the test command the result is of.
+func (r *testResult) what() string {
+	if r.Command == "" {
+		return "run.bash"
+	}
+	return r.Command
+}
+
+// runTests runs the std tests of a built version with run.bash,
+// logging the output and recording the result in the state directory.
+func (g *groot) runTests(name string, env []string, timeout time.Duration) error {
+	return g.runTestCommand(name, env, timeout, "./run.bash", "--no-rebuild")
+}
+
+// runTestCommand runs args in the src directory of a built version,
+// logging the output and recording the result as runTests does. A
+// command of "go" is the version's own go command.
+func (g *groot) runTestCommand(name string, env []string, timeout time.Duration, args ...string) error {
+	dir := g.versionDir(name)
+	logPath := g.testLogPath(name)
+
+	err := mkdirAll(filepath.Dir(logPath))
+	if err != nil {
+		return err
+	}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	ctx := g.context()
+	if timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	command := args[0]
+	if command == "go" {
+		command = filepath.Join(dir, "bin", "go")
+	}
+	cmd := exec.CommandContext(ctx, command, args[1:]...)
+	stdout, stderr, flush := subprocessOutput(os.Stdout, os.Stderr)
+	cmd.Stdout = io.MultiWriter(stdout, logFile)
+	cmd.Stderr = io.MultiWriter(stderr, logFile)
+	cmd.Dir = filepath.Join(dir, "src")
+	cmd.Env = append(os.Environ(), "GOROOT="+dir, "PATH="+g.versionPath(dir))
+	cmd.Env = append(cmd.Env, env...)
+
+	infoln("Running", strings.Join(args, " "), "for", name, "logging to", logPath)
+	result := testResult{Started: time.Now()}
+	if args[0] != "./run.bash" {
+		result.Command = strings.Join(args, " ")
+	}
+	err = cmd.Run()
+	flush(err)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("tests timed out after %s", timeout)
+	}
+	result.Duration = time.Since(result.Started).Round(time.Second).String()
+	result.Passed = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	werr := writeJSONFile(g.testResultPath(name), &result)
+	if err != nil {
+		return fmt.Errorf("testing %s: %v (log: %s)", name, err, logPath)
+	}
+	return werr
+}
+
+// readTestResult returns the recorded test result of a version,
+// or nil if it hasn't been tested.
+func (g *groot) readTestResult(name string) (*testResult, error) {
+	path := g.testResultPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var result testResult
+	err := readJSONFile(path, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
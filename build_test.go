@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestTestResultsInState checks that test leaves the worktree clean,
+// keeping its log and result in the state directory, and that they
+// follow the version through rename and remove.
+func TestTestResultsInState(t *testing.T) {
+	home := newTestHome(t)
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	results := func(name string) []string {
+		return []string{filepath.Join(home, ".tests", name+".log"), filepath.Join(home, ".tests", name+".json")}
+	}
+
+	dir := filepath.Join(home, "go1.21.0")
+	status := func() string {
+		t.Helper()
+		cmd := exec.Command("git", "status", "--porcelain", "--ignored")
+		cmd.Dir = dir
+		cmd.Env = gitEnv()
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	mustRunGroot(t, home, "add", "go1.21.0")
+	before := status()
+	mustRunGroot(t, home, "test", "go1.21.0", "std")
+	if after := status(); after != before {
+		t.Errorf("testing changed the worktree's status from\n%s\nto\n%s", before, after)
+	}
+	for _, path := range results("go1.21.0") {
+		if !exists(path) {
+			t.Errorf("%s wasn't written", path)
+		}
+	}
+	g := &groot{paths: legacyPaths(home)}
+	if result, err := g.readTestResult("go1.21.0"); err != nil || result == nil || !result.Passed {
+		t.Errorf("readTestResult = %+v, %v; want a passing result", result, err)
+	}
+
+	mustRunGroot(t, home, "rename", "go1.21.0", "mygo")
+	for _, path := range results("go1.21.0") {
+		if exists(path) {
+			t.Errorf("%s remains after rename", path)
+		}
+	}
+	for _, path := range results("mygo") {
+		if !exists(path) {
+			t.Errorf("%s wasn't moved by rename", path)
+		}
+	}
+
+	mustRunGroot(t, home, "remove", "--yes", "mygo")
+	for _, path := range append(results("mygo"), filepath.Join(home, ".tests", "mygo.json.lock")) {
+		if exists(path) {
+			t.Errorf("%s remains after remove", path)
+		}
+	}
+}
+
+// TestBuildLogInState checks that a quiet build logs to the state
+// directory rather than the worktree, and that the log follows the
+// version through rename and remove.
+func TestBuildLogInState(t *testing.T) {
+	home := newTestHome(t)
+	g := &groot{paths: legacyPaths(home)}
+	mustRunGroot(t, home, "add", "--quiet", "go1.21.0")
+	if _, err := os.Stat(g.buildLogPath("go1.21.0")); err != nil {
+		t.Errorf("the build log wasn't written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "go1.21.0", ".groot-build.log")); !os.IsNotExist(err) {
+		t.Errorf("the build log was written to the worktree: %v", err)
+	}
+	if name, _ := g.lastBuildLog(); name != "go1.21.0" {
+		t.Errorf("lastBuildLog is of %q, want go1.21.0", name)
+	}
+
+	mustRunGroot(t, home, "rename", "go1.21.0", "mygo")
+	if _, err := os.Stat(g.buildLogPath("mygo")); err != nil {
+		t.Errorf("rename didn't move the build log: %v", err)
+	}
+	mustRunGroot(t, home, "remove", "--yes", "mygo")
+	if _, err := os.Stat(g.buildLogPath("mygo")); !os.IsNotExist(err) {
+		t.Errorf("remove left the build log: %v", err)
+	}
+}
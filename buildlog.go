@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// buildLogTail is the number of lines printed from the log
+// when a build fails.
+const buildLogTail = 30
+
+// tailWriter keeps the last n lines written to it.
+type tailWriter struct {
+	mu    sync.Mutex
+	n     int
+	lines [][]byte
+	cur   []byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range p {
+		t.cur = append(t.cur, b)
+		if b != '\n' {
+			continue
+		}
+		t.lines = append(t.lines, t.cur)
+		t.cur = nil
+		if len(t.lines) > t.n {
+			t.lines = t.lines[1:]
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the kept lines.
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(bytes.Join(append(t.lines, t.cur), nil))
+}
+
+// buildLog is where the output of a build goes.
+type buildLog struct {
+	w    io.Writer // make.bash's stdout and stderr
+	path string    // empty if not logging to a file
+	f    *os.File
+	tail *tailWriter
+}
+
+// openBuildLog sets up the output of building name according to opts.
+func (g *groot) openBuildLog(name string, opts buildOptions) (*buildLog, error) {
+	// Below levelInfo builds are quiet, the log is
+	// printed only if they fail.
+	quiet := opts.quiet || verbosity < levelInfo
+	// The tail is kept even without a log file so a failure can be
+	// diagnosed.
+	l := &buildLog{path: opts.logFile, tail: &tailWriter{n: buildLogTail}}
+	l.w = io.MultiWriter(os.Stdout, l.tail)
+	if l.path == "" && quiet {
+		l.path = g.buildLogPath(name)
+		err := mkdirAll(filepath.Dir(l.path))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if l.path == "" {
+		return l, nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, stateFileMode)
+	if err != nil {
+		return nil, err
+	}
+	l.f = f
+	if quiet {
+		l.w = io.MultiWriter(f, l.tail)
+	} else {
+		l.w = io.MultiWriter(os.Stdout, f, l.tail)
+	}
+	return l, nil
+}
+
+// close closes the log file. If the build failed with err, which
+// explains the failure if it was diagnosed, the end of the log is
+// printed below it so the failure can be seen and reported.
+func (l *buildLog) close(err error) {
+	if l.f == nil {
+		return
+	}
+	l.f.Close()
+
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Build failed: %v\n\nLast %d lines of output:\n", err, buildLogTail)
+	fmt.Fprint(os.Stderr, l.tail.String())
+	fmt.Fprintln(os.Stderr, "Full log:", l.path)
+}
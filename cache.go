@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultMetaCacheTTL = time.Hour
+	defaultTagCacheTTL  = 24 * time.Hour
+)
+
+// cacheEntry records the validators of a cached response.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+func (g *groot) metaCacheTTL() time.Duration {
+	return parseDuration("meta_cache_ttl", g.config.MetaCacheTTL, defaultMetaCacheTTL)
+}
+
+func (g *groot) tagCacheTTL() time.Duration {
+	return parseDuration("tag_cache_ttl", g.config.TagCacheTTL, defaultTagCacheTTL)
+}
+
+// parseTTL parses the value of the config key, returning def if
+// the value is unset or invalid.
+func parseDuration(key, value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		warnf("Invalid %s %q, using %s: %v", key, value, def, err)
+		return def
+	}
+	return ttl
+}
+
+// fetchCached returns the body of url, cached on disk under name.
+//
+// Cached bodies younger than the TTL are returned directly unless
+// g.refresh is set, older ones are revalidated with a conditional
+// request. If the request fails the cached body is used regardless
+// of age.
+func (g *groot) fetchCached(url, name string) ([]byte, error) {
+	dir := filepath.Join(g.paths.cache, "meta")
+	bodyPath := filepath.Join(dir, name)
+	entryPath := bodyPath + ".entry"
+
+	var entry cacheEntry
+	err := readJSONFile(entryPath, &entry)
+	if err != nil {
+		warnln("Ignoring invalid cache entry:", err)
+		entry = cacheEntry{}
+	}
+
+	cached, err := readFileShared(bodyPath)
+	if err != nil || entry.URL != url {
+		cached = nil
+	}
+
+	if cached != nil && !g.refresh && time.Since(entry.Fetched) < g.metaCacheTTL() {
+		return cached, nil
+	}
+
+	header := make(http.Header)
+	if cached != nil {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := g.getConditional(url, header)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		warnf("%v\nUsing cached data from %s ago.", err, time.Since(entry.Fetched).Round(time.Second))
+		return cached, nil
+	}
+	defer resp.Body.Close()
+
+	body := cached
+	if resp.StatusCode != http.StatusNotModified {
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		entry = cacheEntry{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+	}
+	entry.Fetched = time.Now()
+
+	err = mkdirAll(dir)
+	if err == nil {
+		err = withFileLock(bodyPath, true, func() error {
+			return writeFileAtomic(bodyPath, body, stateFileMode)
+		})
+	}
+	if err == nil {
+		err = writeJSONFile(entryPath, &entry)
+	}
+	if err != nil {
+		// The response is still good, caching is best effort.
+		warnln(fmt.Errorf("caching %s: %v", url, err))
+	}
+
+	return body, nil
+}
+
+// tagCache is the tag list of the bare repo cached on disk.
+type tagCache struct {
+	Fetched time.Time `json:"fetched"`
+	Tags    []string  `json:"tags"`
+}
+
+func (g *groot) tagCachePath() string {
+	return filepath.Join(g.paths.cache, "tags.json")
+}
+
+// cachedTags returns the tags cached on disk, or nil if there
+// are none or they may be out of date.
+func (g *groot) cachedTags() []string {
+	ttl := g.tagCacheTTL()
+	if ttl <= 0 || g.refresh {
+		return nil
+	}
+
+	var c tagCache
+	err := readJSONFile(g.tagCachePath(), &c)
+	if err != nil {
+		warnln("Ignoring invalid tag cache:", err)
+		return nil
+	}
+	if c.Tags == nil || time.Since(c.Fetched) >= ttl {
+		return nil
+	}
+
+	// Catch fetches made outside of groot, which update one or
+	// the other.
+	for _, name := range []string{"packed-refs", filepath.Join("refs", "tags")} {
+		finfo, err := os.Stat(filepath.Join(g.paths.git, name))
+		if err == nil && finfo.ModTime().After(c.Fetched) {
+			return nil
+		}
+	}
+	return c.Tags
+}
+
+// cacheTags writes tags to the disk cache.
+func (g *groot) cacheTags(tags []string) {
+	if g.tagCacheTTL() <= 0 {
+		return
+	}
+	err := mkdirAll(g.paths.cache)
+	if err == nil {
+		err = writeJSONFile(g.tagCachePath(), &tagCache{Fetched: time.Now(), Tags: tags})
+	}
+	if err != nil {
+		// Caching is best effort.
+		warnln("Caching tags:", err)
+	}
+}
+
+// invalidateTags discards the cached tag list.
+func (g *groot) invalidateTags() error {
+	g.tagList = nil
+	err := os.Remove(g.tagCachePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumsFile records the SHA256 hashes of verified downloads, in
+// the format of sha256sum and the SHA256SUMS files of releases.
+const checksumsFile = "checksums.txt"
+
+func (g *groot) checksumsPath() string {
+	return filepath.Join(g.paths.state, checksumsFile)
+}
+
+// parseChecksums reads "<sha256>  <filename>" lines from r. A "*"
+// marking the file as binary, blank lines, and # comments are
+// accepted, as are lines with the file name first.
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a hash and a file name", n)
+		}
+		if isSHA256(fields[1]) && !isSHA256(fields[0]) {
+			fields[0], fields[1] = fields[1], fields[0]
+		}
+		hash, name := strings.ToLower(fields[0]), filepath.Base(strings.TrimPrefix(fields[1], "*"))
+		if !isSHA256(hash) {
+			return nil, fmt.Errorf("line %d: invalid SHA256 hash %q", n, fields[0])
+		}
+		if prev, ok := sums[name]; ok && prev != hash {
+			return nil, fmt.Errorf("line %d: %s is listed with two different hashes", n, name)
+		}
+		sums[name] = hash
+	}
+	return sums, scanner.Err()
+}
+
+func isSHA256(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) == sha256.Size*2
+}
+
+// mirrorChecksum returns the hash of filename from the mirror checksum
+// file. A file that isn't listed is an error, there's no fallback.
+func (g *groot) mirrorChecksum(filename string) (string, error) {
+	if g.mirrorSums == nil {
+		f, err := os.Open(g.mirrorFile)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		sums, err := parseChecksums(f)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", g.mirrorFile, err)
+		}
+		g.mirrorSums = sums
+	}
+
+	hash, ok := g.mirrorSums[filename]
+	if !ok {
+		return "", fmt.Errorf("%s isn't listed in the checksum file %s", filename, g.mirrorFile)
+	}
+	return hash, nil
+}
+
+func formatChecksums(sums map[string]string) []byte {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", sums[name], name)
+	}
+	return buf.Bytes()
+}
+
+// localChecksums returns the recorded hashes.
+func (g *groot) localChecksums() (map[string]string, error) {
+	data, err := readFileShared(g.checksumsPath())
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sums, err := parseChecksums(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", g.checksumsPath(), err)
+	}
+	return sums, nil
+}
+
+// localChecksum returns the recorded hash of filename, or "".
+func (g *groot) localChecksum(filename string) (string, error) {
+	sums, err := g.localChecksums()
+	if err != nil {
+		return "", err
+	}
+	return sums[filename], nil
+}
+
+// recordChecksums merges add into the checksums file. A file already
+// recorded with a different hash is an error and nothing is written,
+// since one of the two is wrong and groot can't tell which.
+func (g *groot) recordChecksums(add map[string]string) (int, error) {
+	path := g.checksumsPath()
+	err := mkdirAll(filepath.Dir(path))
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	err = withFileLock(path, true, func() error {
+		sums := make(map[string]string)
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			sums, err = parseChecksums(bytes.NewReader(data))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		var conflicts []string
+		for name, hash := range add {
+			switch sums[name] {
+			case hash:
+			case "":
+				sums[name] = hash
+				added++
+			default:
+				conflicts = append(conflicts, fmt.Sprintf("%s: recorded %s, got %s", name, sums[name], hash))
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			return fmt.Errorf("hashes conflict with %s, remove the wrong entries:\n  %s", path, strings.Join(conflicts, "\n  "))
+		}
+		if added == 0 {
+			return nil
+		}
+		return writeFileAtomic(path, formatChecksums(sums), stateFileMode)
+	})
+	return added, err
+}
+
+func checksums(g *groot, args []string) error {
+	if len(args) > 2 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "import":
+		// The file defaults to stdin.
+		r := io.Reader(os.Stdin)
+		if len(args) == 2 {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		sums, err := parseChecksums(r)
+		if err != nil {
+			return err
+		}
+		added, err := g.recordChecksums(sums)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d of %d checksums into %s\n", added, len(sums), g.checksumsPath())
+		return nil
+	case "export":
+		sums, err := g.localChecksums()
+		if err != nil {
+			return err
+		}
+		data := formatChecksums(sums)
+		if len(args) == 1 {
+			os.Stdout.Write(data)
+			return nil
+		}
+		err = ioutil.WriteFile(args[1], data, 0644)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+	return usageErrorf("unknown checksums command: %s", args[0])
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// regenerablePaths lists what make.bash leaves in a tree that isn't
+// needed to run the toolchain, with the release whose build started
+// creating it. make.bash recreates all of them.
+var regenerablePaths = []struct {
+	since string
+	path  string // slash separated, relative to the version directory
+}{
+	{"go1", "pkg/obj"},           // intermediate objects; from go1.10 also make.bash's build cache
+	{"go1", "src/cmd/dist/dist"}, // the dist tool, also installed under pkg/tool
+	{"go1.5", "pkg/bootstrap"},   // the toolchain built with the bootstrap
+}
+
+// regenerable returns the paths of regenerablePaths that apply to
+// builds of tag. Builds that aren't of a release get them all.
+func regenerable(tag string) []string {
+	v, ok := parseVersion(tag)
+	var paths []string
+	for _, p := range regenerablePaths {
+		since, _ := parseVersion(p.since)
+		if ok && v.less(since) {
+			continue
+		}
+		paths = append(paths, p.path)
+	}
+	return paths
+}
+
+// isRegenerable reports whether the slash separated path, relative to
+// a version directory, is one clean removes from builds of tag.
+func isRegenerable(path, tag string) bool {
+	for _, p := range regenerable(tag) {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanVersion removes the regenerable paths of the version name, a
+// build of tag, and returns the space reclaimed.
+func (g *groot) cleanVersion(name, tag string) (int64, error) {
+	dir := g.versionDir(name)
+
+	// Since go1.10 make.bash uses pkg/obj/go-build as its build cache;
+	// let the tree's own go command clear it, as it knows its layout.
+	cache := filepath.Join(dir, "pkg", "obj", "go-build")
+	if _, err := os.Stat(cache); err == nil {
+		cmd := g.goCmd(name, "clean", "-cache")
+		cmd.Env = append(cmd.Env, "GOROOT="+dir, "GOCACHE="+cache)
+		debugln("Running", strings.Join(cmd.Args, " "), "for", name)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			debugf("go clean -cache for %s: %v: %s\n", name, err, out)
+		}
+	}
+
+	var freed int64
+	for _, rel := range regenerable(tag) {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err := os.Lstat(longPath(path)); err != nil {
+			continue
+		}
+		size, _ := dirSize(longPath(path))
+		debugln("Removing", path)
+		makeWritable(path)
+		err := os.RemoveAll(longPath(path))
+		if err != nil {
+			return freed, err
+		}
+		freed += size
+	}
+	return freed, nil
+}
+
+func clean(fs *flag.FlagSet, g *groot) func(args []string) error {
+	all := fs.Bool("all", false, "clean every installed version")
+	return func(args []string) error {
+		var err error
+		if *all == (len(args) == 1) || len(args) > 1 {
+			return errUsage
+		}
+
+		var names []string
+		if *all {
+			names, err = g.installed()
+			if err != nil {
+				return err
+			}
+		} else {
+			name, err := g.resolveInstalled(args[0], false)
+			if err != nil {
+				return err
+			}
+			if finfo, err := os.Stat(g.versionDir(name)); err != nil || !finfo.IsDir() {
+				return fmt.Errorf("%s is an archived snapshot; there's nothing to clean", name)
+			}
+			names = []string{name}
+		}
+
+		var total int64
+		for _, name := range names {
+			inst, err := g.installInfo(name)
+			if err != nil {
+				return err
+			}
+			if inst.Kind != kindSource {
+				debugln("Skipping", name+", which isn't built from source")
+				continue
+			}
+			freed, err := g.cleanVersion(name, inst.Tag)
+			if err != nil {
+				return fmt.Errorf("cleaning %s: %v", name, err)
+			}
+			if freed > 0 {
+				fmt.Printf("%s: reclaimed %s\n", name, formatSize(freed))
+			}
+			total += freed
+		}
+		if len(names) > 1 || total == 0 {
+			fmt.Println("Reclaimed", formatSize(total))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of groot, or of a command with subcommands
+// such as bootstrap.
+type command struct {
+	name    string   // including the parent command's, such as "bootstrap list"
+	aliases []string // other names the command is run by
+	usage   []string // synopses, without the program and command names
+	minArgs int      // the fewest positional arguments it takes
+
+	// rawArgs passes the arguments to the command unparsed, for those
+	// that pass them on to other programs or subcommands. Only a
+	// first argument of -h or --help is recognized.
+	rawArgs bool
+
+	// dashArgs passes "--" and the arguments after it to the command
+	// unparsed, after its positional arguments, for those that run
+	// other programs with them.
+	dashArgs bool
+
+	// setup defines the command's flags on fs, which may set fields of
+	// g, and returns the function running the command with the
+	// positional arguments left after parsing them.
+	setup func(fs *flag.FlagSet, g *groot) func(args []string) error
+}
+
+// noFlags adapts run to a command without flags.
+func noFlags(run func(g *groot, args []string) error) func(*flag.FlagSet, *groot) func([]string) error {
+	return func(_ *flag.FlagSet, g *groot) func([]string) error {
+		return func(args []string) error { return run(g, args) }
+	}
+}
+
+// commands are groot's subcommands, in the order help lists them.
+var commands = []command{
+	{name: "init", usage: []string{"[--skip-build | --binary-only version] [--force] [--repo-url url] [--arch GOARCH] [--no-symlink] [--bare-dir-reuse path] [--insecure-skip-verify]"}, setup: initGroot},
+	{name: "add", usage: []string{
+		"[--force] [--keep n] [--minimal] [--no-build] [--discard-objects] [--detach] [--log-file path] [--quiet] [--stall-timeout duration] [--goamd64 level] [--goarm version] [--experiment names] [--bootstrap version] [--goroot-final path] [--env-file file] [--env KEY=VALUE]... [--test [--timeout duration]] tag",
+		"--binary [--force] [--keep n] [--minimal] [--arch GOARCH] tag",
+		"[--binary] --rc [options]",
+		"--branch branch [options]",
+		"--binary [--parallel-download [--jobs n]] [--keep n] [--minimal] [--arch GOARCH] tag...",
+		"--from file [--inspect] [--force] [--keep n] [--minimal] [--arch GOARCH] [tag]",
+	}, setup: add},
+	{name: "activate", usage: []string{
+		"[--no-symlink] [--prerelease] [--force] [version]",
+		"--print|--local-only [--prerelease] [version]",
+	}, setup: activate},
+	{name: "deactivate", setup: noFlags(deactivate)},
+	{name: "current", setup: noFlags(current)},
+	{name: "local", usage: []string{
+		"[--format go-version|tool-versions] [version]",
+		"[--format go-version|tool-versions] --unset",
+	}, setup: local},
+	{name: "list", aliases: []string{"ls"}, usage: []string{"[--long] [--bootstrap] [--tree] [--size] [--label filter]..."}, setup: list},
+	{name: "info", usage: []string{"[--json] version"}, minArgs: 1, setup: info},
+	{name: "status", setup: noFlags(status)},
+	{name: "available", usage: []string{"[--remote | --remote-git] [--prerelease] [--latest-per-minor] [--supported] [--binary [--os GOOS] [--arch GOARCH]] [--since-date YYYY-MM-DD] [--refresh]"}, setup: available},
+	{name: "latest", usage: []string{"[--remote] [--prerelease] [--refresh]"}, setup: latest},
+	{name: "update", usage: []string{
+		"[tip [--keep n] [--archive]]",
+		"[--yes] [version]",
+		"--rebuild version",
+	}, setup: update},
+	{name: "upgrade", usage: []string{"[--check] [--refresh]"}, setup: upgrade},
+	{name: "rebuild", usage: []string{
+		"[--log-file path] [--quiet] [--stall-timeout duration] [--test [--timeout duration]] [version]",
+		"--stale-bootstrap [--quiet] [--test [--timeout duration]]",
+	}, setup: rebuild},
+	{name: "remove", aliases: []string{"rm"}, usage: []string{
+		"[--yes] version...",
+		"[--yes] --label filter...",
+	}, setup: remove},
+	{name: "rename", usage: []string{"version name"}, minArgs: 2, setup: noFlags(rename)},
+	{name: "label", usage: []string{
+		"version [key=value...]",
+		"--remove version key...",
+	}, minArgs: 1, setup: label},
+	{name: "note", usage: []string{"version [text]"}, minArgs: 1, setup: noFlags(note)},
+	{name: "env", usage: []string{
+		"[--json]",
+		"[--json] [--goroot-only] version",
+		"--add-to-profile",
+	}, setup: env},
+	{name: "exec", usage: []string{"version command [args...]"}, minArgs: 2, rawArgs: true, setup: noFlags(execCmd)},
+	{name: "run", usage: []string{
+		"[go command] [args...]",
+		"[-q] [version] [file.go... | package] [--] [args...]",
+	}, minArgs: 1, rawArgs: true, setup: noFlags(runCmd)},
+	{name: "which", usage: []string{"version"}, minArgs: 1, setup: noFlags(which)},
+	{name: "path", usage: []string{"[version]"}, setup: noFlags(pathCmd)},
+	{name: "paths", usage: []string{"[--json]"}, setup: printPaths},
+	{name: "go-env", usage: []string{
+		"[--json] version [VAR...]",
+		"--diff other [--json] version [VAR...]",
+	}, minArgs: 1, setup: goEnv},
+	{name: "compare", usage: []string{"[--bench | --side-by-side [--width columns]] versionA versionB -- command [args...]"}, dashArgs: true, setup: compare},
+	{name: "test", usage: []string{
+		"[--timeout duration] version [packages...] [-- go test flags...]",
+		"--full|--all [--timeout duration] version",
+	}, minArgs: 1, dashArgs: true, setup: testCmd},
+	{name: "verify", usage: []string{"version"}, minArgs: 1, setup: noFlags(verify)},
+	{name: "verify-download", usage: []string{"[--arch GOARCH] [--mirror-file file] version"}, minArgs: 1, setup: verifyDownload},
+	{name: "checksums", usage: []string{
+		"import [file]",
+		"export [file]",
+	}, minArgs: 1, setup: noFlags(checksums)},
+	{name: "clean", usage: []string{
+		"version",
+		"--all",
+	}, setup: clean},
+	{name: "prune", setup: noFlags(prune)},
+	{name: "repair", usage: []string{"--reclone [--bare-dir-reuse path]"}, setup: repair},
+	{name: "reset", usage: []string{"[--yes] [--purge]"}, setup: reset},
+	{name: "migrate", usage: []string{"--xdg"}, setup: migrate},
+	{name: "bootstrap", usage: subcommandUsage(bootstrapCommands), minArgs: 1, rawArgs: true, setup: noFlags(bootstrap)},
+	{name: "direnv", usage: subcommandUsage(direnvCommands), minArgs: 1, rawArgs: true, setup: noFlags(direnv)},
+	{name: "doctor", setup: noFlags(doctor)},
+	{name: "bugreport", usage: []string{"[--no-doctor]"}, setup: bugreport},
+}
+
+// findCommand returns the command of cmds named or aliased name.
+func findCommand(cmds []command, name string) (command, bool) {
+	for _, c := range cmds {
+		if c.name == name || strings.HasSuffix(c.name, " "+name) {
+			return c, true
+		}
+		for _, alias := range c.aliases {
+			if alias == name {
+				return c, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// usageError makes runCommand print the usage of the command after
+// msg, if there is one.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	if e.msg == "" {
+		return "invalid usage"
+	}
+	return e.msg
+}
+
+// errUsage is returned by commands given arguments they can't make
+// sense of.
+var errUsage = &usageError{}
+
+// usageErrorf returns a usageError explaining what's wrong with the
+// arguments.
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitStatus ends groot with the status, without printing anything
+// more. It's returned by commands that have reported why they failed
+// themselves, and by exec and run with the status of the program they
+// ran.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// exitWith returns the error ending groot with code, nil if it's 0.
+func exitWith(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return exitStatus(code)
+}
+
+// printUsage writes the synopses of c to stdout, followed by its flags
+// if fs has any.
+func (c command) printUsage(fs *flag.FlagSet) {
+	if len(c.usage) == 0 {
+		fmt.Println(os.Args[0], c.name)
+	}
+	for _, u := range c.usage {
+		fmt.Println(os.Args[0], c.name, u)
+	}
+	if len(c.aliases) > 0 {
+		fmt.Println("Also run as:", strings.Join(c.aliases, ", "))
+	}
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Println("\nFlags:")
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+		fs.SetOutput(nil)
+	}
+}
+
+// flagSet returns the flag set of c with its flags defined, and the
+// function running it.
+func (g *groot) flagSet(c command) (*flag.FlagSet, func([]string) error) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	run := c.setup(fs, g)
+	fs.Usage = func() { c.printUsage(fs) }
+	return fs, run
+}
+
+// runCommand parses the flags of c from args, runs it, and returns the
+// exit code its error maps to. Usage errors print the usage of c, and
+// --help prints it and succeeds.
+func (g *groot) runCommand(c command, args []string) int {
+	fs, run := g.flagSet(c)
+	if c.rawArgs {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "-help") {
+			c.printUsage(fs)
+			return 0
+		}
+		if len(args) < c.minArgs {
+			return commandExitCode(c, fs, errUsage)
+		}
+		return commandExitCode(c, fs, run(args))
+	}
+
+	var dash []string
+	if c.dashArgs {
+		for i, arg := range args {
+			if arg == "--" {
+				args, dash = args[:i], args[i:]
+				break
+			}
+		}
+	}
+	args, err := parseFlags(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		// The flag package has printed the error and the usage.
+		return exitError
+	}
+	if len(args) < c.minArgs {
+		err = errUsage
+	} else {
+		err = run(append(args, dash...))
+	}
+	return commandExitCode(c, fs, err)
+}
+
+// commandExitCode reports err, returned by c, and returns groot's exit code
+// for it.
+func commandExitCode(c command, fs *flag.FlagSet, err error) int {
+	var status exitStatus
+	var usageErr *usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &status):
+		return int(status)
+	case errors.As(err, &usageErr):
+		if usageErr.msg != "" {
+			fmt.Println(usageErr.msg)
+		}
+		c.printUsage(fs)
+		return exitError
+	}
+	return printError(err)
+}
+
+// invoke runs the command set up by setup with args, for commands
+// that run others, such as upgrade adding the new patch release.
+func (g *groot) invoke(name string, setup func(*flag.FlagSet, *groot) func([]string) error, args ...string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	run := setup(fs, g)
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	return run(args)
+}
+
+// runSubcommand runs the subcommand of cmds named by args[0], for
+// commands such as bootstrap.
+func (g *groot) runSubcommand(cmds []command, args []string) error {
+	c, ok := findCommand(cmds, args[0])
+	if !ok {
+		return usageErrorf("unknown subcommand: %s", args[0])
+	}
+	return exitWith(g.runCommand(c, args[1:]))
+}
+
+// subcommandUsage returns the synopses of the subcommands cmds, for
+// the usage of their parent.
+func subcommandUsage(cmds []command) []string {
+	var usage []string
+	for _, c := range cmds {
+		sub := c.name[strings.LastIndexByte(c.name, ' ')+1:]
+		if len(c.usage) == 0 {
+			usage = append(usage, sub)
+		}
+		for _, u := range c.usage {
+			usage = append(usage, sub+" "+u)
+		}
+	}
+	return usage
+}
+
+// help prints the usage of the command named by args, or lists the
+// commands.
+func help(args []string) int {
+	if len(args) > 0 {
+		c, ok := findCommand(commands, args[0])
+		if !ok {
+			fmt.Println("unknown subcommand:", args[0])
+			return exitError
+		}
+		// The flags are only described, so they don't need a
+		// set up groot.
+		fs, _ := new(groot).flagSet(c)
+		c.printUsage(fs)
+		return 0
+	}
+
+	fmt.Println(`groot: GOROOT manager`)
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, c := range commands {
+		if len(c.aliases) > 0 {
+			fmt.Printf("  %s (%s)\n", c.name, strings.Join(c.aliases, ", "))
+			continue
+		}
+		fmt.Println("  " + c.name)
+	}
+	fmt.Println()
+	fmt.Printf("Run `%s help command` or `%s command --help` for the usage of a command.\n", os.Args[0], os.Args[0])
+	return 0
+}
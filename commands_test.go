@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sourceLineRE matches the file:line prefix of log output, which moves
+// whenever the code does.
+var sourceLineRE = regexp.MustCompile(`(?m)^\w+\.go:\d+: `)
+
+// TestUsageGolden checks the output of help, --help, and usage errors
+// against testdata/usage.
+func TestUsageGolden(t *testing.T) {
+	// The commands needing init only look for the clone's directory
+	// before reporting a usage error.
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(home, ".bare"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"help", []string{"help"}},
+		{"no-args", nil},
+		{"help-list", []string{"help", "list"}},
+		{"help-alias", []string{"help", "rm"}},
+		{"help-unknown", []string{"help", "frob"}},
+		{"list-help", []string{"list", "--help"}},
+		{"unknown-command", []string{"frob"}},
+		{"unknown-flag", []string{"list", "--bogus"}},
+		{"missing-args", []string{"info"}},
+		{"usage-message", []string{"checksums", "frob"}},
+		{"exec-help", []string{"exec", "--help"}},
+		{"exec-missing-args", []string{"exec", "go1.21.0"}},
+		{"subcommands", []string{"bootstrap"}},
+		{"unknown-subcommand", []string{"bootstrap", "frob"}},
+		{"subcommand-help", []string{"bootstrap", "rm", "--help"}},
+		{"compare-no-command", []string{"compare", "go1.21.0", "go1.22.0", "--"}},
+		{"flag-conflict", []string{"init", "--skip-build", "--binary-only", "go1.22.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runGroot(t, append([]string{"--home", home}, tt.args...)...)
+			got := fmt.Sprintf("$ groot %s\nexit %d\n-- stdout --\n%s-- stderr --\n%s",
+				strings.Join(tt.args, " "), code, stdout, sourceLineRE.ReplaceAllString(stderr, ""))
+			got = strings.ReplaceAll(got, home, "$HOME")
+
+			golden := filepath.Join("testdata", "usage", tt.name+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
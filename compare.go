@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// compareResult is the outcome of running the compared command
+// under one version.
+type compareResult struct {
+	name           string
+	stdout, stderr []byte
+	code           int
+}
+
+func compare(fs *flag.FlagSet, g *groot) func(args []string) error {
+	bench := fs.Bool("bench", false, "compare the benchmark results of go test -bench statistically instead of diffing output")
+	sideBySide := fs.Bool("side-by-side", false, "print output in two columns instead of as a unified diff")
+	width := fs.Int("width", 160, "total `columns` of --side-by-side output")
+	return func(args []string) error {
+		var err error
+		if len(args) < 2 {
+			return errUsage
+		}
+		// runCommand passes the -- separating the command on.
+		versions, command := args[:2], args[2:]
+		if len(command) > 0 && command[0] == "--" {
+			command = command[1:]
+		}
+		if versions[0] == "--" || versions[1] == "--" || len(command) == 0 {
+			return errUsage
+		}
+
+		var results [2]compareResult
+		for i, name := range versions {
+			fmt.Fprintf(os.Stderr, "Running under %s: %s\n", name, strings.Join(command, " "))
+			results[i], err = g.runCompared(name, command[0], command[1:]...)
+			if err != nil {
+				return err
+			}
+		}
+		a, b := results[0], results[1]
+
+		switch {
+		case *bench:
+			err = printBenchComparison(os.Stdout, a, b)
+		case *sideBySide:
+			printSideBySide(os.Stdout, "stdout", a.name, b.name, a.stdout, b.stdout, *width)
+			printSideBySide(os.Stdout, "stderr", a.name, b.name, a.stderr, b.stderr, *width)
+		default:
+			printUnifiedDiff(os.Stdout, a.name+" stdout", b.name+" stdout", a.stdout, b.stdout)
+			printUnifiedDiff(os.Stdout, a.name+" stderr", b.name+" stderr", a.stderr, b.stderr)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Exit code: %s %d, %s %d\n", a.name, a.code, b.name, b.code)
+		if a.code != b.code {
+			return exitStatus(exitError)
+		}
+		return nil
+	}
+}
+
+// runCompared runs command under the installed version name,
+// capturing its output.
+func (g *groot) runCompared(name, command string, args ...string) (compareResult, error) {
+	cmd, err := g.versionCommand(name, command, args...)
+	if err != nil {
+		return compareResult{}, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	res := compareResult{name: name}
+	err = cmd.Run()
+	if code, ok := exitCode(err); ok {
+		res.code = code
+	} else if err != nil {
+		return res, err
+	}
+	res.stdout, res.stderr = stdout.Bytes(), stderr.Bytes()
+	return res, nil
+}
+
+// splitLines splits b into lines without their line endings.
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(strings.Replace(string(b), "\r\n", "\n", -1), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffOp is one line of a line-based diff.
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	line string
+}
+
+// maxDiffCells bounds the size of the LCS table. Beyond it the
+// differing middle section is reported as replaced wholesale.
+const maxDiffCells = 1 << 24
+
+// diffLines returns the edit script turning a into b.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	// Trim the common prefix and suffix, which are typically
+	// most of the output.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		ops = append(ops, diffOp{' ', a[pre]})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common
+		// subsequence of ma[i:] and mb[j:].
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				switch {
+				case ma[i] == mb[j]:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] >= lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				ops = append(ops, diffOp{' ', ma[i]})
+				i++
+				j++
+			case j == len(mb) || i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]:
+				ops = append(ops, diffOp{'-', ma[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', mb[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// printUnifiedDiff writes the differences between a and b to w
+// in unified diff format, or a note if they're identical.
+func printUnifiedDiff(w io.Writer, nameA, nameB string, a, b []byte) {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		fmt.Fprintf(w, "%s and %s are identical\n", nameA, nameB)
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// lineA and lineB are the 1-based line numbers of ops[i].
+	lineA, lineB := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+
+		// Start the hunk diffContext lines before the change.
+		start := i
+		for start > 0 && i-start < diffContext && ops[start-1].kind == ' ' {
+			start--
+		}
+		startA, startB := lineA-(i-start), lineB-(i-start)
+
+		// Extend it until diffContext*2 unchanged lines in a row,
+		// so nearby changes share a hunk.
+		end, same := i, 0
+		for end < len(ops) && same <= diffContext*2 {
+			if ops[end].kind == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+			end++
+		}
+		if same > diffContext {
+			end -= same - diffContext
+		}
+
+		var countA, countB int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(w, "%c%s\n", op.kind, op.line)
+		}
+
+		lineA, lineB = startA+countA, startB+countB
+		i = end
+	}
+}
+
+// printSideBySide writes a and b to w in two columns, marking
+// lines that differ.
+func printSideBySide(w io.Writer, stream, nameA, nameB string, a, b []byte, width int) {
+	col := (width - 3) / 2
+	if col < 10 {
+		col = 10
+	}
+	clip := func(s string) string {
+		s = strings.Replace(s, "\t", "    ", -1)
+		if len(s) > col {
+			return s[:col-3] + "..."
+		}
+		return s
+	}
+
+	fmt.Fprintf(w, "%-*s   %s\n", col, clip(nameA+" "+stream), clip(nameB+" "+stream))
+	ops := diffLines(splitLines(a), splitLines(b))
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			fmt.Fprintf(w, "%-*s   %s\n", col, clip(ops[i].line), clip(ops[i].line))
+			i++
+			continue
+		}
+
+		// Pair up a run of removed lines with the added lines
+		// following it.
+		var removed, added []string
+		for ; i < len(ops) && ops[i].kind == '-'; i++ {
+			removed = append(removed, ops[i].line)
+		}
+		for ; i < len(ops) && ops[i].kind == '+'; i++ {
+			added = append(added, ops[i].line)
+		}
+		for j := 0; j < len(removed) || j < len(added); j++ {
+			switch {
+			case j < len(removed) && j < len(added):
+				fmt.Fprintf(w, "%-*s | %s\n", col, clip(removed[j]), clip(added[j]))
+			case j < len(removed):
+				fmt.Fprintf(w, "%-*s <\n", col, clip(removed[j]))
+			default:
+				fmt.Fprintf(w, "%-*s > %s\n", col, "", clip(added[j]))
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// printBenchComparison writes a benchstat-style table comparing
+// the benchmark results in the stdout of a and b.
+func printBenchComparison(w io.Writer, a, b compareResult) error {
+	old, new := parseBenchmarks(a.stdout), parseBenchmarks(b.stdout)
+	if len(old.names) == 0 && len(new.names) == 0 {
+		return fmt.Errorf("no benchmark results in the output of either version; did the command include -bench?")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, unit := range mergeOrder(old.units, new.units) {
+		fmt.Fprintf(tw, "name\t%s %s\t%s %s\tdelta\n", a.name, unitLabel(unit), b.name, unitLabel(unit))
+		for _, name := range mergeOrder(old.names, new.names) {
+			x, y := old.values[benchKey{name, unit}], new.values[benchKey{name, unit}]
+			if len(x) == 0 && len(y) == 0 {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, formatSample(x, unit), formatSample(y, unit), formatDelta(x, y))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// mergeOrder returns the union of a and b, in the order they first appear.
+func mergeOrder(a, b []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range append(append([]string(nil), a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareCommand(t *testing.T) {
+	home := newTestHome(t)
+	for _, tag := range []string{"go1.21.0", "go1.22.0"} {
+		if stdout, stderr, code := runGroot(t, "--home", home, "add", tag); code != 0 {
+			t.Fatalf("add %s: exit code %d\n%s%s", tag, code, stdout, stderr)
+		}
+	}
+
+	stdout, stderr, code := runGroot(t, "--home", home, "compare", "go1.21.0", "go1.22.0", "--", "go", "version")
+	if code != 0 {
+		t.Fatalf("compare: exit code %d\nstdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	for _, want := range []string{
+		"-go version go1.21.0 linux/amd64",
+		"+go version go1.22.0 linux/amd64",
+		"Exit code: go1.21.0 0, go1.22.0 0",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("compare output doesn't contain %q:\n%s", want, stdout)
+		}
+	}
+	if want := "Running under go1.21.0: go version\n"; !strings.Contains(stderr, want) {
+		t.Errorf("compare stderr doesn't contain %q:\n%s", want, stderr)
+	}
+}
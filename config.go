@@ -0,0 +1,72 @@
+package main
+
+type config struct {
+	// Layout is "xdg" once migrated to the XDG base directory
+	// layout, empty for the legacy layout under $HOME/.groot.
+	Layout string `json:"layout,omitempty"`
+
+	// AllowInsecureSkipVerify permits the --insecure-skip-verify flag.
+	AllowInsecureSkipVerify bool `json:"allow_insecure_skip_verify,omitempty"`
+
+	// CABundle is a PEM file or directory of PEM files trusted
+	// in addition to the system roots.
+	CABundle string `json:"ca_bundle,omitempty"`
+
+	// MetaCacheTTL is how long cached release metadata is used
+	// without revalidation, as parsed by time.ParseDuration.
+	MetaCacheTTL string `json:"meta_cache_ttl,omitempty"`
+
+	// TagCacheTTL is how long the tag list of the bare repo is
+	// cached on disk, as parsed by time.ParseDuration. "0" disables
+	// the disk cache.
+	TagCacheTTL string `json:"tag_cache_ttl,omitempty"`
+
+	// VersionSource is where available versions are discovered:
+	// "git" for the tags of the bare repo (the default) or "go.dev"
+	// for the release metadata published there.
+	VersionSource string `json:"version_source,omitempty"`
+
+	// NetworkTimeout limits how long clones and fetches may take, as
+	// parsed by time.ParseDuration. Unlimited by default.
+	NetworkTimeout string `json:"network_timeout,omitempty"`
+
+	// StallTimeout is how long a clone or fetch may go without
+	// reporting progress before it's aborted. Defaults to 10m.
+	StallTimeout string `json:"stall_timeout,omitempty"`
+
+	// MaxRate limits the speed of downloads, such as "2MB" for 2 MiB
+	// per second. Unlimited by default.
+	MaxRate string `json:"max_rate,omitempty"`
+
+	// SkipExtractCheck disables the check that extracted binary
+	// releases are complete and have a runnable go command.
+	SkipExtractCheck bool `json:"skip_extract_check,omitempty"`
+
+	// MaxVersions is the number of installed versions add keeps,
+	// removing the oldest beyond it. Unlimited if 0.
+	MaxVersions int `json:"max_versions,omitempty"`
+
+	// Shared enables shared installation mode, as if --shared was given.
+	Shared bool `json:"shared,omitempty"`
+
+	// DirMode is the octal permission of directories groot creates
+	// in shared mode. Defaults to 2770, group-writable and setgid so
+	// new entries inherit the group.
+	DirMode string `json:"dir_mode,omitempty"`
+
+	// ShimGenerations makes activate link bin to a new directory of
+	// shims each time, keeping the earlier ones until the next boot,
+	// so paths already resolved through bin keep their version.
+	ShimGenerations bool `json:"shim_generations,omitempty"`
+
+	// WarnLocalMismatch makes every command warn when the nearest
+	// .go-version or .tool-versions requests another version than
+	// the active one.
+	WarnLocalMismatch bool `json:"warn_local_mismatch,omitempty"`
+}
+
+func loadConfig(path string) (config, error) {
+	var cfg config
+	err := readJSONFile(path, &cfg)
+	return cfg, err
+}
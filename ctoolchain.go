@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// noCToolchainError is returned when a build needs a C compiler that
+// can't be found. It makes groot exit with exitNoCToolchain.
+type noCToolchainError struct {
+	cc string // the compiler looked for, or "" if unknown
+}
+
+func (e *noCToolchainError) Error() string {
+	cc := "a C compiler"
+	if e.cc != "" {
+		cc = "the C compiler " + e.cc
+	}
+	return fmt.Sprintf("C toolchain required for cgo-enabled build, but %s wasn't found; install gcc or clang, or build with --env CGO_ENABLED=0", cc)
+}
+
+// defaultCCs are the compilers make.bash tries when CC isn't set.
+var defaultCCs = []string{"gcc", "clang", "cc"}
+
+// buildEnvVar returns the value of key in the environment of a build
+// with opts.
+func buildEnvVar(opts buildOptions, key string) string {
+	var value string
+	for _, kv := range append(os.Environ(), opts.env()...) {
+		if strings.HasPrefix(kv, key+"=") {
+			value = kv[len(key)+1:]
+		}
+	}
+	return value
+}
+
+// needsCToolchain reports whether building opts requires a C
+// compiler: releases before go1.5 are partly written in C, and later
+// ones enable cgo unless CGO_ENABLED=0, until go1.20 disabled it by
+// default on systems without a C toolchain.
+func needsCToolchain(opts buildOptions) bool {
+	switch buildEnvVar(opts, "CGO_ENABLED") {
+	case "0":
+		v, ok := parseVersion(opts.tag)
+		return ok && v.less(version{major: 1, minor: 5})
+	case "1":
+		return true
+	}
+	v, ok := parseVersion(opts.tag)
+	return ok && v.less(version{major: 1, minor: 20, pre: "beta", preNum: 1})
+}
+
+// checkCToolchain returns a noCToolchainError if building opts needs
+// a C compiler and none is on PATH, before make.bash gets far enough
+// to fail on it.
+func checkCToolchain(opts buildOptions) error {
+	if !needsCToolchain(opts) {
+		return nil
+	}
+	if cc := buildEnvVar(opts, "CC"); cc != "" {
+		// CC may include flags.
+		cc = strings.Fields(cc)[0]
+		if _, err := exec.LookPath(cc); err != nil {
+			return &noCToolchainError{cc: cc}
+		}
+		return nil
+	}
+	for _, cc := range defaultCCs {
+		if _, err := exec.LookPath(cc); err == nil {
+			return nil
+		}
+	}
+	return &noCToolchainError{}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// buildProblem is a known cause of make.bash failures, recognized by
+// a pattern in its output.
+type buildProblem struct {
+	re *regexp.Regexp
+	// explain returns the error to report for a build of tag with the
+	// bootstrap of version bootstrap, given the submatches of re.
+	explain func(m []string, tag, bootstrap string) error
+}
+
+// buildProblems are tried in order against the end of the output of a
+// failed build; the first match explains the failure.
+var buildProblems = []buildProblem{
+	{
+		// cmd/dist of go1.17 and later has a file in package
+		// building_Go_requires_Go_1_17_13_or_later that older
+		// bootstraps don't exclude, and make.bash checks the version
+		// itself since go1.20.
+		re: regexp.MustCompile(`building_Go_requires_Go_(\d+)_(\d+)(?:_(\d+))?_or_later|requires Go (\d+\.\d+(?:\.\d+)?) or later`),
+		explain: func(m []string, tag, bootstrap string) error {
+			min := "go" + m[4]
+			if m[4] == "" {
+				min = "go" + m[1] + "." + m[2]
+				if m[3] != "" {
+					min += "." + m[3]
+				}
+			}
+			const fix = "run `groot bootstrap upgrade`, or pick a newer one with `groot bootstrap use`"
+			if bootstrap == "" {
+				return fmt.Errorf("the bootstrap toolchain is too old to build %s, which requires %s or later; %s", tag, min, fix)
+			}
+			return fmt.Errorf("your bootstrap %s is too old to build %s, which requires %s or later; %s", bootstrap, tag, min, fix)
+		},
+	},
+	{
+		re: regexp.MustCompile(`C compiler "?([^"\s]+)"? not found|cannot invoke C compiler|exec: "(gcc|clang|cc)": executable file not found`),
+		explain: func(m []string, _, _ string) error {
+			cc := m[1]
+			if cc == "" {
+				cc = m[2]
+			}
+			return &noCToolchainError{cc: cc}
+		},
+	},
+	{
+		re: regexp.MustCompile(`(?i)no space left on device|disk quota exceeded`),
+		explain: func(_ []string, tag, _ string) error {
+			return fmt.Errorf("the build of %s ran out of disk space; free some, such as with `groot clean --all` or `groot prune`, and build again", tag)
+		},
+	},
+	{
+		// The kernel's OOM killer leaves the compiler "signal: killed".
+		re: regexp.MustCompile(`(?i)out of memory|cannot allocate memory|virtual memory exhausted|signal: killed`),
+		explain: func(_ []string, tag, _ string) error {
+			return fmt.Errorf("the build of %s ran out of memory and a compiler was killed; close other programs or add swap, or build fewer packages at once with --env GOMAXPROCS=1", tag)
+		},
+	},
+}
+
+// diagnoseBuild returns the explanation of a failed build of tag with
+// the bootstrap of version bootstrap from the end of its output, or
+// nil if the failure isn't a known one.
+func diagnoseBuild(output, tag, bootstrap string) error {
+	for _, p := range buildProblems {
+		if m := p.re.FindStringSubmatch(output); m != nil {
+			return p.explain(m, tag, strings.TrimSpace(bootstrap))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiagnoseBuild matches the captured make.bash failures in
+// testdata/build against buildProblems.
+func TestDiagnoseBuild(t *testing.T) {
+	tests := []struct {
+		log       string
+		tag       string
+		bootstrap string
+		want      string // substring of the explanation, empty if unknown
+		noCC      string // the compiler of a noCToolchainError
+	}{
+		{log: "bootstrap-too-old.log", tag: "go1.22.0", bootstrap: "go1.19.13", want: "your bootstrap go1.19.13 is too old to build go1.22.0, which requires go1.20.6 or later"},
+		{log: "bootstrap-too-old-go117.log", tag: "go1.20", want: "the bootstrap toolchain is too old to build go1.20, which requires go1.17.13 or later; run `groot bootstrap upgrade`"},
+		{log: "bootstrap-requires.log", tag: "go1.24.0", bootstrap: "go1.20.5\n", want: "your bootstrap go1.20.5 is too old to build go1.24.0, which requires go1.22.6 or later"},
+		{log: "no-cc.log", tag: "go1.23.0", noCC: "gcc"},
+		{log: "disk-full.log", tag: "go1.23.0", want: "the build of go1.23.0 ran out of disk space"},
+		{log: "oom.log", tag: "go1.23.0", want: "the build of go1.23.0 ran out of memory"},
+		{log: "compile-error.log", tag: "go1.23.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.log, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "build", tt.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = diagnoseBuild(string(data), tt.tag, tt.bootstrap)
+
+			var ccErr *noCToolchainError
+			switch {
+			case tt.noCC != "":
+				if !errors.As(err, &ccErr) || ccErr.cc != tt.noCC {
+					t.Errorf("diagnoseBuild = %v, want a noCToolchainError for %s", err, tt.noCC)
+				}
+			case tt.want == "":
+				if err != nil {
+					t.Errorf("diagnoseBuild = %v, want nil for an unknown failure", err)
+				}
+			case err == nil || !strings.Contains(err.Error(), tt.want):
+				t.Errorf("diagnoseBuild = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envrcFile is direnv's per-directory configuration.
+const envrcFile = ".envrc"
+
+// direnvHook is the use_groot function for direnvrc. `use groot spec`
+// resolves spec with `groot which`, so it accepts the same partial
+// versions as activate.
+const direnvHook = `use_groot() {
+  local gobin
+  if ! gobin=$(%s which "$1"); then
+    log_error "groot: $1 isn't installed; run 'groot add $1'"
+    return 1
+  fi
+  local goroot=${gobin%%/bin/go*}
+  export GOROOT=$goroot
+  PATH_add "$goroot/bin"
+}
+`
+
+// direnvCommands are the subcommands of direnv.
+var direnvCommands = []command{
+	{name: "direnv hook", setup: noFlags(direnvPrintHook)},
+	{name: "direnv init", usage: []string{"[version]"}, setup: noFlags(direnvInit)},
+}
+
+func direnv(g *groot, args []string) error {
+	return g.runSubcommand(direnvCommands, args)
+}
+
+// direnvPrintHook prints the use_groot function, to be added
+// to ~/.config/direnv/direnvrc.
+func direnvPrintHook(_ *groot, _ []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "groot"
+	}
+	fmt.Printf(direnvHook, strconv.Quote(exe))
+	return nil
+}
+
+// direnvInit adds `use groot spec` to the .envrc of the current
+// directory, replacing an existing use groot line but otherwise
+// keeping its content.
+func direnvInit(g *groot, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	var spec string
+	if len(args) == 1 {
+		spec = args[0]
+		_, err := g.resolveInstalled(spec, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		active, err := g.activeVersion()
+		if err != nil {
+			return err
+		}
+		if active == "" {
+			return fmt.Errorf("no version is active; give the version to use")
+		}
+		spec = active
+	}
+	use := "use groot " + spec
+
+	data, err := ioutil.ReadFile(envrcFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	replaced := false
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "use" && fields[1] == "groot" {
+			lines[i] = use
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, use)
+	}
+
+	err = ioutil.WriteFile(envrcFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %q to %s; run `direnv allow` to load it\n", use, envrcFile)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// doctorCheck is a single diagnostic run by doctor. run returns
+// a short description of what was found, or an error if the
+// check failed.
+type doctorCheck struct {
+	name string
+	run  func(g *groot) (string, error)
+}
+
+var doctorChecks = []doctorCheck{
+	{"CA configuration", checkCAConfig},
+	{"TLS connection", checkTLS},
+	{"Test results", checkTestResults},
+	{"Worktrees", checkWorktrees},
+	{"Active version", checkActiveLink},
+	{"Local version", checkLocalVersion},
+}
+
+func doctor(g *groot, _ []string) error {
+	if g.runDoctor(os.Stdout) > 0 {
+		return exitStatus(exitError)
+	}
+	return nil
+}
+
+// runDoctor runs doctorChecks, writing their results to w, and returns
+// the number that failed.
+func (g *groot) runDoctor(w io.Writer) int {
+	failed := 0
+	for _, check := range doctorChecks {
+		result, err := check.run(g)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Fprintf(w, "[ ok ] %s: %s\n", check.name, result)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(w, "%d check(s) failed.\n", failed)
+	}
+	return failed
+}
+
+func checkCAConfig(g *groot) (string, error) {
+	bundle, source := g.caBundle()
+	if bundle == "" {
+		return "system roots", nil
+	}
+
+	_, n, err := loadCABundle(bundle)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("system roots + %d certificate(s) from %s (%s)", n, bundle, source), nil
+}
+
+func checkTLS(g *groot) (string, error) {
+	client, err := g.httpClient()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("HEAD", downloadURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return fmt.Sprintf("connected to %s", resp.Request.URL.Host), nil
+}
+
+func checkTestResults(g *groot) (string, error) {
+	names, err := g.installed()
+	if os.IsNotExist(err) {
+		return "no versions installed", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var results []string
+	for _, name := range names {
+		result, err := g.readTestResult(name)
+		switch {
+		case err != nil:
+			results = append(results, fmt.Sprintf("%s: %v", name, err))
+		case result == nil:
+			results = append(results, name+" untested")
+		case result.Passed:
+			results = append(results, fmt.Sprintf("%s passed %s (%s)", name, result.what(), result.Started.Format("2006-01-02")))
+		default:
+			results = append(results, fmt.Sprintf("%s FAILED %s (%s)", name, result.what(), result.Started.Format("2006-01-02")))
+		}
+	}
+	if len(results) == 0 {
+		return "no versions installed", nil
+	}
+	return strings.Join(results, ", "), nil
+}
+
+// checkLocalVersion fails if the nearest .go-version or .tool-versions
+// requests another version than the active one.
+func checkLocalVersion(g *groot) (string, error) {
+	if !g.initialized() {
+		return "groot is not initialized", nil
+	}
+	p, local, err := g.localVersion()
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "no " + goVersionFile + " or " + toolVersionsFile + " in effect", nil
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return "", err
+	}
+	if local != active {
+		return "", localMismatchError(p, local, active)
+	}
+	return fmt.Sprintf("%s from %s is active", local, p), nil
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	binaryRelease = "1.9.2"
+	downloadURL   = "https://redirector.gvt1.com/edgedl/go/"
+	checksumURL   = "https://dl.google.com/go/"
+)
+
+// distToRelease overrides binaryRelease for platforms that
+// have no binary release of that version.
+var distToRelease = map[string]string{
+	"darwin/arm64": "1.16.15",
+}
+
+// distToArchiveArch maps GOARCH to the architecture
+// used in release archive names when they differ.
+var distToArchiveArch = map[string]string{
+	"linux/arm":   "armv6l",
+	"freebsd/arm": "armv6l",
+}
+
+var distToHash = map[string]string{
+	"android/386":     "",
+	"android/amd64":   "",
+	"android/arm":     "",
+	"android/arm64":   "",
+	"darwin/386":      "",
+	"darwin/amd64":    "73fd5840d55f5566d8db6c0ffdd187577e8ebe650c783f68bd27cbf95bde6743",
+	"darwin/arm":      "",
+	"darwin/arm64":    "",
+	"dragonfly/amd64": "",
+	"freebsd/386":     "809dcb0a8457c8d0abf954f20311a1ee353486d0ae3f921e9478189721d37677",
+	"freebsd/amd64":   "8be985c3e251c8e007fa6ecd0189bc53e65cc519f4464ddf19fa11f7ed251134",
+	"freebsd/arm":     "",
+	"linux/386":       "574b2c4b1a248e58ef7d1f825beda15429610a2316d9cbd3096d8d3fa8c0bc1a",
+	"linux/amd64":     "de874549d9a8d8d8062be05808509c09a88a248e77ec14eb77453530829ac02b",
+	"linux/arm":       "",
+	"linux/arm64":     "0016ac65ad8340c84f51bc11dbb24ee8265b0a4597dbfdf8d91776fc187456fa",
+	"linux/mips":      "",
+	"linux/mips64":    "",
+	"linux/mips64le":  "",
+	"linux/mipsle":    "",
+	"linux/ppc64":     "",
+	"linux/ppc64le":   "adb440b2b6ae9e448c253a20836d8e8aa4236f731d87717d9c7b241998dc7f9d",
+	"linux/s390x":     "a7137b4fbdec126823a12a4b696eeee2f04ec616e9fb8a54654c51d5884c1345",
+	"nacl/386":        "",
+	"nacl/amd64p32":   "",
+	"nacl/arm":        "",
+	"netbsd/386":      "",
+	"netbsd/amd64":    "",
+	"netbsd/arm":      "",
+	"openbsd/386":     "",
+	"openbsd/amd64":   "",
+	"openbsd/arm":     "",
+	"plan9/386":       "",
+	"plan9/amd64":     "",
+	"plan9/arm":       "",
+	"solaris/amd64":   "",
+	"windows/386":     "",
+	"windows/amd64":   "",
+}
+
+// downloadBinaryRelease installs the bootstrap toolchain into dir,
+// returning the version installed.
+func (g *groot) downloadBinaryRelease(dir string) (string, error) {
+	goos, goarch := g.platform()
+	dist := goos + "/" + goarch
+
+	if g.config.VersionSource == sourceGoDev && g.mirrorFile == "" {
+		f, err := g.bootstrapArchive(goos, goarch)
+		if err == nil {
+			return strings.TrimPrefix(f.Version, "go"), g.downloadAndExtract(downloadURL+f.Filename, f.SHA256, dir, nil)
+		}
+		warnln("Choosing bootstrap from release metadata:", err)
+	}
+
+	hash, ok := distToHash[dist]
+	if !ok {
+		return "", fmt.Errorf("Unknown OS/Architecture: %s", dist)
+	}
+	if goarch != runtime.GOARCH || g.mirrorFile != "" {
+		// distToHash describes the host architecture, and is
+		// replaced by a mirror checksum file.
+		hash = ""
+	}
+
+	release := binaryRelease
+	if r, ok := distToRelease[dist]; ok {
+		release = r
+		hash = ""
+	}
+
+	filename := archiveName("go"+release, goos, goarch)
+	if hash == "" {
+		var err error
+		hash, err = g.lookupChecksum(filename)
+		if err != nil {
+			return "", fmt.Errorf("Unsupported OS/Architecture: %s: %v", dist, err)
+		}
+	}
+
+	return release, g.downloadAndExtract(downloadURL+filename, hash, dir, nil)
+}
+
+// installBinary installs the official binary release of tag.
+func (g *groot) installBinary(tag string) error {
+	goos, goarch := g.platform()
+	filename := archiveName(tag, goos, goarch)
+	hash, err := g.lookupChecksum(filename)
+	if err != nil {
+		return fmt.Errorf("no binary release of %s for %s/%s: %v", tag, goos, goarch, err)
+	}
+
+	url := downloadURL + filename
+	return g.installExtracted(tag, &provenance{Source: sourceBinary, URL: url}, func(dir string, filter *archiveFilter) error {
+		return g.downloadAndExtract(url, hash, dir, filter)
+	})
+}
+
+// installArchive installs tag from a local copy of its binary release
+// archive. It's checked against the published checksum when that can
+// be looked up.
+func (g *groot) installArchive(tag, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash, err := g.lookupChecksum(filepath.Base(archive))
+	if err != nil {
+		warnf("Installing %s unverified: %v", archive, err)
+	} else {
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		if err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+			return fmt.Errorf("%s does not match published SHA256 hash\nexpected: %s\ngot:      %s", archive, hash, got)
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+
+	return g.installExtracted(tag, archiveProvenance(archive), func(dir string, filter *archiveFilter) error {
+		return extractArchive(f, archive, "", dir, !g.config.SkipExtractCheck, filter)
+	})
+}
+
+// archiveTag returns the version of a release archive from its
+// name, such as go1.21.0 for go1.21.0.linux-amd64.tar.gz.
+func archiveTag(archive string) (string, bool) {
+	name := filepath.Base(archive)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", false
+	}
+	_, ok := parseVersion(name[:i])
+	return name[:i], ok
+}
+
+// inspectFrom lists the entries of a local archive for add --inspect,
+// failing if extracting it would be rejected.
+func inspectFrom(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	problems, err := inspectArchive(f, w)
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return err
+	}
+	if problems > 0 {
+		fmt.Printf("%d entries would be rejected by extraction\n", problems)
+		return exitStatus(exitError)
+	}
+	fmt.Println("No problems found")
+	return nil
+}
+
+// installExtracted installs the binary release tag, which extract
+// writes to its version directory, skipping the entries filter
+// excludes. With add --minimal, filter leaves out minimalExclude.
+func (g *groot) installExtracted(tag string, origin *provenance, extract func(dir string, filter *archiveFilter) error) error {
+	dir := g.versionDir(tag)
+	_, err := os.Stat(longPath(dir))
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	var filter *archiveFilter
+	if g.minimalBinary {
+		warnf("Leaving %s out of %s: the std tests can't run on it, so it's no use for working on Go itself\n", strings.Join(minimalExclude, "/, ")+"/", tag)
+		filter = &archiveFilter{exclude: minimalExclude}
+	}
+
+	_, goarch := g.platform()
+	err = extract(dir, filter)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	if filter != nil {
+		infof("Minimal install saved %s\n", formatSize(filter.skipped))
+	}
+
+	err = g.writeManifest(tag)
+	if err != nil {
+		return err
+	}
+
+	inst := installState{Tag: tag, Kind: kindBinary, Provenance: origin, Minimal: filter != nil}
+	// Binaries for another architecture may not run here.
+	if goarch == runtime.GOARCH {
+		err = g.smokeTest(tag, inst)
+		if err != nil {
+			return g.smokeFailure(tag, inst, err)
+		}
+	}
+	return g.recordInstall(tag, inst)
+}
+
+// platform returns the OS and architecture of binaries to download.
+func (g *groot) platform() (string, string) {
+	if g.arch != "" {
+		return runtime.GOOS, g.arch
+	}
+	return runtime.GOOS, runtime.GOARCH
+}
+
+// archiveName returns the file name of the binary release of
+// version (e.g. "go1.9.2") for goos/goarch.
+func archiveName(version, goos, goarch string) string {
+	arch := goarch
+	if a, ok := distToArchiveArch[goos+"/"+goarch]; ok {
+		arch = a
+	}
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("%s.%s-%s%s", version, goos, arch, ext)
+}
+
+// lookupChecksum returns the published SHA256 hash of filename,
+// preferring the one recorded in the checksums file. With a mirror
+// checksum file only that is consulted.
+func (g *groot) lookupChecksum(filename string) (string, error) {
+	if g.mirrorFile != "" {
+		return g.mirrorChecksum(filename)
+	}
+
+	hash, err := g.localChecksum(filename)
+	if err != nil || hash != "" {
+		return hash, err
+	}
+
+	hash, err = g.releaseChecksum(filename)
+	if err != nil {
+		warnln("Looking up checksum:", err)
+		hash, err = g.fetchChecksum(filename)
+	}
+	return hash, err
+}
+
+// downloadAndExtract downloads the archive at url, verifies it
+// against the SHA256 hash, and extracts it into dir, skipping the
+// entries filter excludes.
+func (g *groot) downloadAndExtract(url, hash, dir string, filter *archiveFilter) error {
+	dl, err := g.downloadVerified(url, hash, nil)
+	if err != nil {
+		return err
+	}
+	defer dl.remove()
+
+	return extractArchive(dl.f, dl.name, dl.contentType, dir, !g.config.SkipExtractCheck, filter)
+}
+
+// verifiedDownload is an archive downloaded to a temporary file
+// and checked against its published hash.
+type verifiedDownload struct {
+	f           *os.File
+	name        string // base name of the final URL
+	contentType string
+}
+
+func (dl *verifiedDownload) remove() {
+	dl.f.Close()
+	os.Remove(dl.f.Name())
+}
+
+// downloadVerified downloads url to a temporary file and checks it
+// against hash. If p is non-nil the download is added to its totals.
+func (g *groot) downloadVerified(url, hash string, p *downloadProgress) (*verifiedDownload, error) {
+	resp, err := g.get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading binary release: %v", err)
+	}
+	defer resp.Body.Close()
+
+	f, err := ioutil.TempFile("", "groot-download-")
+	if err != nil {
+		return nil, err
+	}
+	dl := &verifiedDownload{
+		f:           f,
+		name:        path.Base(resp.Request.URL.Path),
+		contentType: resp.Header.Get("Content-Type"),
+	}
+
+	// Look at the start of the body before hashing it, an error page
+	// would only be reported as a hash mismatch.
+	br := bufio.NewReader(resp.Body)
+	head, _ := br.Peek(512)
+	err = checkArchiveResponse(url, dl.contentType, head)
+	if err != nil {
+		dl.remove()
+		return nil, err
+	}
+
+	body := g.limitRate(br)
+	if p != nil {
+		p.start(resp.ContentLength)
+		body = io.TeeReader(body, p)
+	}
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hasher), body)
+	if err != nil {
+		dl.remove()
+		return nil, fmt.Errorf("downloading binary release: %v", err)
+	}
+
+	filename := path.Base(url)
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != hash {
+		dl.remove()
+		err := fmt.Errorf("downloaded binary release does not match published SHA256 hash\nexpected: %s\ngot:      %s", hash, got)
+		if local, _ := g.localChecksum(filename); local == hash {
+			err = fmt.Errorf("%v\nThe expected hash is from %s; remove its entry for %s if it's wrong.", err, g.checksumsPath(), filename)
+		}
+		return nil, err
+	}
+
+	_, err = g.recordChecksums(map[string]string{filename: hash})
+	if err != nil {
+		warnln("Recording checksum:", err)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		dl.remove()
+		return nil, err
+	}
+	return dl, nil
+}
+
+func verifyDownload(fs *flag.FlagSet, g *groot) func(args []string) error {
+	fs.StringVar(&g.arch, "arch", "", "check the binary release for `GOARCH` instead of the host architecture")
+	fs.StringVar(&g.mirrorFile, "mirror-file", g.mirrorFile, "check against the checksums listed in `file`")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		tag := normalizeTag(args[0])
+
+		goos, goarch := g.platform()
+		filename := archiveName(tag, goos, goarch)
+		hash, err := g.lookupChecksum(filename)
+		if err != nil {
+			return fmt.Errorf("no binary release of %s for %s/%s: %v", tag, goos, goarch, err)
+		}
+
+		url := downloadURL + filename
+		infoln("Downloading", url)
+		got, err := g.downloadHash(url)
+		if err != nil {
+			return err
+		}
+		if got != hash {
+			fmt.Printf("MISMATCH %s\nexpected: %s\ngot:      %s\n", filename, hash, got)
+			return exitStatus(exitMismatch)
+		}
+		fmt.Printf("OK %s %s\n", filename, got)
+		return nil
+	}
+}
+
+// downloadHash downloads the archive at url without keeping it and
+// returns its SHA256 hash.
+func (g *groot) downloadHash(url string) (string, error) {
+	resp, err := g.get(url)
+	if err != nil {
+		return "", fmt.Errorf("downloading binary release: %v", err)
+	}
+	defer resp.Body.Close()
+
+	br := bufio.NewReader(resp.Body)
+	head, _ := br.Peek(512)
+	err = checkArchiveResponse(url, resp.Header.Get("Content-Type"), head)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, g.limitRate(br))
+	if err != nil {
+		return "", fmt.Errorf("downloading binary release: %v", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// fetchChecksum retrieves the published SHA256 hash of a release archive.
+func (g *groot) fetchChecksum(filename string) (string, error) {
+	resp, err := g.get(checksumURL + filename + ".sha256")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	hash := strings.TrimSpace(string(body))
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("fetching checksum for %s: invalid checksum %q", filename, hash)
+	}
+	return hash, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func execCmd(g *groot, args []string) error {
+	name, err := g.resolveInstalled(args[0], false)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := g.versionCommand(name, args[1], args[2:]...)
+	if err != nil {
+		return err
+	}
+	return runAttached(cmd)
+}
+
+// runCmd runs the go command of the active version, regardless of
+// whether PATH has been set up. If the first argument is an installed
+// version, the program given by the next is run with it instead.
+func runCmd(g *groot, args []string) error {
+	if len(args) > 0 && args[0] == "-q" {
+		verbosity = levelError
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		return errUsage
+	}
+	if len(args) > 1 && g.isInstalledSpec(args[0]) {
+		return g.runProgram(args[0], args[1:])
+	}
+
+	name, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("no version is active; use `groot activate` first")
+	}
+
+	cmd, err := g.versionCommand(name, "go", args...)
+	if err != nil {
+		return err
+	}
+	return runAttached(cmd)
+}
+
+// isInstalledSpec reports whether spec refers to an installed version
+// rather than being a go command.
+func (g *groot) isInstalledSpec(spec string) bool {
+	if _, ok := parseVersion(normalizeTag(spec)); !ok && !g.exists(spec) {
+		return false
+	}
+	_, err := g.resolveInstalled(spec, false)
+	return err == nil
+}
+
+// runProgram builds the files or package at the start of args with the
+// installed version spec and runs it with the rest of args. Unlike
+// go run, the program's exit code is passed on.
+func (g *groot) runProgram(spec string, args []string) error {
+	name, err := g.resolveInstalled(spec, false)
+	if err != nil {
+		return err
+	}
+
+	// Either .go files or a single package.
+	n := 1
+	if strings.HasSuffix(args[0], ".go") {
+		for n < len(args) && strings.HasSuffix(args[n], ".go") {
+			n++
+		}
+	}
+	targets, progArgs := args[:n], args[n:]
+	if len(progArgs) > 0 && progArgs[0] == "--" {
+		progArgs = progArgs[1:]
+	}
+
+	tmp, err := ioutil.TempDir("", "groot-run-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	prog := filepath.Join(tmp, exeName("main"))
+
+	if verbosity >= levelInfo {
+		fmt.Fprintf(os.Stderr, "groot: running with %s (%s)\n", name, g.versionDir(name))
+	}
+	build, err := g.versionCommand(name, "go", append([]string{"build", "-o", prog}, targets...)...)
+	if err != nil {
+		return err
+	}
+	if err := runAttached(build); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(prog, progArgs...)
+	cmd.Env = build.Env
+	return runAttached(cmd)
+}
+
+// runAttached runs cmd with groot's stdio. If it exits unsuccessfully,
+// the error is the exitStatus passing its exit code on.
+func runAttached(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if code, ok := exitCode(err); ok {
+		return exitWith(code)
+	}
+	return err
+}
+
+// versionCommand returns a command that runs with the environment
+// of the installed version name, restoring it first if it's an
+// archived snapshot.
+func (g *groot) versionCommand(name, command string, args ...string) (*exec.Cmd, error) {
+	err := g.restoreSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := g.versionEnv(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(command, args...)
+	cmd.Env = env
+
+	// Prefer the version's own tools over exec.Command's lookup
+	// in groot's PATH.
+	if filepath.Base(command) == command {
+		tool := filepath.Join(g.versionDir(name), "bin", exeName(command))
+		if _, err := os.Stat(tool); err == nil {
+			cmd.Path = tool
+		}
+	}
+	return cmd, nil
+}
+
+// exitCode returns the exit code of a command that ran
+// and failed with err.
+func exitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// versionEnv returns groot's environment with GOROOT and PATH set
+// to use the installed version name.
+func (g *groot) versionEnv(name string) ([]string, error) {
+	dir := g.versionDir(name)
+	bin := filepath.Join(dir, "bin")
+	if _, err := os.Stat(bin); err != nil {
+		return nil, err
+	}
+
+	env := []string{"GOROOT=" + dir}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOROOT=") && !strings.HasPrefix(strings.ToUpper(kv), "PATH=") {
+			env = append(env, kv)
+		}
+	}
+	// The active version is left off PATH, so tools the command runs
+	// can't resolve to it instead.
+	return append(env, "PATH="+g.versionPath(dir)), nil
+}
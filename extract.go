@@ -0,0 +1,481 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// extractArchive extracts the release archive f into dir. The format
+// is determined by the file name, falling back to the content type.
+// With check, the extracted entries are compared with the archive
+// afterwards, catching truncated writes the download hash can't.
+// Entries filter excludes are skipped if it's non-nil.
+func extractArchive(f *os.File, name, contentType, dir string, check bool, filter *archiveFilter) error {
+	var entries *extractedEntries
+	if check {
+		entries = &extractedEntries{files: make(map[string]int64)}
+	}
+
+	var err error
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = extractZipFile(f, dir, entries, filter)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = readTarGz(f, dir, nil, entries, filter)
+	case contentType == "application/zip", contentType == "application/x-zip-compressed":
+		err = extractZipFile(f, dir, entries, filter)
+	case contentType == "application/x-gzip", contentType == "application/gzip", contentType == "application/x-tar", contentType == "application/octet-stream":
+		err = readTarGz(f, dir, nil, entries, filter)
+	default:
+		return fmt.Errorf("unable to determine archive format of %s (Content-Type %q)", name, contentType)
+	}
+	if err != nil || entries == nil {
+		return err
+	}
+	return entries.check(name, dir)
+}
+
+// archiveContentTypes are the content types servers send for release
+// archives. An empty Content-Type is accepted too.
+var archiveContentTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-gtar":           true,
+	"application/x-tar":            true,
+	"application/x-compressed-tar": true,
+	"application/zip":              true,
+	"application/x-zip-compressed": true,
+	"application/octet-stream":     true,
+	"binary/octet-stream":          true,
+}
+
+// checkArchiveResponse returns an error if a download from url with
+// contentType, starting with head, is evidently not an archive, such
+// as the HTML error page some proxies send with a 200 status.
+func checkArchiveResponse(url, contentType string, head []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	sniffed := http.DetectContentType(head)
+	if (contentType == "" || archiveContentTypes[mediaType]) && !strings.HasPrefix(sniffed, "text/") {
+		return nil
+	}
+
+	const max = 200
+	if len(head) > max {
+		head = head[:max]
+	}
+	start := strings.Map(func(r rune) rune {
+		if r != '\n' && !unicode.IsPrint(r) {
+			return '.'
+		}
+		return r
+	}, string(head))
+	return fmt.Errorf("%s returned Content-Type %q rather than an archive; the mirror or a proxy may have sent an error page. The response starts with:\n%s", url, contentType, start)
+}
+
+// extractedEntries records what extraction wrote.
+type extractedEntries struct {
+	dirs  []string
+	files map[string]int64 // sizes
+}
+
+func (e *extractedEntries) dir(name string) {
+	if e != nil {
+		e.dirs = append(e.dirs, name)
+	}
+}
+
+func (e *extractedEntries) file(name string, size int64) {
+	if e != nil {
+		e.files[name] = size
+	}
+}
+
+// archiveFilter leaves the entries under the top-level directories
+// exclude out of an extraction, counting the bytes of the files it
+// skips.
+type archiveFilter struct {
+	exclude []string
+	skipped int64
+}
+
+// skip reports whether the archive entry name of size bytes is left
+// out, recording its size if so.
+func (f *archiveFilter) skip(name string, size int64) bool {
+	if f == nil {
+		return false
+	}
+	name = strings.TrimPrefix(path.Clean("/"+strings.Replace(name, "\\", "/", -1)), "/go/")
+	for _, d := range f.exclude {
+		if name == d || strings.HasPrefix(name, d+"/") {
+			f.skipped += size
+			return true
+		}
+	}
+	return false
+}
+
+// check confirms that the recorded entries are on disk, files with
+// their full size, and that dir has a go command that can be run.
+func (e *extractedEntries) check(archive, dir string) error {
+	total := len(e.dirs) + len(e.files)
+	var missing int
+	var first error
+	fail := func(err error) {
+		missing++
+		if first == nil {
+			first = err
+		}
+	}
+
+	for _, name := range e.dirs {
+		finfo, err := os.Stat(longPath(name))
+		if err == nil && !finfo.IsDir() {
+			err = fmt.Errorf("%s is not a directory", name)
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	for name, size := range e.files {
+		finfo, err := os.Stat(longPath(name))
+		if err == nil && finfo.Size() != size {
+			err = fmt.Errorf("%s is %d bytes, expected %d", name, finfo.Size(), size)
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("extracting %s: %d of %d entries are missing or incomplete, such as: %v", archive, missing, total, first)
+	}
+
+	gobin := filepath.Join(dir, "bin", exeName("go"))
+	finfo, err := os.Stat(gobin)
+	if err != nil {
+		return fmt.Errorf("extracting %s: no go command: %v", archive, err)
+	}
+	if runtime.GOOS != "windows" && finfo.Mode()&0100 == 0 {
+		return fmt.Errorf("extracting %s: %s is not executable", archive, gobin)
+	}
+	return nil
+}
+
+// archivePath returns where an archive entry is extracted to. The
+// top-level "go" directory is stripped and entries that would be
+// written outside of dir are rejected.
+func archivePath(dir, name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	clean := path.Clean("/" + name)
+	if clean != "/"+path.Clean(name) {
+		return "", fmt.Errorf("archive entry %q is outside of the extraction directory", name)
+	}
+
+	clean = strings.TrimPrefix(clean, "/go")
+	if clean != "" && clean[0] != '/' {
+		return "", fmt.Errorf("archive entry %q is not in the top-level go directory", name)
+	}
+
+	if runtime.GOOS == "windows" {
+		err := checkWindowsName(name)
+		if err != nil {
+			return "", fmt.Errorf("archive entry %v", err)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// dirTimes records directory modification times, which are applied
+// once extraction is complete since extracting the directory's
+// contents would otherwise update them.
+type dirTimes []dirTime
+
+type dirTime struct {
+	name  string
+	mtime time.Time
+}
+
+func (d *dirTimes) add(name string, mtime time.Time) {
+	*d = append(*d, dirTime{name: name, mtime: mtime})
+}
+
+func (d dirTimes) apply() error {
+	// Deepest first, so parents aren't modified afterwards.
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i].mtime.IsZero() {
+			continue
+		}
+		err := os.Chtimes(d[i].name, d[i].mtime, d[i].mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(r io.Reader, dir string) error {
+	return readTarGz(r, dir, nil, nil, nil)
+}
+
+// archiveInspection lists archive entries instead of extracting
+// them, counting the ones extraction would reject.
+type archiveInspection struct {
+	w        io.Writer
+	problems int
+}
+
+// entry reports an entry of kind "dir", "file", or another type, and
+// the error extraction would fail with because of it, if any.
+func (in *archiveInspection) entry(name, kind string, size int64, mode os.FileMode, err error) {
+	if err == nil && kind != "dir" && kind != "file" {
+		err = fmt.Errorf("unexpected %s entry", kind)
+	}
+	problem := ""
+	if err != nil {
+		in.problems++
+		problem = "! " + err.Error()
+	}
+	fmt.Fprintf(in.w, "%s\t%s\t%d\t%s\t%s\n", name, kind, size, mode, problem)
+}
+
+// tarKind names the type of a tar entry.
+func tarKind(typeflag byte) string {
+	switch typeflag {
+	case tar.TypeDir:
+		return "dir"
+	case tar.TypeReg, tar.TypeRegA:
+		return "file"
+	case tar.TypeSymlink:
+		return "symlink"
+	case tar.TypeLink:
+		return "hardlink"
+	case tar.TypeChar, tar.TypeBlock:
+		return "device"
+	case tar.TypeFifo:
+		return "fifo"
+	}
+	return fmt.Sprintf("type %q", typeflag)
+}
+
+// readTarGz extracts the tar.gz archive r into dir, or with inspect,
+// only lists its entries. Extracted entries are added to record if
+// it's non-nil, and the ones filter excludes are skipped.
+func readTarGz(r io.Reader, dir string, inspect *archiveInspection, record *extractedEntries, filter *archiveFilter) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+
+	var dirs dirTimes
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := archivePath(dir, hdr.Name)
+		if inspect != nil {
+			inspect.entry(hdr.Name, tarKind(hdr.Typeflag), hdr.Size, hdr.FileInfo().Mode(), err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if filter.skip(hdr.Name, hdr.Size) {
+			continue
+		}
+
+		// Perm drops setuid, setgid, and sticky bits.
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			debugf("Directory: %s\n", name)
+			err := mkdirExtracted(longPath(name), mode)
+			if err != nil {
+				return err
+			}
+			dirs.add(longPath(name), hdr.ModTime)
+			record.dir(name)
+		case tar.TypeReg:
+			debugf("File: %s\n", name)
+			err := writeFile(longPath(name), tr, mode, hdr.ModTime)
+			if err != nil {
+				return err
+			}
+			record.file(name, hdr.Size)
+		default:
+			return fmt.Errorf("Unexpected type %c", hdr.Typeflag)
+		}
+	}
+
+	return dirs.apply()
+}
+
+func extractZipFile(f *os.File, dir string, record *extractedEntries, filter *archiveFilter) error {
+	finfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return readZip(f, finfo.Size(), dir, nil, record, filter)
+}
+
+// readZip extracts the zip archive r into dir, or with inspect,
+// only lists its entries. Extracted entries are added to record if
+// it's non-nil, and the ones filter excludes are skipped.
+func readZip(r io.ReaderAt, size int64, dir string, inspect *archiveInspection, record *extractedEntries, filter *archiveFilter) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	var dirs dirTimes
+
+	for _, zf := range zr.File {
+		name, err := archivePath(dir, zf.Name)
+		// Mode is derived from the external attributes.
+		mode := zf.Mode()
+		if inspect != nil {
+			kind := "file"
+			switch {
+			case mode.IsDir():
+				kind = "dir"
+			case mode&os.ModeSymlink != 0:
+				kind = "symlink"
+			case !mode.IsRegular():
+				kind = "special"
+			}
+			inspect.entry(zf.Name, kind, int64(zf.UncompressedSize64), mode, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if filter.skip(zf.Name, int64(zf.UncompressedSize64)) {
+			continue
+		}
+
+		switch {
+		case mode.IsDir():
+			debugf("Directory: %s\n", name)
+			err := mkdirExtracted(longPath(name), mode.Perm())
+			if err != nil {
+				return err
+			}
+			dirs.add(longPath(name), zf.Modified)
+			record.dir(name)
+		case mode.IsRegular():
+			debugf("File: %s\n", name)
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = writeFile(longPath(name), rc, mode.Perm(), zf.Modified)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			record.file(name, int64(zf.UncompressedSize64))
+		default:
+			return fmt.Errorf("Unexpected mode %s for %s", mode, zf.Name)
+		}
+	}
+
+	return dirs.apply()
+}
+
+// inspectArchive lists the entries of the release archive f to w
+// without extracting it, returning the number of entries extraction
+// would reject.
+func inspectArchive(f *os.File, w io.Writer) (int, error) {
+	inspect := &archiveInspection{w: w}
+	var err error
+	if strings.HasSuffix(f.Name(), ".zip") {
+		var finfo os.FileInfo
+		finfo, err = f.Stat()
+		if err == nil {
+			err = readZip(f, finfo.Size(), ".", inspect, nil, nil)
+		}
+	} else {
+		err = readTarGz(f, ".", inspect, nil, nil)
+	}
+	return inspect.problems, err
+}
+
+// extractedDirMode is the mode of parent directories that don't
+// have an entry of their own in an archive, or whose entry comes
+// after their contents.
+const extractedDirMode = 0755
+
+// mkdirExtracted creates the directory name from an archive entry
+// with mode, which is applied even if the directory already exists.
+// The owner can always traverse and write to the directory, as
+// archives with directories missing those bits would otherwise leave
+// their contents inaccessible.
+func mkdirExtracted(name string, mode os.FileMode) error {
+	mode = mode.Perm() | 0700
+	err := os.MkdirAll(name, mode)
+	if err != nil {
+		return err
+	}
+	// MkdirAll doesn't change an existing directory, which may
+	// have been created as the parent of an earlier entry.
+	return os.Chmod(name, mode)
+}
+
+// writeFile writes the contents of r to name, then applies mode and
+// mtime. An existing read-only file, such as one left by an earlier
+// interrupted extraction, is made writable first. Missing parent
+// directories are created.
+func writeFile(name string, r io.Reader, mode os.FileMode, mtime time.Time) error {
+	err := os.MkdirAll(filepath.Dir(name), extractedDirMode)
+	if err != nil {
+		return err
+	}
+
+	if finfo, err := os.Lstat(name); err == nil && finfo.Mode().IsRegular() && finfo.Mode().Perm()&0200 == 0 {
+		err := os.Chmod(name, finfo.Mode().Perm()|0200)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode|0200)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// OpenFile only applies mode to new files.
+	err = os.Chmod(name, mode)
+	if err != nil {
+		return err
+	}
+	if mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(name, mtime, mtime)
+}
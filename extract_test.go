@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveEntry is an entry of a test archive, a directory if its name
+// ends with a slash.
+type archiveEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+	mtime   time.Time
+}
+
+func (e archiveEntry) isDir() bool { return e.name[len(e.name)-1] == '/' }
+
+// tarGz returns a tar.gz archive of entries, in order.
+func tarGz(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Mode:     int64(e.mode),
+			Size:     int64(len(e.content)),
+			ModTime:  e.mtime,
+			Typeflag: tar.TypeReg,
+		}
+		if e.isDir() {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipFile writes a zip archive of entries, in order, to a temporary
+// file and returns it open.
+func zipFile(t *testing.T, entries []archiveEntry) *os.File {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "go.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.mtime}
+		mode := e.mode
+		if e.isDir() {
+			mode |= os.ModeDir
+		}
+		hdr.SetMode(mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// TestExtractTarOutOfOrder extracts an archive whose directory entry
+// follows its contents and lacks the execute bit.
+func TestExtractTarOutOfOrder(t *testing.T) {
+	archive := tarGz(t, []archiveEntry{
+		{name: "go/src/runtime/proc.go", content: "package runtime\n", mode: 0644},
+		{name: "go/src/", mode: 0600},
+		{name: "go/src/runtime/", mode: 0600},
+		{name: "go/VERSION", content: "go1.22.1\n", mode: 0644},
+	})
+	dir := t.TempDir()
+	err := extractTarGz(bytes.NewReader(archive), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []string{"src", filepath.Join("src", "runtime")} {
+		finfo, err := os.Stat(filepath.Join(dir, d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Windows only has a read-only attribute.
+		if got := finfo.Mode().Perm(); got != 0700 && runtime.GOOS != "windows" {
+			t.Errorf("%s mode = %v, want %v", d, got, os.FileMode(0700))
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "src", "runtime", "proc.go"))
+	if err != nil || string(data) != "package runtime\n" {
+		t.Errorf("reading the extracted file = %q, %v", data, err)
+	}
+	if _, err := ioutil.ReadDir(filepath.Join(dir, "src", "runtime")); err != nil {
+		t.Errorf("listing the extracted directory: %v", err)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	f := zipFile(t, []archiveEntry{
+		{name: "go/", mode: 0755},
+		{name: "go/bin/", mode: 0755},
+		{name: "go/bin/go", content: "#!/bin/sh\n", mode: 0755},
+		{name: "go/src/cmd/go/internal/", mode: 0755},
+		{name: "go/src/cmd/go/internal/doc.go", content: "package internal\n", mode: 0644},
+		{name: "go/VERSION", content: "go1.22.1\n", mode: 0644},
+	})
+	dir := t.TempDir()
+	if err := extractZipFile(f, dir, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{name: "bin/go", content: "#!/bin/sh\n", mode: 0755},
+		{name: "src/cmd/go/internal/doc.go", content: "package internal\n", mode: 0644},
+		{name: "VERSION", content: "go1.22.1\n", mode: 0644},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, filepath.FromSlash(tt.name))
+		data, err := ioutil.ReadFile(path)
+		if err != nil || string(data) != tt.content {
+			t.Errorf("%s = %q, %v, want %q", tt.name, data, err, tt.content)
+			continue
+		}
+		finfo, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := finfo.Mode().Perm(); got != tt.mode && runtime.GOOS != "windows" {
+			t.Errorf("%s mode = %v, want %v", tt.name, got, tt.mode)
+		}
+	}
+	for _, d := range []string{"bin", "src/cmd/go/internal"} {
+		if finfo, err := os.Stat(filepath.Join(dir, filepath.FromSlash(d))); err != nil || !finfo.IsDir() {
+			t.Errorf("%s isn't an extracted directory: %v", d, err)
+		}
+	}
+}
+
+func TestExtractZipTraversal(t *testing.T) {
+	for _, name := range []string{"../evil", "go/../../evil", "/evil", "go/bin/../../../evil"} {
+		f := zipFile(t, []archiveEntry{
+			{name: "go/VERSION", content: "go1.22.1\n", mode: 0644},
+			{name: name, content: "evil\n", mode: 0644},
+		})
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "go1.22.1")
+		err := extractZipFile(f, dir, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "outside of the extraction directory") {
+			t.Errorf("extracting %q: err = %v, want it rejected", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil")); !os.IsNotExist(err) {
+			t.Errorf("extracting %q wrote outside the extraction directory", name)
+		}
+	}
+}
+
+// TestExtractTimes checks that extracted files and directories get
+// the mode and modification time of their entries, including
+// directories whose contents are extracted after them.
+func TestExtractTimes(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2024, 2, day, 12, 0, 0, 0, time.UTC) }
+	entries := []archiveEntry{
+		{name: "go/", mode: 0755, mtime: at(1)},
+		{name: "go/src/", mode: 0750, mtime: at(2)},
+		{name: "go/src/runtime/", mode: 0755, mtime: at(3)},
+		{name: "go/src/runtime/proc.go", content: "package runtime\n", mode: 0640, mtime: at(4)},
+		{name: "go/src/runtime/HACKING.md", content: "# Hacking\n", mode: 0444, mtime: at(5)},
+		{name: "go/bin/", mode: 0755, mtime: at(6)},
+		{name: "go/VERSION", content: "go1.22.1\n", mode: 0644, mtime: at(7)},
+		{name: "go/bin/go", content: "#!/bin/sh\n", mode: 0755, mtime: at(8)},
+	}
+	want := map[string]archiveEntry{
+		".":                      {mode: 0755, mtime: at(1)},
+		"src":                    {mode: 0750, mtime: at(2)},
+		"src/runtime":            {mode: 0755, mtime: at(3)},
+		"src/runtime/proc.go":    {mode: 0640, mtime: at(4)},
+		"src/runtime/HACKING.md": {mode: 0444, mtime: at(5)},
+		"bin":                    {mode: 0755, mtime: at(6)},
+		"VERSION":                {mode: 0644, mtime: at(7)},
+		"bin/go":                 {mode: 0755, mtime: at(8)},
+	}
+
+	extractors := []struct {
+		name    string
+		extract func(dir string) error
+	}{
+		{"tar", func(dir string) error { return extractTarGz(bytes.NewReader(tarGz(t, entries)), dir) }},
+		{"zip", func(dir string) error { return extractZipFile(zipFile(t, entries), dir, nil, nil) }},
+	}
+	for _, ex := range extractors {
+		t.Run(ex.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "go1.22.1")
+			if err := ex.extract(dir); err != nil {
+				t.Fatal(err)
+			}
+			for name, w := range want {
+				finfo, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if !finfo.ModTime().Equal(w.mtime) {
+					t.Errorf("%s mtime = %v, want %v", name, finfo.ModTime().UTC(), w.mtime)
+				}
+				if got := finfo.Mode().Perm(); got != w.mode && runtime.GOOS != "windows" {
+					t.Errorf("%s mode = %v, want %v", name, got, w.mode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// gitConfig overrides settings of the user's git configuration that
+// would interfere with groot's use of git.
+var gitConfig = []string{
+	"core.hooksPath=" + os.DevNull, // worktree add runs post-checkout hooks
+	"core.fsmonitor=false",
+	"core.autocrlf=false", // the Go tree must be checked out as is
+	"core.safecrlf=false",
+	"commit.gpgSign=false",
+	"advice.detachedHead=false",
+	"init.defaultBranch=master",
+}
+
+// gitEnvAllowed are the GIT_ variables passed through to git. They
+// configure how the network is reached rather than which repo is used.
+var gitEnvAllowed = map[string]bool{
+	"GIT_SSH":           true,
+	"GIT_SSH_COMMAND":   true,
+	"GIT_SSL_CAINFO":    true,
+	"GIT_SSL_CAPATH":    true,
+	"GIT_PROXY_COMMAND": true,
+	"GIT_TRACE":         true,
+	"GIT_CURL_VERBOSE":  true,
+}
+
+// gitEnv returns groot's environment with GIT_ variables that select
+// a repository, index, or config removed, so they can't redirect
+// groot's git operations.
+func gitEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		key := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			key = kv[:i]
+		}
+		if strings.HasPrefix(strings.ToUpper(key), "GIT_") && !gitEnvAllowed[key] {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env,
+		// Fail instead of waiting for credentials that will never come.
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_PAGER=cat",
+		"GIT_OPTIONAL_LOCKS=0",
+	)
+}
+
+// gitCommand returns a git command isolated from the caller's git
+// environment and configuration.
+func gitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	prefix := []string{"--no-pager"}
+	for _, kv := range gitConfig {
+		prefix = append(prefix, "-c", kv)
+	}
+	if runtime.GOOS == "windows" {
+		// The Go tree has paths longer than MAX_PATH.
+		prefix = append(prefix, "-c", "core.longpaths=true")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append(prefix, args...)...)
+	cmd.Env = gitEnv()
+	return cmd
+}
+
+// runGit runs git with args, attached to groot's output.
+func (g *groot) runGit(args ...string) error {
+	return g.runRecorded(gitCommand(g.context(), args...))
+}
+
+const defaultStallTimeout = 10 * time.Minute
+
+// networkGit runs a git command that talks to a remote, such as clone
+// or fetch with --progress. It's aborted if it takes longer than the
+// configured network_timeout, or if git reports no progress for
+// stall_timeout.
+func (g *groot) networkGit(args ...string) error {
+	ctx, cancel := context.WithCancel(g.context())
+	defer cancel()
+	if timeout := parseDuration("network_timeout", g.config.NetworkTimeout, 0); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stall := parseDuration("stall_timeout", g.config.StallTimeout, defaultStallTimeout)
+
+	// Progress is written to stderr, passed through so long
+	// operations visibly advance unless output is quieted.
+	out, _, flush := subprocessOutput(os.Stderr, os.Stderr)
+	render := g.newGitProgress(out)
+	progress := &activityWriter{w: render}
+	progress.touch()
+
+	cmd := gitCommand(ctx, args...)
+	cmd.Stdout = progress
+	cmd.Stderr = progress
+	// Helpers such as git-remote-https may outlive a killed git
+	// and keep its output open.
+	cmd.WaitDelay = 5 * time.Second
+
+	debugln("Running: git", strings.Join(args, " "))
+
+	var stalled int32
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(stall / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if progress.idle() >= stall {
+					atomic.StoreInt32(&stalled, 1)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	op := args[0]
+	if op == "--git-dir" && len(args) > 2 {
+		op = args[2]
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	g.recordOp(cmd, start, err)
+	render.Close()
+	flush(err)
+	switch {
+	case err == nil:
+		return nil
+	case atomic.LoadInt32(&stalled) == 1:
+		return fmt.Errorf("git %s stalled: no progress for %s", op, stall)
+	case g.context().Err() != nil:
+		return fmt.Errorf("git %s interrupted", op)
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("git %s timed out", op)
+	}
+	return err
+}
+
+// activityWriter passes writes through to w, recording when
+// the last one happened.
+type activityWriter struct {
+	w    io.Writer
+	last int64 // UnixNano of the last write
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.touch()
+	return a.w.Write(p)
+}
+
+func (a *activityWriter) touch() {
+	atomic.StoreInt64(&a.last, time.Now().UnixNano())
+}
+
+// idle returns the time since the last write.
+func (a *activityWriter) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.last)))
+}
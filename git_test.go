@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// hostileGitEnv sets variables that would redirect git to another
+// repository, index, or hooks directory.
+func hostileGitEnv(t *testing.T, dir string) {
+	t.Setenv("GIT_DIR", filepath.Join(dir, "hostile.git"))
+	t.Setenv("GIT_WORK_TREE", filepath.Join(dir, "hostile"))
+	t.Setenv("GIT_INDEX_FILE", filepath.Join(dir, "hostile.index"))
+	t.Setenv("GIT_OBJECT_DIRECTORY", filepath.Join(dir, "hostile.objects"))
+	t.Setenv("GIT_CONFIG_PARAMETERS", "'core.hookspath'='"+filepath.Join(dir, "hooks")+"'")
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "core.hooksPath")
+	t.Setenv("GIT_CONFIG_VALUE_0", filepath.Join(dir, "hooks"))
+	t.Setenv("GIT_TERMINAL_PROMPT", "1")
+	t.Setenv("GIT_SSH_COMMAND", "ssh -i key")
+	t.Setenv("GIT_PROXY_COMMAND", "proxy")
+}
+
+func TestGitEnv(t *testing.T) {
+	hostileGitEnv(t, t.TempDir())
+
+	cmd := gitCommand(context.Background(), "status")
+	env := make(map[string]string)
+	for _, kv := range cmd.Env {
+		i := strings.IndexByte(kv, '=')
+		if _, dup := env[kv[:i]]; dup {
+			t.Errorf("%s is set twice", kv[:i])
+		}
+		env[kv[:i]] = kv[i+1:]
+	}
+
+	for _, key := range []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_INDEX_FILE", "GIT_OBJECT_DIRECTORY", "GIT_CONFIG_PARAMETERS", "GIT_CONFIG_COUNT", "GIT_CONFIG_KEY_0", "GIT_CONFIG_VALUE_0"} {
+		if v, ok := env[key]; ok {
+			t.Errorf("%s=%s was passed to git", key, v)
+		}
+	}
+	want := map[string]string{
+		"GIT_SSH_COMMAND":     "ssh -i key",
+		"GIT_PROXY_COMMAND":   "proxy",
+		"GIT_TERMINAL_PROMPT": "0",
+		"GIT_PAGER":           "cat",
+		"GIT_OPTIONAL_LOCKS":  "0",
+	}
+	for key, v := range want {
+		if env[key] != v {
+			t.Errorf("%s = %q, want %q", key, env[key], v)
+		}
+	}
+	if env["PATH"] != os.Getenv("PATH") {
+		t.Errorf("PATH = %q, want it passed through", env["PATH"])
+	}
+
+	args := strings.Join(cmd.Args, " ")
+	if !strings.Contains(args, "-c core.hooksPath="+os.DevNull) {
+		t.Errorf("git %s doesn't override core.hooksPath", args)
+	}
+}
+
+// TestGitCommandIsolated runs git under a hostile environment and
+// global configuration, and checks that it still works in the repo
+// it's given without running the user's hooks.
+func TestGitCommandIsolated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a shell script")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("needs git")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	hooks := filepath.Join(dir, "hooks")
+	marker := filepath.Join(dir, "hook-ran")
+	writeTestFile(t, filepath.Join(hooks, "post-checkout"), "#!/bin/sh\ntouch "+marker+"\n", 0755)
+	writeTestFile(t, filepath.Join(dir, "home", ".gitconfig"), "[core]\n\thooksPath = "+hooks+"\n", 0644)
+	writeTestFile(t, filepath.Join(repo, "file"), "content\n", 0644)
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "home"))
+	hostileGitEnv(t, dir)
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := gitCommand(context.Background(), append([]string{"-C", repo, "-c", "user.name=groot", "-c", "user.email=groot@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+	git("checkout", "-q", "-b", "other")
+
+	if got, want := git("rev-parse", "--absolute-git-dir"), filepath.Join(repo, ".git"); got != want {
+		t.Errorf("git dir = %s, want %s", got, want)
+	}
+	if got := git("config", "core.hooksPath"); got != os.DevNull {
+		t.Errorf("core.hooksPath = %s, want %s", got, os.DevNull)
+	}
+	for _, path := range []string{marker, filepath.Join(dir, "hostile.git"), filepath.Join(dir, "hostile.index"), filepath.Join(dir, "hostile")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists", path)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/vcabbage/groot
+
+go 1.20
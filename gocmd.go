@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goCmd returns the command running the go command of the installed
+// version name with args, canceled on interrupt. See goCmdContext.
+func (g *groot) goCmd(name string, args ...string) *exec.Cmd {
+	return g.goCmdContext(g.context(), name, args...)
+}
+
+// goCmdContext returns the command running the go command of the
+// installed version name with args, for groot's own use. The go
+// command is named by its absolute path, and the environment has the
+// version's bin directory first on PATH and groot's active bin
+// directory removed from it, so neither the command nor the tools it
+// runs can resolve to the active version, which may be the one that's
+// broken. GOROOT is left unset; callers that need it add it.
+func (g *groot) goCmdContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	dir := g.versionDir(name)
+	cmd := exec.CommandContext(ctx, filepath.Join(dir, "bin", exeName("go")), args...)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOROOT=") && !strings.HasPrefix(strings.ToUpper(kv), "PATH=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "PATH="+g.versionPath(dir))
+	return cmd
+}
+
+// versionPath returns PATH for running the version in dir: its bin
+// directory, then the entries of groot's PATH other than the active
+// bin directory.
+func (g *groot) versionPath(dir string) string {
+	entries := []string{filepath.Join(dir, "bin")}
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if entry != "" && g.paths.active != "" && samePath(entry, g.paths.active) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
+}
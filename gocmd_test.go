@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestGoCmdExcludesActive checks that the go command groot runs can't
+// resolve to the active version through PATH, whether PATH names
+// groot's bin or the directory it links to.
+func TestGoCmdExcludesActive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bin is a shim directory on Windows")
+	}
+	base := t.TempDir()
+	g := &groot{paths: legacyPaths(base)}
+	activeBin := filepath.Join(g.versionDir("go1.21.0"), "bin")
+	for _, dir := range []string{activeBin, filepath.Join(g.versionDir("go1.22.1"), "bin")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(activeBin, g.paths.active); err != nil {
+		t.Fatal(err)
+	}
+
+	other := t.TempDir()
+	t.Setenv("PATH", strings.Join([]string{other, g.paths.active, "/usr/bin", activeBin}, string(os.PathListSeparator)))
+	t.Setenv("GOROOT", g.versionDir("go1.21.0"))
+
+	cmd := g.goCmdContext(context.Background(), "go1.22.1", "version")
+	if !filepath.IsAbs(cmd.Path) {
+		t.Errorf("cmd.Path = %q, want an absolute path", cmd.Path)
+	}
+	if want := filepath.Join(g.versionDir("go1.22.1"), "bin", "go"); cmd.Path != want {
+		t.Errorf("cmd.Path = %q, want %q", cmd.Path, want)
+	}
+
+	var path string
+	for _, kv := range cmd.Env {
+		switch {
+		case strings.HasPrefix(kv, "GOROOT="):
+			t.Errorf("cmd.Env has %s, want GOROOT unset", kv)
+		case strings.HasPrefix(kv, "PATH="):
+			if path != "" {
+				t.Errorf("cmd.Env has PATH twice")
+			}
+			path = strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+	want := strings.Join([]string{filepath.Join(g.versionDir("go1.22.1"), "bin"), other, "/usr/bin"}, string(os.PathListSeparator))
+	if path != want {
+		t.Errorf("PATH = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// goEnv runs the go env of an installed version, or compares it with
+// another version's with --diff, without activating either.
+func goEnv(fs *flag.FlagSet, g *groot) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print the variables as a JSON object")
+	other := fs.String("diff", "", "print only the variables that differ from installed `version`'s")
+	return func(args []string) error {
+		name, err := g.resolveInstalled(args[0], false)
+		if err != nil {
+			return err
+		}
+		vars := args[1:]
+
+		if *other == "" {
+			goArgs := []string{"env"}
+			if *asJSON {
+				goArgs = append(goArgs, "-json")
+			}
+			cmd, err := g.goEnvCmd(name, append(goArgs, vars...)...)
+			if err != nil {
+				return err
+			}
+			return runAttached(cmd)
+		}
+
+		otherName, err := g.resolveInstalled(*other, false)
+		if err != nil {
+			return err
+		}
+		a, err := g.goEnvVars(name, vars)
+		if err != nil {
+			return err
+		}
+		b, err := g.goEnvVars(otherName, vars)
+		if err != nil {
+			return err
+		}
+
+		keys := make(map[string]bool)
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		var differ []string
+		for k := range keys {
+			if a[k] != b[k] {
+				differ = append(differ, k)
+			}
+		}
+		sort.Strings(differ)
+
+		if *asJSON {
+			diff := make(map[string]map[string]string)
+			for _, k := range differ {
+				diff[k] = map[string]string{name: a[k], otherName: b[k]}
+			}
+			out, err := json.MarshalIndent(diff, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		if len(differ) == 0 {
+			fmt.Println("No differences")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "\t%s\t%s\n", name, otherName)
+		for _, k := range differ {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k, displayEnvValue(a, k), displayEnvValue(b, k))
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// displayEnvValue formats the value of key in env for a table, telling
+// an empty value from one the version doesn't report.
+func displayEnvValue(env map[string]string, key string) string {
+	v, ok := env[key]
+	switch {
+	case !ok:
+		return "(unset)"
+	case v == "":
+		return `""`
+	}
+	return v
+}
+
+// goEnvVars returns the variables reported by go env of the installed
+// version name, only those in vars if any are given.
+func (g *groot) goEnvVars(name string, vars []string) (map[string]string, error) {
+	if len(vars) > 0 {
+		// go env VAR... prints just the values, one per line, in
+		// every version.
+		out, err := g.goEnvOutput(name, vars...)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		if len(lines) != len(vars) {
+			return nil, fmt.Errorf("go env of %s printed %d values for %d variables", name, len(lines), len(vars))
+		}
+		env := make(map[string]string)
+		for i, v := range vars {
+			env[v] = strings.TrimSuffix(lines[i], "\r")
+		}
+		return env, nil
+	}
+
+	// -json was added in go1.9; older versions fail with it.
+	out, err := g.goEnvOutput(name, "-json")
+	if err != nil {
+		out, err = g.goEnvOutput(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parseGoEnv(out)
+}
+
+// goEnvCmd returns the command running go env with args for the
+// installed version name, with GOROOT set as activating it would.
+func (g *groot) goEnvCmd(name string, args ...string) (*exec.Cmd, error) {
+	err := g.restoreSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	cmd := g.goCmd(name, append([]string{"env"}, args...)...)
+	cmd.Env = append(cmd.Env, "GOROOT="+g.versionDir(name))
+	return cmd, nil
+}
+
+// goEnvOutput runs go env with args for the installed version name
+// and returns its standard output.
+func (g *groot) goEnvOutput(name string, args ...string) ([]byte, error) {
+	cmd, err := g.goEnvCmd(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env %s of %s: %v: %s", strings.Join(args, " "), name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// parseGoEnv parses the output of go env, either the JSON object of
+// go env -json or the plain form, which is a shell script setting the
+// variables: KEY="value" on Unix, quoted with single quotes by newer
+// versions, set KEY=value on Windows, and KEY=value or KEY=(...) on
+// Plan 9.
+func parseGoEnv(out []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	if trimmed := bytes.TrimSpace(out); bytes.HasPrefix(trimmed, []byte("{")) {
+		err := json.Unmarshal(trimmed, &env)
+		if err != nil {
+			return nil, fmt.Errorf("parsing go env -json: %v", err)
+		}
+		return env, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "set ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			continue
+		}
+		env[line[:i]] = unquoteEnvValue(line[i+1:])
+	}
+	return env, scanner.Err()
+}
+
+// unquoteEnvValue removes the shell quoting go env adds to value.
+func unquoteEnvValue(value string) string {
+	switch {
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		// A single quote is written as '\''.
+		return strings.Replace(value[1:len(value)-1], `'\''`, "'", -1)
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		return value[1 : len(value)-1]
+	case len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')':
+		return value[1 : len(value)-1]
+	}
+	return value
+}
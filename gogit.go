@@ -0,0 +1,246 @@
+//go:build gogit
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// goGit implements gitBackend with go-git, for systems without git.
+// Versions are checked out as plain directories, with goGitHead
+// recording the commit in place of a worktree's .git file.
+type goGit struct {
+	g *groot
+}
+
+func newGoGit(g *groot) (gitBackend, error) {
+	return &goGit{g}, nil
+}
+
+func (b *goGit) name() string { return backendGoGit }
+
+func (b *goGit) open() (*git.Repository, error) {
+	return git.PlainOpen(b.g.paths.git)
+}
+
+func (b *goGit) clone(url, dir, reference string) error {
+	if reference != "" {
+		return fmt.Errorf("--reference is not supported by the %s backend", backendGoGit)
+	}
+	progress := b.g.newGitProgress(os.Stderr)
+	defer progress.Close()
+	_, err := git.PlainCloneContext(b.g.context(), dir, true, &git.CloneOptions{
+		URL:      url,
+		Progress: progress,
+		Tags:     git.AllTags,
+	})
+	return err
+}
+
+func (b *goGit) fetch(url string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	remote, err := repo.CreateRemoteAnonymous(&gitconfig.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{url},
+	})
+	if err != nil {
+		return err
+	}
+	progress := b.g.newGitProgress(os.Stderr)
+	defer progress.Close()
+	err = remote.FetchContext(b.g.context(), &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"},
+		Progress: progress,
+		Tags:     git.AllTags,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+func (b *goGit) fetchTag(url, tag string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	remote, err := repo.CreateRemoteAnonymous(&gitconfig.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{url},
+	})
+	if err != nil {
+		return err
+	}
+	ref := "refs/tags/" + tag
+	progress := b.g.newGitProgress(os.Stderr)
+	defer progress.Close()
+	err = remote.FetchContext(b.g.context(), &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec("+" + ref + ":" + ref)},
+		Progress: progress,
+		Tags:     git.NoTags,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+func (b *goGit) tags() ([]string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if name := ref.Name().Short(); strings.HasPrefix(name, "go") {
+			tags = append(tags, name)
+		}
+		return nil
+	})
+	return tags, err
+}
+
+func (b *goGit) revParse(rev string) (string, error) {
+	repo, err := b.open()
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", rev, err)
+	}
+	return hash.String(), nil
+}
+
+func (b *goGit) addWorktree(dir, branch, rev string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("resolving %s: %v", rev, err)
+	}
+	err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), *hash))
+	if err != nil {
+		return err
+	}
+	return b.checkout(repo, dir, *hash)
+}
+
+func (b *goGit) resetWorktree(dir, rev string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("resolving %s: %v", rev, err)
+	}
+
+	// Start from scratch rather than tracking which files changed;
+	// build outputs are regenerated by make.bash anyway.
+	err = os.RemoveAll(longPath(dir))
+	if err != nil {
+		return err
+	}
+	return b.checkout(repo, dir, *hash)
+}
+
+// checkout writes the tree of commit hash to dir.
+func (b *goGit) checkout(repo *git.Repository, dir string, hash plumbing.Hash) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	err = mkdirExtracted(longPath(dir), extractedDirMode)
+	if err != nil {
+		return err
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		target := longPath(filepath.Join(dir, filepath.FromSlash(f.Name)))
+		err := mkdirExtracted(filepath.Dir(target), extractedDirMode)
+		if err != nil {
+			return err
+		}
+
+		if f.Mode == filemode.Symlink {
+			contents, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return os.Symlink(contents, target)
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		mode := os.FileMode(0644)
+		if f.Mode == filemode.Executable {
+			mode = 0755
+		}
+		return writeFile(target, r, mode, commit.Committer.When)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Without a .git directory, cmd/dist can't ask git for the
+	// version of a development commit.
+	versionFile := filepath.Join(dir, "VERSION")
+	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
+		version := fmt.Sprintf("devel %s %s\n", hash, commit.Committer.When.UTC().Format("Mon Jan 2 15:04:05 2006 -0700"))
+		err = ioutil.WriteFile(versionFile, []byte(version), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, goGitHead), []byte(hash.String()+"\n"), 0644)
+}
+
+func (b *goGit) removeWorktree(dir, branch string) error {
+	repo, err := b.open()
+	if err != nil {
+		return err
+	}
+	err = repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(branch))
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+	return nil
+}
+
+func (b *goGit) head(dir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, goGitHead))
+	if err != nil {
+		return "", fmt.Errorf("reading HEAD of %s: %v", dir, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,9 @@
+//go:build !gogit
+
+package main
+
+import "errors"
+
+func newGoGit(*groot) (gitBackend, error) {
+	return nil, errors.New("groot was built without the go-git backend (build with -modfile=gogit.mod -tags gogit)")
+}
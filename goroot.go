@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// relocatable reports whether toolchains built from tag find their
+// GOROOT relative to the go binary. Before go1.10 the GOROOT of the
+// build, or GOROOT_FINAL, was used unless GOROOT was set.
+func relocatable(tag string) bool {
+	v, ok := parseVersion(tag)
+	return !ok || !v.less(version{major: 1, minor: 10, pre: "beta", preNum: 1})
+}
+
+// gorootEnv returns the GOROOT_FINAL setting for building tag in dir,
+// or for installing it at final if that's set. It's set explicitly
+// for versions that bake it in, so a stray GOROOT_FINAL in groot's
+// environment can't point the toolchain elsewhere. Newer versions
+// ignore it.
+func gorootEnv(tag, dir, final string) []string {
+	if final != "" {
+		return []string{"GOROOT_FINAL=" + final}
+	}
+	if relocatable(tag) {
+		return nil
+	}
+	return []string{"GOROOT_FINAL=" + dir}
+}
+
+// goroot returns the GOROOT the go command of inst reports when it's
+// installed in dir, which is the GOROOT_FINAL it was built with for
+// versions that bake it in.
+func (inst installState) goroot(dir string) string {
+	if inst.GOROOTFinal != "" && !relocatable(inst.Tag) {
+		return inst.GOROOTFinal
+	}
+	return dir
+}
+
+// checkGOROOT verifies that the go command of the installed version
+// name reports its install directory as GOROOT, or the GOROOT_FINAL
+// it was built with.
+func (g *groot) checkGOROOT(name string) error {
+	want := g.versionDir(name)
+	if inst, err := g.installInfo(name); err == nil {
+		want = inst.goroot(want)
+	}
+	return g.checkGOROOTIs(name, want)
+}
+
+// checkGOROOTIs verifies that the go command of the installed version
+// name reports want as GOROOT.
+func (g *groot) checkGOROOTIs(name, want string) error {
+	dir := g.versionDir(name)
+
+	// goCmd leaves GOROOT unset; an inherited one would be reported
+	// as is.
+	out, err := g.goCmd(name, "env", "GOROOT").Output()
+	if err != nil {
+		return fmt.Errorf("running go env GOROOT: %v", err)
+	}
+	goroot := strings.TrimSpace(string(out))
+
+	if want != dir {
+		if goroot != want {
+			return fmt.Errorf("go env GOROOT of %s reports %s, not the GOROOT_FINAL it was built with, %s", name, goroot, want)
+		}
+		return nil
+	}
+	if !samePath(goroot, dir) {
+		return fmt.Errorf("go env GOROOT of %s reports %s, not its install directory %s; tools that rely on GOROOT will misbehave unless GOROOT is set in the environment", name, goroot, dir)
+	}
+	return nil
+}
+
+// samePath reports whether a and b refer to the same directory,
+// after resolving symlinks.
+func samePath(a, b string) bool {
+	resolve := func(p string) string {
+		if r, err := filepath.EvalSymlinks(p); err == nil {
+			return r
+		}
+		return filepath.Clean(p)
+	}
+	a, b = resolve(a), resolve(b)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
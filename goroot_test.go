@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelocatable(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"go1.4", false},
+		{"go1.9", false},
+		{"go1.9.7", false},
+		{"go1.9rc2", false},
+		{"go1.10beta1", true},
+		{"go1.10rc1", true},
+		{"go1.10", true},
+		{"go1.10.8", true},
+		{"go1.22.1", true},
+		{"tip", true},
+		{"release-branch.go1.9", true},
+	}
+	for _, tt := range tests {
+		if got := relocatable(tt.tag); got != tt.want {
+			t.Errorf("relocatable(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestGorootEnv(t *testing.T) {
+	tests := []struct {
+		tag, dir, final string
+		want            []string
+	}{
+		{"go1.22.1", "/g/go1.22.1", "", nil},
+		{"tip", "/g/tip", "", nil},
+		{"go1.9.7", "/g/go1.9.7", "", []string{"GOROOT_FINAL=/g/go1.9.7"}},
+		{"go1.9.7", "/g/go1.9.7", "/opt/go", []string{"GOROOT_FINAL=/opt/go"}},
+		{"go1.22.1", "/g/go1.22.1", "/opt/go", []string{"GOROOT_FINAL=/opt/go"}},
+	}
+	for _, tt := range tests {
+		if got := gorootEnv(tt.tag, tt.dir, tt.final); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("gorootEnv(%q, %q, %q) = %q, want %q", tt.tag, tt.dir, tt.final, got, tt.want)
+		}
+	}
+}
+
+func TestInstallGoroot(t *testing.T) {
+	tests := []struct {
+		inst installState
+		want string
+	}{
+		{installState{Tag: "go1.22.1"}, "/g/v"},
+		{installState{Tag: "go1.22.1", GOROOTFinal: "/opt/go"}, "/g/v"},
+		{installState{Tag: "go1.9.7"}, "/g/v"},
+		{installState{Tag: "go1.9.7", GOROOTFinal: "/opt/go"}, "/opt/go"},
+	}
+	for _, tt := range tests {
+		if got := tt.inst.goroot("/g/v"); got != tt.want {
+			t.Errorf("%+v.goroot = %q, want %q", tt.inst, got, tt.want)
+		}
+	}
+}
+
+// TestCheckGOROOTRelocated copies the go command of the toolchain
+// running the tests, with enough of its GOROOT for it to be found
+// relative to the binary, into a groot directory and checks that it
+// reports its new location.
+func TestCheckGOROOTRelocated(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("needs a go toolchain")
+	}
+	out, err := exec.Command(goBin, "env", "GOROOT").Output()
+	if err != nil {
+		t.Skip("go env GOROOT:", err)
+	}
+	src := strings.TrimSpace(string(out))
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOFLAGS", "")
+
+	base := t.TempDir()
+	g := &groot{paths: legacyPaths(base)}
+	dst := g.versionDir("go1.22.1")
+	for _, name := range []string{filepath.Join("bin", exeName("go")), "go.env", "VERSION"} {
+		copyTestFile(t, filepath.Join(src, name), filepath.Join(dst, name))
+	}
+	if err := os.MkdirAll(filepath.Join(dst, "pkg", "tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.checkGOROOT("go1.22.1"); err != nil {
+		t.Errorf("checkGOROOT: %v", err)
+	}
+	if err := g.checkGOROOTIs("go1.22.1", src); err == nil {
+		t.Errorf("checkGOROOTIs(%s) succeeded for a copy in %s", src, dst)
+	}
+
+	// Reached through a symlink, the directory is still the same.
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(base, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	g = &groot{paths: legacyPaths(link)}
+	if err := g.checkGOROOT("go1.22.1"); err != nil {
+		t.Errorf("checkGOROOT through a symlink: %v", err)
+	}
+}
+
+func copyTestFile(t *testing.T, src, dst string) {
+	t.Helper()
+	in, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	finfo, err := in.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, finfo.Mode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
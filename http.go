@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	userAgent = "groot (+https://github.com/vcabbage/groot)"
+
+	// stallTimeout is how long a download may go without receiving
+	// any data before it's aborted.
+	stallTimeout = 60 * time.Second
+)
+
+func (g *groot) httpClient() (*http.Client, error) {
+	if g.client != nil {
+		return g.client, nil
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	if bundle, source := g.caBundle(); bundle != "" {
+		pool, _, err := loadCABundle(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("loading CA bundle from %s: %v", source, err)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	if g.insecureSkipVerify {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	// No overall timeout, large downloads are guarded by stallReader instead.
+	g.client = &http.Client{Transport: transport}
+	return g.client, nil
+}
+
+// caBundle returns the configured CA bundle path and where
+// it was configured. GROOT_CA_BUNDLE takes precedence over
+// the config file.
+func (g *groot) caBundle() (string, string) {
+	if bundle := os.Getenv("GROOT_CA_BUNDLE"); bundle != "" {
+		return bundle, "GROOT_CA_BUNDLE"
+	}
+	if g.config.CABundle != "" {
+		return g.config.CABundle, g.paths.config
+	}
+	return "", ""
+}
+
+// loadCABundle returns the system cert pool with the certificates
+// from path added. path may be a PEM file or a directory of PEM files.
+func loadCABundle(path string) (*x509.CertPool, int, error) {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []string{path}
+	if finfo.IsDir() {
+		files = nil
+		finfos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, finfo := range finfos {
+			switch filepath.Ext(finfo.Name()) {
+			case ".pem", ".crt", ".cer":
+				files = append(files, filepath.Join(path, finfo.Name()))
+			}
+		}
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	count := 0
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		n := 0
+		for len(data) > 0 {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %v", file, err)
+			}
+			pool.AddCert(cert)
+			n++
+		}
+		if n == 0 {
+			return nil, 0, fmt.Errorf("%s: no PEM certificates found", file)
+		}
+		count += n
+	}
+
+	if count == 0 {
+		return nil, 0, fmt.Errorf("%s: no PEM certificates found", path)
+	}
+	return pool, count, nil
+}
+
+// setInsecureSkipVerify disables TLS certificate verification, provided
+// the config explicitly allows it.
+func (g *groot) setInsecureSkipVerify() error {
+	if !g.config.AllowInsecureSkipVerify {
+		return fmt.Errorf("--insecure-skip-verify requires \"allow_insecure_skip_verify\": true in %s", g.paths.config)
+	}
+	g.insecureSkipVerify = true
+	g.client = nil
+	return nil
+}
+
+// get performs a GET request, returning an error if the response
+// status isn't 200. The response body is aborted if it stalls.
+func (g *groot) get(url string) (*http.Response, error) {
+	return g.getConditional(url, nil)
+}
+
+// getConditional is like get, but adds header to the request
+// and also accepts a 304 Not Modified response.
+func (g *groot) getConditional(url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	// Interrupting groot cancels the download, as for git.
+	ctx, cancel := context.WithCancel(g.context())
+	req = req.WithContext(ctx)
+
+	if g.insecureSkipVerify {
+		warnln("WARNING: TLS certificate verification is disabled for", url)
+	}
+
+	client, err := g.httpClient()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK && !(header != nil && resp.StatusCode == http.StatusNotModified) {
+		defer cancel()
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+
+	resp.Body = newStallReader(resp.Body, stallTimeout, cancel)
+	return resp, nil
+}
+
+// statusError describes an unexpected response, including the
+// final URL after redirects and the start of the body.
+func statusError(resp *http.Response) error {
+	msg := fmt.Sprintf("GET %s: unexpected status: %s", resp.Request.URL, resp.Status)
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if snippet := strings.TrimSpace(string(body)); snippet != "" {
+		msg += "\n" + snippet
+	}
+	return errors.New(msg)
+}
+
+// stallReader cancels a response when no data has been read
+// within timeout.
+type stallReader struct {
+	rc      io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  func()
+	stalled int32
+}
+
+func newStallReader(rc io.ReadCloser, timeout time.Duration, cancel func()) *stallReader {
+	r := &stallReader{rc: rc, timeout: timeout, cancel: cancel}
+	r.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&r.stalled, 1)
+		cancel()
+	})
+	return r
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if atomic.LoadInt32(&r.stalled) == 1 {
+		return n, fmt.Errorf("download stalled: no data received for %s", r.timeout)
+	}
+	r.timer.Reset(r.timeout)
+	return n, err
+}
+
+func (r *stallReader) Close() error {
+	r.timer.Stop()
+	r.cancel()
+	return r.rc.Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestGetCanceled checks that interrupting groot cancels a request,
+// whether it's waiting for the response or reading the body.
+func TestGetCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("response", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g := &groot{ctx: ctx, client: srv.Client()}
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		resp, err := g.get(srv.URL + "/headers")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("get succeeded after being canceled")
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("get returned after %v, want it canceled promptly", d)
+		}
+	})
+
+	t.Run("body", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g := &groot{ctx: ctx, client: srv.Client()}
+		resp, err := g.get(srv.URL + "/body")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		if _, err := ioutil.ReadAll(resp.Body); err == nil {
+			t.Error("reading the body succeeded after being canceled")
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("reading the body returned after %v, want it canceled promptly", d)
+		}
+	})
+}
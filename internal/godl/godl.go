@@ -0,0 +1,64 @@
+// Package godl describes the Go releases published at go.dev/dl.
+package godl
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// URL is the JSON listing of all releases, newest first.
+const URL = "https://go.dev/dl/?mode=json&include=all"
+
+// CurrentURL is the JSON listing of the current releases: the newest
+// patch release of each supported minor release, and any prerelease.
+const CurrentURL = "https://go.dev/dl/?mode=json"
+
+// Release is a single Go release.
+type Release struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+	Files   []File `json:"files"`
+}
+
+// File is a downloadable file belonging to a release.
+type File struct {
+	Filename string `json:"filename"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Version  string `json:"version"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Kind     string `json:"kind"` // "archive", "installer", or "source"
+}
+
+// Parse decodes the JSON listing.
+func Parse(data []byte) ([]Release, error) {
+	var rels []Release
+	err := json.Unmarshal(data, &rels)
+	if err != nil {
+		return nil, fmt.Errorf("parsing release metadata: %v", err)
+	}
+	return rels, nil
+}
+
+// Archive returns the binary archive of r for goos/goarch.
+func (r Release) Archive(goos, goarch string) (File, bool) {
+	for _, f := range r.Files {
+		if f.Kind == "archive" && f.OS == goos && f.Arch == goarch {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Find returns the file named filename from any of rels.
+func Find(rels []Release, filename string) (File, bool) {
+	for _, rel := range rels {
+		for _, f := range rel.Files {
+			if f.Filename == filename {
+				return f, true
+			}
+		}
+	}
+	return File{}, false
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// labelFilter selects installs by their labels. The forms are
+// key=value, key!=value, key (the label is set), and !key (it isn't).
+type labelFilter struct {
+	key, value string
+	hasValue   bool
+	negate     bool
+}
+
+func parseLabelFilter(s string) (labelFilter, error) {
+	var f labelFilter
+	switch {
+	case strings.Contains(s, "!="):
+		i := strings.Index(s, "!=")
+		f = labelFilter{key: s[:i], value: s[i+2:], hasValue: true, negate: true}
+	case strings.Contains(s, "="):
+		i := strings.Index(s, "=")
+		f = labelFilter{key: s[:i], value: s[i+1:], hasValue: true}
+	case strings.HasPrefix(s, "!"):
+		f = labelFilter{key: s[1:], negate: true}
+	default:
+		f = labelFilter{key: s}
+	}
+	if err := checkLabelKey(f.key); err != nil {
+		return f, fmt.Errorf("invalid label filter %q: %v", s, err)
+	}
+	return f, nil
+}
+
+func (f labelFilter) match(labels map[string]string) bool {
+	value, ok := labels[f.key]
+	if f.hasValue {
+		ok = ok && value == f.value
+	}
+	return ok != f.negate
+}
+
+// labelFilters is a flag.Value collecting repeated --label flags, all
+// of which must match.
+type labelFilters []labelFilter
+
+func (fs *labelFilters) String() string { return "" }
+
+func (fs *labelFilters) Set(s string) error {
+	f, err := parseLabelFilter(s)
+	if err != nil {
+		return err
+	}
+	*fs = append(*fs, f)
+	return nil
+}
+
+func (fs labelFilters) match(inst *installState) bool {
+	var labels map[string]string
+	if inst != nil {
+		labels = inst.Labels
+	}
+	for _, f := range fs {
+		if !f.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkLabelKey returns an error if key can't be used as a label key,
+// either because it's empty or it would make filters ambiguous.
+func checkLabelKey(key string) error {
+	if key == "" {
+		return errors.New("the key is empty")
+	}
+	if strings.HasPrefix(key, "!") || strings.ContainsAny(key, "=,") {
+		return fmt.Errorf("key %q contains '!', '=', or ','", key)
+	}
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("key %q contains whitespace", key)
+		}
+	}
+	return nil
+}
+
+// formatLabels returns labels as sorted key=value pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// updateInstall applies fn to the recorded state of the installed
+// version name, recording what's inferred about it first if nothing
+// was.
+func (g *groot) updateInstall(name string, fn func(*installState)) error {
+	if _, err := os.Stat(longPath(g.versionDir(name))); err != nil {
+		return err
+	}
+	inferred, err := g.installInfo(name)
+	if err != nil {
+		return err
+	}
+	return g.updateState(func(s *state) error {
+		inst, ok := s.Installs[name]
+		if !ok {
+			inst = &inferred
+			inst.Provenance = nil
+			s.Installs[name] = inst
+		}
+		fn(inst)
+		return nil
+	})
+}
+
+func label(fs *flag.FlagSet, g *groot) func(args []string) error {
+	remove := fs.Bool("remove", false, "remove the labels with the given keys")
+	return func(args []string) error {
+		var err error
+		name, pairs := args[0], args[1:]
+		if !g.exists(name) {
+			return fmt.Errorf("%s isn't installed; see `groot list`", name)
+		}
+
+		if len(pairs) == 0 {
+			inst, err := g.installInfo(name)
+			if err != nil {
+				return err
+			}
+			keys := make([]string, 0, len(inst.Labels))
+			for k := range inst.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Printf("%s=%s\n", k, inst.Labels[k])
+			}
+			return nil
+		}
+
+		set := make(map[string]string)
+		for _, pair := range pairs {
+			key, value := pair, ""
+			if !*remove {
+				i := strings.Index(pair, "=")
+				if i < 0 {
+					return fmt.Errorf("label %q isn't key=value", pair)
+				}
+				key, value = pair[:i], pair[i+1:]
+			}
+			if err := checkLabelKey(key); err != nil {
+				return err
+			}
+			set[key] = value
+		}
+
+		err = g.updateInstall(name, func(inst *installState) {
+			if inst.Labels == nil {
+				inst.Labels = make(map[string]string)
+			}
+			for k, v := range set {
+				if *remove {
+					delete(inst.Labels, k)
+				} else {
+					inst.Labels[k] = v
+				}
+			}
+			if len(inst.Labels) == 0 {
+				inst.Labels = nil
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func note(g *groot, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageErrorf(`An empty text, "", removes the note.`)
+	}
+	name := args[0]
+	if !g.exists(name) {
+		return fmt.Errorf("%s isn't installed; see `groot list`", name)
+	}
+
+	if len(args) == 1 {
+		inst, err := g.installInfo(name)
+		if err != nil {
+			return err
+		}
+		if inst.Note != "" {
+			fmt.Println(inst.Note)
+		}
+		return nil
+	}
+
+	text := strings.TrimSpace(args[1])
+	err := g.updateInstall(name, func(inst *installState) {
+		inst.Note = text
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+)
+
+// installGroup is the installs of one minor release line, as listed
+// by list --tree.
+type installGroup struct {
+	label string // such as go1.21, or "other"
+	names []string
+}
+
+// otherGroup holds the installs not derived from a release line, such
+// as tip and other branches.
+const otherGroup = "other"
+
+// minorRE finds the release line of a tag or branch that isn't a
+// release itself, such as release-branch.go1.21.
+var minorRE = regexp.MustCompile(`go(\d+)\.(\d+)`)
+
+// releaseLine returns the minor release line of the version tag,
+// which for custom-named installs is the tag or branch they were
+// built from.
+func releaseLine(tag string) (version, bool) {
+	if v, ok := parseVersion(tag); ok {
+		return version{major: v.major, minor: v.minor}, true
+	}
+	if m := minorRE.FindStringSubmatch(tag); m != nil {
+		return parseVersion("go" + m[1] + "." + m[2])
+	}
+	return version{}, false
+}
+
+// groupInstalls buckets the installs names by release line, newest
+// line first, with installs of other branches last. tags maps names
+// to the tag or branch they were installed from, where it's known;
+// other names are taken to be their own tag. Within a line the newest
+// release comes first, and builds of the same release, such as
+// go1.21.6-goamd64v3 or a renamed install, follow it by name.
+func groupInstalls(names []string, tags map[string]string) []installGroup {
+	tagOf := func(name string) string {
+		if tag, ok := tags[name]; ok && tag != "" {
+			return tag
+		}
+		return name
+	}
+
+	byLine := make(map[version][]string)
+	var lines []version
+	var other []string
+	for _, name := range names {
+		line, ok := releaseLine(tagOf(name))
+		if !ok {
+			other = append(other, name)
+			continue
+		}
+		if _, seen := byLine[line]; !seen {
+			lines = append(lines, line)
+		}
+		byLine[line] = append(byLine[line], name)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[j].less(lines[i]) })
+
+	var groups []installGroup
+	for _, line := range lines {
+		members := byLine[line]
+		sort.SliceStable(members, func(i, j int) bool {
+			vi, iok := parseVersion(tagOf(members[i]))
+			vj, jok := parseVersion(tagOf(members[j]))
+			switch {
+			case iok && jok && vi != vj:
+				return vj.less(vi)
+			case iok != jok:
+				// Branches of the line follow its releases.
+				return iok
+			}
+			return members[i] < members[j]
+		})
+		groups = append(groups, installGroup{label: line.String(), names: members})
+	}
+	if len(other) > 0 {
+		sort.Strings(other)
+		groups = append(groups, installGroup{label: otherGroup, names: other})
+	}
+	return groups
+}
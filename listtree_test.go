@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupInstalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		tags  map[string]string
+		want  []installGroup
+	}{
+		{
+			name:  "empty",
+			names: nil,
+			want:  nil,
+		},
+		{
+			name:  "releases",
+			names: []string{"go1.20.14", "go1.21.0", "go1.21.6", "go1.22.1", "go1.9.7"},
+			want: []installGroup{
+				{label: "go1.22", names: []string{"go1.22.1"}},
+				{label: "go1.21", names: []string{"go1.21.6", "go1.21.0"}},
+				{label: "go1.20", names: []string{"go1.20.14"}},
+				{label: "go1.9", names: []string{"go1.9.7"}},
+			},
+		},
+		{
+			name:  "prereleases",
+			names: []string{"go1.22rc1", "go1.22.0", "go1.22rc2", "go1.22beta1", "go1.21.6", "go1.23rc1"},
+			want: []installGroup{
+				{label: "go1.23", names: []string{"go1.23rc1"}},
+				{label: "go1.22", names: []string{"go1.22.0", "go1.22rc2", "go1.22rc1", "go1.22beta1"}},
+				{label: "go1.21", names: []string{"go1.21.6"}},
+			},
+		},
+		{
+			name:  "custom names",
+			names: []string{"work", "go1.21.6", "go1.21.6-goamd64v3", "old", "go1.21.5"},
+			tags: map[string]string{
+				"work":               "go1.21.6",
+				"go1.21.6-goamd64v3": "go1.21.6",
+				"old":                "go1.20.1",
+			},
+			want: []installGroup{
+				{label: "go1.21", names: []string{"go1.21.6", "go1.21.6-goamd64v3", "work", "go1.21.5"}},
+				{label: "go1.20", names: []string{"old"}},
+			},
+		},
+		{
+			name:  "empty tag",
+			names: []string{"go1.21.6"},
+			tags:  map[string]string{"go1.21.6": ""},
+			want:  []installGroup{{label: "go1.21", names: []string{"go1.21.6"}}},
+		},
+		{
+			name:  "branches",
+			names: []string{"rb21", "go1.21.6", "dev.boringcrypto", "feature"},
+			tags: map[string]string{
+				"rb21":             "release-branch.go1.21",
+				"dev.boringcrypto": "dev.boringcrypto",
+				"feature":          "feature/x",
+			},
+			want: []installGroup{
+				{label: "go1.21", names: []string{"go1.21.6", "rb21"}},
+				{label: otherGroup, names: []string{"dev.boringcrypto", "feature"}},
+			},
+		},
+		{
+			name:  "branch only line",
+			names: []string{"release-branch.go1.22"},
+			want:  []installGroup{{label: "go1.22", names: []string{"release-branch.go1.22"}}},
+		},
+		{
+			name:  "tip snapshots",
+			names: []string{"tip-2024-03-02", "tip", "go1.22.1", "tip-2024-03-01-2", "tip-2024-03-01"},
+			tags: map[string]string{
+				"tip-2024-03-01":   tipTag,
+				"tip-2024-03-01-2": tipTag,
+				"tip-2024-03-02":   tipTag,
+			},
+			want: []installGroup{
+				{label: "go1.22", names: []string{"go1.22.1"}},
+				{label: otherGroup, names: []string{"tip", "tip-2024-03-01", "tip-2024-03-01-2", "tip-2024-03-02"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupInstalls(tt.names, tt.tags)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupInstalls(%q) =\n%+v\nwant\n%+v", tt.names, got, tt.want)
+			}
+		})
+	}
+}
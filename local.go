@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goVersionFile pins the version used in a directory and
+// the directories below it.
+const goVersionFile = ".go-version"
+
+// toolVersionsFile is asdf's file of the versions of each tool used in
+// a directory, which pins Go with a golang line.
+const toolVersionsFile = ".tool-versions"
+
+// findGoVersion looks for a .go-version file, or a .tool-versions file
+// with a golang line, in dir and its ancestors, returning its path and
+// the version it names. The path is empty if there's none. A
+// .go-version takes precedence over a .tool-versions in the same
+// directory.
+func findGoVersion(dir string) (string, string, error) {
+	for {
+		p := filepath.Join(dir, goVersionFile)
+		data, err := ioutil.ReadFile(p)
+		if err == nil {
+			spec := goVersionSpec(string(data))
+			if spec == "" {
+				return "", "", fmt.Errorf("%s is empty", p)
+			}
+			return p, spec, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+
+		// As with asdf, a .tool-versions without Go defers to the
+		// parent directories.
+		p = filepath.Join(dir, toolVersionsFile)
+		data, err = ioutil.ReadFile(p)
+		if err == nil {
+			if spec := toolVersionsSpec(string(data)); spec != "" {
+				return p, spec, nil
+			}
+		} else if !os.IsNotExist(err) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// goVersionSpec returns the version named by the contents of a
+// .go-version file: the first line that isn't blank or a # comment,
+// as other tools write them with CRLF line endings, a byte order
+// mark, or trailing lines.
+func goVersionSpec(data string) string {
+	for _, line := range strings.Split(strings.TrimPrefix(data, "\ufeff"), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// toolVersionsTools are the names a .tool-versions file may give Go:
+// asdf's golang plugin, and go as mise also accepts.
+var toolVersionsTools = []string{"golang", "go"}
+
+// isGoTool reports whether the .tool-versions line fields pins Go.
+func isGoTool(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	for _, tool := range toolVersionsTools {
+		if fields[0] == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// toolVersionFields returns the fields of a .tool-versions line,
+// without its comment.
+func toolVersionFields(line string) []string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(line)
+}
+
+// toolVersionsSpec returns the Go version named by the contents of a
+// .tool-versions file, or "" if it doesn't pin Go. Of a line listing
+// several versions, such as golang 1.22.1 1.21.8, the first is
+// preferred, as by asdf.
+func toolVersionsSpec(data string) string {
+	for _, line := range strings.Split(strings.TrimPrefix(data, "\ufeff"), "\n") {
+		fields := toolVersionFields(line)
+		if isGoTool(fields) && len(fields) > 1 {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// setToolVersion rewrites the .tool-versions file in the working
+// directory to pin Go to spec, or to remove Go when spec is empty,
+// keeping the lines of other tools. It reports whether the file had a
+// Go line. A file left without any lines is removed.
+func setToolVersion(spec string) (bool, error) {
+	data, err := ioutil.ReadFile(toolVersionsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	var lines []string
+	found := false
+	text := strings.TrimSuffix(strings.TrimPrefix(string(data), "\ufeff"), "\n")
+	if text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			if !isGoTool(toolVersionFields(line)) {
+				lines = append(lines, line)
+				continue
+			}
+			if found || spec == "" {
+				found = true
+				continue
+			}
+			found = true
+			lines = append(lines, "golang "+spec)
+		}
+	}
+	if !found && spec != "" {
+		lines = append(lines, "golang "+spec)
+	}
+
+	if len(lines) == 0 {
+		err = os.Remove(toolVersionsFile)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return found, err
+	}
+	return found, ioutil.WriteFile(toolVersionsFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// localVersion returns the .go-version or .tool-versions file in
+// effect for the working directory and the installed version it refers to.
+func (g *groot) localVersion() (string, string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	p, spec, err := findGoVersion(wd)
+	if err != nil || p == "" {
+		return "", "", err
+	}
+	tag, err := g.resolveInstalled(spec, false)
+	if err != nil {
+		return p, "", fmt.Errorf("%s: %v", p, err)
+	}
+	return p, tag, nil
+}
+
+// localMismatchError reports that the version file p requests local
+// while active is the active version.
+func localMismatchError(p, local, active string) error {
+	if active == "" {
+		return fmt.Errorf("%s requests %s, but no version is active; run `groot activate`", p, local)
+	}
+	return fmt.Errorf("%s requests %s, but %s is active; run `groot activate`", p, local, active)
+}
+
+// reportsLocal lists the commands that report the version file in
+// effect themselves, or change which version is active, so they skip
+// the warn_local_mismatch warning.
+var reportsLocal = map[string]bool{
+	"activate":   true,
+	"current":    true,
+	"deactivate": true,
+	"doctor":     true,
+	"init":       true,
+	"local":      true,
+	"status":     true,
+}
+
+// warnLocalMismatch warns if the nearest .go-version or .tool-versions
+// requests another version than the active one, as the go on PATH
+// isn't the one the file names until it's activated.
+func (g *groot) warnLocalMismatch() {
+	p, local, err := g.localVersion()
+	if err != nil {
+		warnln("Ignoring", err)
+		return
+	}
+	if p == "" {
+		return
+	}
+	active, err := g.activeVersion()
+	if err != nil || active == local {
+		return
+	}
+	warnln(localMismatchError(p, local, active))
+}
+
+func local(fs *flag.FlagSet, g *groot) func(args []string) error {
+	unset := fs.Bool("unset", false, "remove the .go-version file in the current directory")
+	format := fs.String("format", "go-version", "write a `go-version` or tool-versions file")
+	return func(args []string) error {
+		toolVersions := false
+		switch *format {
+		case "go-version":
+		case "tool-versions":
+			toolVersions = true
+		default:
+			return errUsage
+		}
+
+		switch {
+		case *unset && len(args) == 0 && toolVersions:
+			found, err := setToolVersion("")
+			if err != nil {
+				return err
+			}
+			if !found {
+				fmt.Println("No golang line in", toolVersionsFile, "in the current directory")
+				return exitStatus(exitError)
+			}
+			return nil
+		case *unset && len(args) == 0:
+			err := os.Remove(goVersionFile)
+			if os.IsNotExist(err) {
+				fmt.Println("No", goVersionFile, "in the current directory")
+				return exitStatus(exitError)
+			}
+			if err != nil {
+				return err
+			}
+			return nil
+		case len(args) == 0:
+			// Show the file in effect, which may be in a parent.
+			p, tag, err := g.localVersion()
+			if err != nil {
+				return err
+			}
+			if p == "" {
+				fmt.Println("No", goVersionFile, "or", toolVersionsFile, "in the current directory or its parents")
+				return exitStatus(exitError)
+			}
+			fmt.Println(tag, "from", p)
+			return nil
+		case len(args) > 1 || *unset:
+			return errUsage
+		}
+
+		tag, err := g.resolveInstalled(args[0], false)
+		if err != nil {
+			return err
+		}
+		// The full tag is written since a partial version would
+		// follow later patch releases.
+		file := goVersionFile
+		if toolVersions {
+			// asdf's golang plugin names releases without the go prefix.
+			spec := tag
+			if _, ok := parseVersion(tag); ok {
+				spec = strings.TrimPrefix(tag, "go")
+			}
+			file = toolVersionsFile
+			_, err = setToolVersion(spec)
+		} else {
+			err = ioutil.WriteFile(goVersionFile, []byte(tag+"\n"), 0644)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s; `groot activate` in this directory activates %s\n", file, tag)
+		return nil
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// File locking isn't supported, concurrent invocations
+// rely on atomic renames alone.
+
+func lockFile(f *os.File, exclusive bool) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
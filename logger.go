@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// logLevel is how much groot reports about what it's doing.
+type logLevel int
+
+const (
+	levelError logLevel = iota // errors and each command's result only
+	levelWarn                  // and warnings
+	levelInfo                  // and the progress of each phase, the default
+	levelDebug                 // and subprocess command lines and extracted files
+)
+
+var logLevels = map[string]logLevel{
+	"error": levelError,
+	"warn":  levelWarn,
+	"info":  levelInfo,
+	"debug": levelDebug,
+}
+
+// verbosity is the level set by --quiet, --verbose, or GROOT_LOG.
+var verbosity = levelInfo
+
+// setVerbosity sets the level from the global flags, falling back to
+// GROOT_LOG.
+func setVerbosity(quiet, verbose bool) {
+	switch {
+	case quiet:
+		verbosity = levelError
+	case verbose:
+		verbosity = levelDebug
+	default:
+		env := os.Getenv("GROOT_LOG")
+		if env == "" {
+			return
+		}
+		level, ok := logLevels[strings.ToLower(env)]
+		if !ok {
+			warnf("Unknown GROOT_LOG %q, using info", env)
+			return
+		}
+		verbosity = level
+	}
+}
+
+// warnf logs a warning, with the location it came from.
+func warnf(format string, args ...interface{}) {
+	if verbosity >= levelWarn {
+		log.Output(2, fmt.Sprintf(format, args...))
+	}
+}
+
+// warnln is warnf formatted as by fmt.Sprintln.
+func warnln(args ...interface{}) {
+	if verbosity >= levelWarn {
+		log.Output(2, fmt.Sprintln(args...))
+	}
+}
+
+// infof reports progress on stdout.
+func infof(format string, args ...interface{}) {
+	if verbosity >= levelInfo {
+		fmt.Printf(format, args...)
+	}
+}
+
+func infoln(args ...interface{}) {
+	if verbosity >= levelInfo {
+		fmt.Println(args...)
+	}
+}
+
+// debugf reports detail only wanted when diagnosing a problem.
+func debugf(format string, args ...interface{}) {
+	if verbosity >= levelDebug {
+		fmt.Printf(format, args...)
+	}
+}
+
+func debugln(args ...interface{}) {
+	if verbosity >= levelDebug {
+		fmt.Println(args...)
+	}
+}
+
+// subprocessOutput returns where the stdout and stderr of a
+// subprocess go. Below levelInfo they're kept in a tailWriter rather
+// than streamed, and printed by flush only if the subprocess failed.
+func subprocessOutput(stdout, stderr io.Writer) (io.Writer, io.Writer, func(err error)) {
+	if verbosity >= levelInfo {
+		return stdout, stderr, func(error) {}
+	}
+	tail := &tailWriter{n: buildLogTail}
+	return tail, tail, func(err error) {
+		if err != nil {
+			fmt.Fprint(os.Stderr, tail.String())
+		}
+	}
+}
+
+// runAttachedOutput runs cmd with its output going to groot's as
+// allowed by the log level.
+func runAttachedOutput(cmd *exec.Cmd) error {
+	debugln("Running:", strings.Join(cmd.Args, " "))
+
+	var flush func(error)
+	cmd.Stdout, cmd.Stderr, flush = subprocessOutput(os.Stdout, os.Stderr)
+	err := cmd.Run()
+	flush(err)
+	return err
+}
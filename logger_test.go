@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestLogLevels runs add under each GROOT_LOG with a dangling bin, so
+// that there's a warning, progress, and subprocess output to report.
+func TestLogLevels(t *testing.T) {
+	const (
+		warning    = "which no longer exists"
+		subprocess = "HEAD is now at"
+		progress   = "Preparing worktree"
+		debug      = "Running: git"
+	)
+	tests := []struct {
+		env     string
+		args    []string
+		present []string
+		absent  []string
+	}{
+		{env: "error", absent: []string{warning, subprocess, progress, debug}},
+		{env: "warn", present: []string{warning}, absent: []string{subprocess, progress, debug}},
+		{env: "info", present: []string{warning, subprocess, progress}, absent: []string{debug}},
+		{env: "debug", present: []string{warning, subprocess, progress, debug}},
+		{env: "DEBUG", present: []string{debug}},
+		{env: "", present: []string{warning, subprocess}, absent: []string{debug}},
+		{env: "loud", present: []string{`Unknown GROOT_LOG "loud", using info`, subprocess}, absent: []string{debug}},
+		{env: "debug", args: []string{"--quiet"}, absent: []string{warning, subprocess, debug}},
+		{env: "error", args: []string{"--verbose"}, present: []string{warning, debug}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s%v", tt.env, tt.args), func(t *testing.T) {
+			home := newTestHome(t)
+			err := os.Symlink(filepath.Join(home, "gone", "bin"), filepath.Join(home, "bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("GROOT_LOG", tt.env)
+
+			args := append([]string{"--home", home}, tt.args...)
+			stdout, stderr, code := runGroot(t, append(args, "add", "go1.21.0")...)
+			if code != 0 {
+				t.Fatalf("add failed: %s", stderr)
+			}
+			output := stdout + stderr
+			for _, s := range tt.present {
+				if !strings.Contains(output, s) {
+					t.Errorf("output doesn't contain %q:\n%s", s, output)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(output, s) {
+					t.Errorf("output contains %q:\n%s", s, output)
+				}
+			}
+		})
+	}
+}
+
+// TestSubprocessOutput checks that below info a subprocess's output
+// is only printed, and then only its tail, if it fails.
+func TestSubprocessOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	script := func(status int) *exec.Cmd {
+		return exec.Command("sh", "-c", fmt.Sprintf(`i=1; while [ $i -le 40 ]; do echo "line $i"; echo "err $i" >&2; i=$((i+1)); done; exit %d`, status))
+	}
+	defer func(v logLevel) { verbosity = v }(verbosity)
+
+	tests := []struct {
+		level   logLevel
+		status  int
+		stdout  []string
+		stderr  []string
+		missing []string
+	}{
+		{level: levelError, status: 0, missing: []string{"line 40", "err 40"}},
+		{level: levelWarn, status: 0, missing: []string{"line 40", "err 40"}},
+		{level: levelError, status: 1, stderr: []string{"line 40\n", "err 40\n", "err 26\n"}, missing: []string{"line 1\n", "err 1\n", "line 25\n"}},
+		{level: levelInfo, status: 0, stdout: []string{"line 1\n", "line 40\n"}, stderr: []string{"err 1\n", "err 40\n"}},
+		{level: levelInfo, status: 1, stdout: []string{"line 1\n", "line 40\n"}, stderr: []string{"err 1\n", "err 40\n"}},
+	}
+	for _, tt := range tests {
+		verbosity = tt.level
+		var err error
+		stdout, stderr := captureOutput(t, func() { err = runAttachedOutput(script(tt.status)) })
+		if (err != nil) != (tt.status != 0) {
+			t.Errorf("level %d, exit %d: err = %v", tt.level, tt.status, err)
+		}
+		check := func(what, got string, want []string) {
+			for _, s := range want {
+				if !strings.Contains(got, s) {
+					t.Errorf("level %d, exit %d: %s doesn't contain %q:\n%s", tt.level, tt.status, what, s, got)
+				}
+			}
+		}
+		check("stdout", stdout, tt.stdout)
+		check("stderr", stderr, tt.stderr)
+		for _, s := range tt.missing {
+			if strings.Contains(stdout+stderr, s) {
+				t.Errorf("level %d, exit %d: output contains %q", tt.level, tt.status, s)
+			}
+		}
+	}
+}
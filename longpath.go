@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// longPath returns p in a form that isn't subject to the
+// 260 character MAX_PATH limit on Windows. p is returned
+// unchanged on other platforms.
+func longPath(p string) string {
+	return longPathFor(runtime.GOOS, p)
+}
+
+// longPathFor implements longPath for goos. It's pure string
+// manipulation so it behaves the same on every platform.
+func longPathFor(goos, p string) string {
+	if goos != "windows" || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+
+	p = strings.Replace(p, "/", `\`, -1)
+
+	// UNC paths, \\server\share\...
+	if strings.HasPrefix(p, `\\`) {
+		elems := strings.SplitN(p[2:], `\`, 3)
+		if len(elems) < 3 {
+			return `\\?\UNC\` + p[2:]
+		}
+		return `\\?\UNC\` + elems[0] + `\` + elems[1] + cleanWindowsPath(elems[2])
+	}
+
+	// Only absolute drive paths can be prefixed, \\?\ disables
+	// the normalization that resolves relative paths.
+	if len(p) < 3 || p[1] != ':' || p[2] != '\\' {
+		return p
+	}
+	return `\\?\` + p[:2] + cleanWindowsPath(p[3:])
+}
+
+// cleanWindowsPath resolves "." and ".." elements of the path
+// below a drive or share, which aren't interpreted in \\?\ paths.
+// As at the root of a drive, ".." can't go above it.
+func cleanWindowsPath(p string) string {
+	clean := path.Clean("/" + strings.Replace(p, `\`, "/", -1))
+	return strings.Replace(clean, "/", `\`, -1)
+}
+
+// checkWindowsName returns an error if any element of the slash
+// separated name is a reserved device name on Windows, such as
+// aux.go, which can't be created as a file.
+func checkWindowsName(name string) error {
+	for _, elem := range strings.Split(name, "/") {
+		if reservedWindowsName(elem) {
+			return fmt.Errorf("%q contains %q, which is a reserved name on Windows", name, elem)
+		}
+	}
+	return nil
+}
+
+func reservedWindowsName(elem string) bool {
+	// The extension and trailing spaces or dots are ignored
+	// when matching device names, "aux.go" and "CON " are reserved.
+	base := strings.TrimRight(elem, " .")
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.ToUpper(strings.TrimRight(base, " "))
+
+	switch base {
+	case "CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$":
+		return true
+	}
+	if len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) {
+		return base[3] >= '1' && base[3] <= '9'
+	}
+	return false
+}
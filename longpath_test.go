@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLongPathFor(t *testing.T) {
+	tests := []struct {
+		goos, path, want string
+	}{
+		{"linux", "/home/gopher/.groot/go1.22.1", "/home/gopher/.groot/go1.22.1"},
+		{"darwin", `C:\groot`, `C:\groot`},
+
+		{"windows", `C:\groot\go1.22.1`, `\\?\C:\groot\go1.22.1`},
+		{"windows", `C:/groot/go1.22.1/src`, `\\?\C:\groot\go1.22.1\src`},
+		{"windows", `C:\`, `\\?\C:\`},
+		{"windows", `C:\groot\`, `\\?\C:\groot`},
+		{"windows", `C:\groot\.\go1.22.1\..\tip`, `\\?\C:\groot\tip`},
+		{"windows", `C:\groot\\go1.22.1`, `\\?\C:\groot\go1.22.1`},
+		{"windows", `C:\..\groot`, `\\?\C:\groot`},
+		{"windows", `d:\a\b\..\..\..\c`, `\\?\d:\c`},
+
+		// Already long.
+		{"windows", `\\?\C:\groot\..\x`, `\\?\C:\groot\..\x`},
+		{"windows", `\\?\UNC\server\share\x`, `\\?\UNC\server\share\x`},
+
+		// UNC paths keep their server and share.
+		{"windows", `\\server\share\groot\go1.22.1`, `\\?\UNC\server\share\groot\go1.22.1`},
+		{"windows", `//server/share/groot`, `\\?\UNC\server\share\groot`},
+		{"windows", `\\server\share\..\groot`, `\\?\UNC\server\share\groot`},
+		{"windows", `\\server\share\a\.\b\..`, `\\?\UNC\server\share\a`},
+		{"windows", `\\server\share`, `\\?\UNC\server\share`},
+
+		// Relative paths and paths relative to the current drive
+		// can't be prefixed.
+		{"windows", `groot\go1.22.1`, `groot\go1.22.1`},
+		{"windows", `..\groot`, `..\groot`},
+		{"windows", `C:groot`, `C:groot`},
+		{"windows", `\groot`, `\groot`},
+		{"windows", `C:`, `C:`},
+		{"windows", ``, ``},
+	}
+	for _, tt := range tests {
+		if got := longPathFor(tt.goos, tt.path); got != tt.want {
+			t.Errorf("longPathFor(%q, %q) = %q, want %q", tt.goos, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWindowsName(t *testing.T) {
+	tests := []struct {
+		name string
+		bad  string // the reserved element, empty if name is valid
+	}{
+		{"go/src/runtime/proc.go", ""},
+		{"go/src/cmd/auxiliary.go", ""},
+		{"go/test/console", ""},
+		{"go/misc/com0", ""},
+		{"go/misc/lpt10", ""},
+		{"go/src/aux.go", "aux.go"},
+		{"go/src/AUX", "AUX"},
+		{"go/con/x.go", "con"},
+		{"go/prn.txt.bak", "prn.txt.bak"},
+		{"go/nul ", "nul "},
+		{"go/nul.", "nul."},
+		{"go/com1", "com1"},
+		{"go/LPT9.log", "LPT9.log"},
+		{"go/conin$", "conin$"},
+		{"go/CONOUT$.x", "CONOUT$.x"},
+	}
+	for _, tt := range tests {
+		err := checkWindowsName(tt.name)
+		switch {
+		case tt.bad == "" && err != nil:
+			t.Errorf("checkWindowsName(%q) = %v, want nil", tt.name, err)
+		case tt.bad != "" && (err == nil || !strings.Contains(err.Error(), fmt.Sprintf("contains %q", tt.bad))):
+			t.Errorf("checkWindowsName(%q) = %v, want an error for %q", tt.name, err, tt.bad)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build windows
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLongPathWindows creates and reads back a file whose path is
+// longer than MAX_PATH.
+func TestLongPathWindows(t *testing.T) {
+	dir := t.TempDir()
+	deep := dir
+	for len(deep) < 300 {
+		deep = filepath.Join(deep, strings.Repeat("d", 40))
+	}
+	name := filepath.Join(deep, "file.go")
+
+	long := longPath(name)
+	if !strings.HasPrefix(long, `\\?\`) {
+		t.Fatalf("longPath(%q) = %q, want a \\\\?\\ path", name, long)
+	}
+	if err := os.MkdirAll(longPath(deep), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(long, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(longPath(filepath.Join(deep, "..", filepath.Base(deep), "file.go")))
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("reading back %s = %q, %v", name, data, err)
+	}
+	if err := os.RemoveAll(longPath(dir)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestArchivePathWindows(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := archivePath(dir, "go/src/aux.go"); err == nil {
+		t.Error("archivePath accepted go/src/aux.go")
+	}
+	got, err := archivePath(dir, "go/src/runtime/proc.go")
+	if want := filepath.Join(dir, "src", "runtime", "proc.go"); err != nil || got != want {
+		t.Errorf("archivePath = %q, %v, want %q", got, err, want)
+	}
+}
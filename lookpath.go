@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// lookPath finds name in the directories of pathList the way the
+// shell would, trying each PATHEXT extension on Windows. Unlike
+// exec.LookPath it returns every match, in PATH order.
+func lookPath(name, pathList string) []string {
+	return lookPathFor(runtime.GOOS, name, pathList)
+}
+
+// lookPathFor implements lookPath as on goos, so Windows resolution
+// can be exercised on any platform.
+func lookPathFor(goos, name, pathList string) []string {
+	exts := []string{""}
+	if goos == "windows" {
+		exts = pathExts()
+	}
+
+	var found []string
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			// An empty entry means the working directory in the shell,
+			// but it's never where groot's bin is.
+			continue
+		}
+		for _, ext := range exts {
+			p := filepath.Join(dir, name+ext)
+			if seen[p] || !isExecutableFor(goos, p) {
+				continue
+			}
+			seen[p] = true
+			found = append(found, p)
+			break
+		}
+	}
+	return found
+}
+
+// pathExts returns the extensions Windows tries when resolving
+// a command, lowercased.
+func pathExts() []string {
+	env := os.Getenv("PATHEXT")
+	if env == "" {
+		env = ".com;.exe;.bat;.cmd"
+	}
+	var exts []string
+	for _, ext := range strings.Split(strings.ToLower(env), ";") {
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// isExecutableFor reports whether p is a file goos would run. Windows
+// goes by the extension rather than the mode.
+func isExecutableFor(goos, p string) bool {
+	finfo, err := os.Stat(p)
+	if err != nil || finfo.IsDir() {
+		return false
+	}
+	if goos == "windows" {
+		return true
+	}
+	return finfo.Mode()&0111 != 0
+}
+
+// describeGoResolution explains what `go` resolves to through the
+// current PATH, and why if it isn't the active version.
+func (g *groot) describeGoResolution() string {
+	pathList := os.Getenv("PATH")
+	matches := lookPath("go", pathList)
+
+	var ours string
+	for _, m := range matches {
+		if samePath(filepath.Dir(m), g.paths.active) {
+			ours = m
+			break
+		}
+	}
+
+	onPath := false
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir != "" && samePath(dir, g.paths.active) {
+			onPath = true
+		}
+	}
+
+	switch {
+	case !onPath:
+		if len(matches) > 0 {
+			return fmt.Sprintf("%s is not on PATH, so `go` still resolves to %s (%s); run 'groot env --add-to-profile' or add 'eval \"$(groot env)\"' to your shell's rc file",
+				g.tildePath(g.paths.active), g.tildePath(matches[0]), goVersionOf(matches[0]))
+		}
+		return fmt.Sprintf("%s is not on PATH; run 'groot env --add-to-profile' or add 'eval \"$(groot env)\"' to your shell's rc file", g.tildePath(g.paths.active))
+	case ours == "":
+		return fmt.Sprintf("%s is on PATH but contains no go executable", g.tildePath(g.paths.active))
+	case matches[0] != ours:
+		return fmt.Sprintf("`go` resolves to %s (%s), which shadows %s because it's earlier in PATH",
+			g.tildePath(matches[0]), goVersionOf(matches[0]), g.tildePath(ours))
+	}
+	return fmt.Sprintf("`go` now resolves to %s (%s)", g.tildePath(ours), goVersionOf(ours))
+}
+
+// goVersionOf returns the output of `go version` for the executable p.
+func goVersionOf(p string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, p, "version").Output()
+	if err != nil {
+		return "go version failed: " + err.Error()
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// systemGoBin returns the bin directory of the GOROOT reported by the
+// first go on PATH outside of the directory active, or an empty string
+// if there's none or it fails.
+func systemGoBin(active string) string {
+	for _, p := range lookPath("go", os.Getenv("PATH")) {
+		if samePath(filepath.Dir(p), active) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		out, err := exec.CommandContext(ctx, p, "env", "GOROOT").Output()
+		cancel()
+		if err != nil {
+			debugln("Running", p, "env GOROOT:", err)
+			return ""
+		}
+		goroot := strings.TrimSpace(string(out))
+		if goroot == "" {
+			return ""
+		}
+		return filepath.Join(goroot, "bin")
+	}
+	return ""
+}
+
+// tildePath abbreviates the home directory in p to ~.
+func (g *groot) tildePath(p string) string {
+	if g.homeDir == "" {
+		return p
+	}
+	if rel, err := filepath.Rel(g.homeDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return p
+}
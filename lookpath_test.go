@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLookPath(t *testing.T) {
+	root := t.TempDir()
+	dir := func(name string) string { return filepath.Join(root, name) }
+	files := map[string]os.FileMode{
+		"active/go":      0755,
+		"system/go":      0755,
+		"user/go":        0755,
+		"noexec/go":      0644,
+		"isdir/go/x":     0644,
+		"win/go.exe":     0644,
+		"win/go.bat":     0644,
+		"wincmd/go.cmd":  0644,
+		"wincom/go.com":  0644,
+		"wincom/go.exe":  0644,
+		"winps/go.ps1":   0644,
+		"winbare/go":     0644,
+		"winbare/go.txt": 0644,
+	}
+	for name, mode := range files {
+		writeTestFile(t, filepath.Join(root, filepath.FromSlash(name)), "", mode)
+	}
+	pathList := func(dirs ...string) string {
+		var list []string
+		for _, d := range dirs {
+			if d != "" {
+				d = dir(d)
+			}
+			list = append(list, d)
+		}
+		return strings.Join(list, string(os.PathListSeparator))
+	}
+
+	tests := []struct {
+		name     string
+		goos     string
+		pathext  string
+		pathList string
+		want     []string
+	}{
+		{
+			name:     "shadowed",
+			goos:     "linux",
+			pathList: pathList("system", "active"),
+			want:     []string{"system/go", "active/go"},
+		},
+		{
+			name:     "first",
+			goos:     "linux",
+			pathList: pathList("active", "system", "user"),
+			want:     []string{"active/go", "system/go", "user/go"},
+		},
+		{
+			name:     "missing bin dir",
+			goos:     "linux",
+			pathList: pathList("missing", "active"),
+			want:     []string{"active/go"},
+		},
+		{
+			name:     "none",
+			goos:     "linux",
+			pathList: pathList("missing", "noexec", "isdir"),
+			want:     nil,
+		},
+		{
+			name:     "not executable or a dir",
+			goos:     "linux",
+			pathList: pathList("noexec", "isdir", "user"),
+			want:     []string{"user/go"},
+		},
+		{
+			name:     "empty and repeated entries",
+			goos:     "linux",
+			pathList: pathList("", "active", "", "active", "system"),
+			want:     []string{"active/go", "system/go"},
+		},
+		{
+			name:     "pathext order",
+			goos:     "windows",
+			pathext:  ".EXE;.BAT",
+			pathList: pathList("win"),
+			want:     []string{"win/go.exe"},
+		},
+		{
+			name:     "pathext reordered",
+			goos:     "windows",
+			pathext:  ".BAT;.EXE",
+			pathList: pathList("win"),
+			want:     []string{"win/go.bat"},
+		},
+		{
+			name:     "pathext without dots",
+			goos:     "windows",
+			pathext:  "exe;;CMD",
+			pathList: pathList("winps", "wincmd", "win"),
+			want:     []string{"wincmd/go.cmd", "win/go.exe"},
+		},
+		{
+			name:     "default pathext",
+			goos:     "windows",
+			pathList: pathList("winbare", "wincom", "missing", "win"),
+			want:     []string{"wincom/go.com", "win/go.exe"},
+		},
+		{
+			name:     "windows ignores mode",
+			goos:     "windows",
+			pathext:  ".TXT",
+			pathList: pathList("winbare", "noexec"),
+			want:     []string{"winbare/go.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.goos != "windows" && runtime.GOOS == "windows" {
+				t.Skip("Windows has no executable bit")
+			}
+			t.Setenv("PATHEXT", tt.pathext)
+			var want []string
+			for _, w := range tt.want {
+				want = append(want, filepath.Join(root, filepath.FromSlash(w)))
+			}
+			if got := lookPathFor(tt.goos, "go", tt.pathList); !reflect.DeepEqual(got, want) {
+				t.Errorf("lookPathFor(%s, go, %s) =\n%q\nwant\n%q", tt.goos, tt.pathList, got, want)
+			}
+		})
+	}
+}
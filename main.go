@@ -1,384 +1,1640 @@
 package main
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"crypto/sha256"
-	"encoding/hex"
+	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 func main() {
 	os.Exit(run())
 }
 
-const binaryRelease = "1.9.2"
-
-var distToHash = map[string]string{
-	"android/386":     "",
-	"android/amd64":   "",
-	"android/arm":     "",
-	"android/arm64":   "",
-	"darwin/386":      "",
-	"darwin/amd64":    "73fd5840d55f5566d8db6c0ffdd187577e8ebe650c783f68bd27cbf95bde6743",
-	"darwin/arm":      "",
-	"darwin/arm64":    "",
-	"dragonfly/amd64": "",
-	"freebsd/386":     "809dcb0a8457c8d0abf954f20311a1ee353486d0ae3f921e9478189721d37677",
-	"freebsd/amd64":   "8be985c3e251c8e007fa6ecd0189bc53e65cc519f4464ddf19fa11f7ed251134",
-	"freebsd/arm":     "",
-	"linux/386":       "574b2c4b1a248e58ef7d1f825beda15429610a2316d9cbd3096d8d3fa8c0bc1a",
-	"linux/amd64":     "de874549d9a8d8d8062be05808509c09a88a248e77ec14eb77453530829ac02b",
-	"linux/arm":       "",
-	"linux/arm64":     "0016ac65ad8340c84f51bc11dbb24ee8265b0a4597dbfdf8d91776fc187456fa",
-	"linux/mips":      "",
-	"linux/mips64":    "",
-	"linux/mips64le":  "",
-	"linux/mipsle":    "",
-	"linux/ppc64":     "",
-	"linux/ppc64le":   "adb440b2b6ae9e448c253a20836d8e8aa4236f731d87717d9c7b241998dc7f9d",
-	"linux/s390x":     "a7137b4fbdec126823a12a4b696eeee2f04ec616e9fb8a54654c51d5884c1345",
-	"nacl/386":        "",
-	"nacl/amd64p32":   "",
-	"nacl/arm":        "",
-	"netbsd/386":      "",
-	"netbsd/amd64":    "",
-	"netbsd/arm":      "",
-	"openbsd/386":     "",
-	"openbsd/amd64":   "",
-	"openbsd/arm":     "",
-	"plan9/386":       "",
-	"plan9/amd64":     "",
-	"plan9/arm":       "",
-	"solaris/amd64":   "",
-	"windows/386":     "",
-	"windows/amd64":   "",
-}
-
-var commands = map[string]func(_ groot, args ...string) int{
-	"activate":  activate,
-	"add":       add,
-	"available": available,
-	"env":       env,
-	"init":      initGroot,
-	"list":      list,
+// requiresInit lists the commands that can't run before init.
+var requiresInit = map[string]bool{
+	"activate":   true,
+	"add":        true,
+	"bootstrap":  true,
+	"clean":      true,
+	"compare":    true,
+	"current":    true,
+	"deactivate": true,
+	"direnv":     true,
+	"env":        true,
+	"exec":       true,
+	"go-env":     true,
+	"info":       true,
+	"label":      true,
+	"list":       true,
+	"local":      true,
+	"migrate":    true,
+	"note":       true,
+	"path":       true,
+	"prune":      true,
+	"rebuild":    true,
+	"remove":     true,
+	"rename":     true,
+	"run":        true,
+	"status":     true,
+	"test":       true,
+	"update":     true,
+	"upgrade":    true,
+	"verify":     true,
+	"which":      true,
 }
 
+// Exit codes.
+const (
+	exitError          = 1
+	exitMismatch       = 2 // verify-download got a different hash
+	exitNotInitialized = 3
+	exitNoCToolchain   = 4 // a build needs a C compiler that isn't installed
+)
+
 func run() int {
 	log.SetFlags(log.Lshortfile)
 
-	if len(os.Args) < 2 {
-		fmt.Println(`groot: GOROOT manager`)
-		return 0
+	fs := flag.NewFlagSet("groot", flag.ContinueOnError)
+	home := fs.String("home", "", "use `dir` as the groot directory instead of $GROOT_HOME or $HOME/.groot")
+	shared := fs.Bool("shared", false, "manage a shared installation used by multiple users")
+	var quiet, verbose bool
+	fs.BoolVar(&quiet, "quiet", false, "only print errors and the result of the command")
+	fs.BoolVar(&quiet, "q", false, "shorthand for --quiet")
+	fs.BoolVar(&verbose, "verbose", false, "also print subprocess command lines and extracted files")
+	fs.BoolVar(&verbose, "v", false, "shorthand for --verbose")
+	noProgress := fs.Bool("no-progress", false, "don't print the progress of git clones and fetches")
+	maxRate := fs.String("max-rate", "", "limit downloads to `rate` bytes per second, such as 2MB")
+	err := fs.Parse(os.Args[1:])
+	if err != nil {
+		return 1
+	}
+	setVerbosity(quiet, verbose)
+
+	if fs.NArg() < 1 {
+		return help(nil)
+	}
+	name, args := fs.Arg(0), fs.Args()[1:]
+	if name == "help" {
+		return help(args)
 	}
 
-	cmd, ok := commands[os.Args[1]]
+	c, ok := findCommand(commands, name)
 	if !ok {
-		fmt.Println("unknown subcommand:", os.Args[1])
+		fmt.Println("unknown subcommand:", name)
 		return 1
 	}
+	name = c.name
 
-	// Find Home Directory
-	user, err := user.Current()
-	if err != nil {
-		return printError(err)
+	// The flag takes precedence over GROOT_HOME, which takes precedence
+	// over the directory derived from the user's home.
+	if *home == "" {
+		*home = os.Getenv("GROOT_HOME")
 	}
 
-	if user.HomeDir == "" {
+	// Find Home Directory. It's only required when the groot directory
+	// isn't given, so sandboxes without one can still use --home.
+	var homeDir string
+	if u, err := user.Current(); err == nil {
+		homeDir = u.HomeDir
+	} else if *home == "" {
+		return printError(err)
+	}
+	if homeDir == "" && *home == "" {
 		fmt.Println("Unable to determine user's home directory.")
 		return 1
 	}
 
-	baseDir := filepath.Join(user.HomeDir, ".groot")
-	g := groot{
-		baseDir:   baseDir,
-		gitDir:    filepath.Join(baseDir, ".bare"),
-		binaryDir: filepath.Join(baseDir, ".binary"),
+	var userPaths paths
+	if homeDir != "" {
+		userPaths, err = resolvePaths(homeDir)
+		if err != nil {
+			return printError(fmt.Errorf("loading config: %v", err))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	g := groot{paths: userPaths, homeDir: homeDir, ctx: ctx, noProgress: *noProgress, mirrorFile: os.Getenv("GROOT_CHECKSUM_FILE")}
+	if *home != "" {
+		dir, err := filepath.Abs(*home)
+		if err != nil {
+			return printError(err)
+		}
+		g.paths = legacyPaths(dir)
+	}
+
+	if requiresInit[name] && !g.initialized() {
+		fmt.Println("groot is not initialized; run `groot init`")
+		return exitNotInitialized
+	}
+	if usesClone[name] {
+		err = g.checkClone()
+		if err != nil {
+			return printError(err)
+		}
+	}
+
+	g.config, err = loadConfig(g.paths.config)
+	if err != nil {
+		return printError(fmt.Errorf("loading config: %v", err))
+	}
+
+	if *maxRate == "" {
+		*maxRate = g.config.MaxRate
+	}
+	rate, err := parseRate(*maxRate)
+	if err != nil {
+		return printError(err)
+	}
+	if rate > 0 {
+		g.rateLimit = newRateLimit(rate)
+	}
+
+	if *shared || g.config.Shared {
+		if homeDir == "" {
+			fmt.Println("Unable to determine user's home directory for the shared installation's active version.")
+			return 1
+		}
+		err = g.setShared(userPaths.active)
+		if err != nil {
+			return printError(err)
+		}
+		if mutatesShared[name] {
+			err = checkWritable(g.paths.base)
+			if err != nil {
+				fmt.Println(err)
+				return exitError
+			}
+		}
+	}
+
+	if !reportsActive[name] && g.initialized() {
+		g.checkDanglingActive(name)
+	}
+	if g.config.WarnLocalMismatch && !reportsLocal[name] && g.initialized() {
+		g.warnLocalMismatch()
 	}
 
-	return cmd(g, os.Args[2:]...)
+	return g.runCommand(c, args)
 }
 
 type groot struct {
-	baseDir   string
-	gitDir    string
-	binaryDir string
-	verbose   bool
+	paths      paths
+	homeDir    string
+	config     config
+	noSymlink  bool
+	noProgress bool // print no clone or fetch progress
+	refresh    bool
+	sharedBare string
+	shared     bool
+	arch       string // GOARCH of downloaded binaries, overriding runtime.GOARCH
+
+	minimalBinary bool // leave minimalExclude out of binary installs
+
+	// mirrorFile lists the checksums of every downloadable archive,
+	// replacing all other sources. Parsed into mirrorSums when needed.
+	mirrorFile string
+	mirrorSums map[string]string
+
+	client             *http.Client
+	insecureSkipVerify bool
+	rateLimit          *rateLimit // shared by all downloads, nil if unlimited
+
+	ctx context.Context // canceled on interrupt
+
+	gitBackend gitBackend // chosen by backend
+
+	tagList []string // cached by tags
+}
+
+// initOptions are the fast paths of init.
+type initOptions struct {
+	skipBuild  bool   // set up the bootstrap and clone but build nothing
+	binaryOnly string // only install this binary release
+	force      bool   // replace an existing clone and bootstrap
+	repoURL    string // clone from this mirror instead of defaultRepoURL
 }
 
-func (g *groot) init() error {
+func (g *groot) init(opts initOptions) error {
 	// Create .groot
-	err := os.MkdirAll(g.baseDir, 0700)
+	err := mkdirAll(g.paths.base)
 	if err != nil {
 		return err
 	}
 
-	// Download binary release
-	err = downloadBinaryRelease(g.binaryDir)
-	if err != nil {
-		return err
+	// Recorded even for --binary-only, for the clone of the first
+	// source build, and the fetches of later updates.
+	setRepoURL := func() error {
+		return g.updateState(func(s *state) error {
+			s.RepoURL = opts.repoURL
+			return nil
+		})
 	}
 
-	// Clone bare repo
-	err = g.exec("git", "clone", "--bare", "https://go.googlesource.com/go", g.gitDir)
+	if opts.binaryOnly != "" {
+		err = setRepoURL()
+		if err != nil {
+			return err
+		}
+		return g.initBinaryOnly(opts.binaryOnly)
+	}
+
+	if g.cloned() && !opts.force {
+		return fmt.Errorf("%s already exists; use init --force to clone it again, or repair --reclone to keep the installed versions", g.paths.git)
+	}
+	err = setRepoURL()
 	if err != nil {
 		return err
 	}
+	if g.cloned() {
+		for _, dir := range []string{g.paths.git, g.paths.binary} {
+			infoln("Removing", dir)
+			makeWritable(dir)
+			err = os.RemoveAll(dir)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
-	// https://stackoverflow.com/questions/39882988/git-bare-repo-cannot-have-a-worktree-for-master-branch-why
-	err = g.git("update-ref", "--no-deref", "HEAD", "HEAD^{commit}")
+	bootstrap, err := g.initSource()
 	if err != nil {
 		return err
 	}
 
+	if opts.skipBuild {
+		fmt.Println()
+		fmt.Println("groot initialized.")
+		fmt.Println("Bootstrap: go" + bootstrap)
+		fmt.Println("No versions were built; use `groot add` to build one.")
+		return nil
+	}
+
 	// Create worktrees
 	tags := []string{"go1.7", "go1.9"} // TODO: install latest
+	var summary []string
+	active := ""
 	for _, tag := range tags {
-		g.branchAndBuild(tag)
+		start := time.Now()
+		err := g.branchAndBuild(buildOptions{tag: tag})
+		if err != nil {
+			summary = append(summary, fmt.Sprintf("  %s failed: %v", tag, err))
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("  %s built in %s", tag, time.Since(start).Round(time.Second)))
+		active = tag
+	}
+
+	if active != "" {
+		err = g.activate(active)
+		if err != nil {
+			return err
+		}
 	}
 
-	activeBranch := filepath.Join(g.baseDir, tags[len(tags)-1], "bin")
-	activePath := filepath.Join(g.baseDir, "bin")
-	return os.Symlink(activeBranch, activePath)
+	fmt.Println()
+	fmt.Println("groot initialized.")
+	fmt.Println("Bootstrap: go" + bootstrap)
+	fmt.Println("Versions:")
+	for _, line := range summary {
+		fmt.Println(line)
+	}
+	if active == "" {
+		return errors.New("no versions were built successfully")
+	}
+	fmt.Println("Active:", active)
+	fmt.Println()
+	fmt.Println(`Run 'groot env --add-to-profile', or add 'eval "$(groot env)"' to your shell's rc file, to put the active version on your PATH.`)
+	return nil
 }
 
-func (g *groot) activate(tag string) error {
-	bin := filepath.Join(g.baseDir, tag, "bin")
+// initBinaryOnly installs and activates the binary release tag,
+// leaving the bootstrap and clone for the first source build.
+func (g *groot) initBinaryOnly(tag string) error {
+	tag = normalizeTag(tag)
 
-	_, err := os.Stat(bin)
+	err := g.updateState(func(s *state) error {
+		s.BinaryOnly = true
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	activePath := filepath.Join(g.baseDir, "bin")
-	err = os.Remove(activePath)
-	if err != nil && !os.IsNotExist(err) {
+	if !g.exists(tag) {
+		err = g.installBinary(tag)
+		if err != nil {
+			return err
+		}
+	}
+	err = g.activate(tag)
+	if err != nil {
 		return err
 	}
 
-	return os.Symlink(bin, activePath)
+	fmt.Println()
+	fmt.Println("groot initialized with", tag+".")
+	fmt.Println("The Go repository will be cloned when a version is first built from source.")
+	fmt.Println()
+	fmt.Println(`Run 'groot env --add-to-profile', or add 'eval "$(groot env)"' to your shell's rc file, to put the active version on your PATH.`)
+	return nil
 }
 
-func (g *groot) git(args ...string) error {
-	return g.exec("git", append([]string{"--git-dir", g.gitDir}, args...)...)
+// ensureClone performs the source setup skipped by init --binary-only.
+func (g *groot) ensureClone() error {
+	if g.cloned() {
+		return nil
+	}
+	s, err := g.loadState()
+	if err != nil {
+		return err
+	}
+	if !s.BinaryOnly {
+		return nil
+	}
+
+	infoln("Cloning the Go repository for the first source build")
+	_, err = g.initSource()
+	if err != nil {
+		return err
+	}
+	return g.updateState(func(s *state) error {
+		s.BinaryOnly = false
+		return nil
+	})
 }
 
-func (g *groot) exec(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// cloned reports whether the bare repo exists.
+func (g *groot) cloned() bool {
+	_, err := os.Stat(g.paths.git)
+	return err == nil
+}
+
+// initSource downloads the bootstrap toolchain and clones the bare
+// repo, returning the bootstrap version.
+func (g *groot) initSource() (string, error) {
+	// Download binary release
+	bootstrap, err := g.downloadBinaryRelease(g.paths.binary)
+	if err != nil {
+		return "", err
+	}
 
-	if g.verbose {
-		fmt.Println("Running:", name, strings.Join(args, " "))
+	// Clone bare repo
+	if g.sharedBare != "" {
+		err = g.requireExecGit("--bare-dir-reuse")
+		if err != nil {
+			return "", err
+		}
+		// Objects are borrowed from the shared repo, only refs and
+		// worktree metadata are written to the per-user clone.
+		err = checkSharedBare(g.sharedBare)
+		if err != nil {
+			return "", err
+		}
+	}
+	repo := g.repoURL()
+	err = g.backend().clone(repo, g.paths.git, g.sharedBare)
+	if err != nil {
+		// A partial clone would make a retry fail.
+		warnln("Removing incomplete clone", g.paths.git)
+		os.RemoveAll(g.paths.git)
+		return "", fmt.Errorf("cloning %s: %v", repo, err)
 	}
 
-	return cmd.Run()
+	return bootstrap, nil
 }
 
-func (g *groot) branchAndBuild(tag string) error {
-	_, err := os.Stat(filepath.Join(g.baseDir, tag))
-	if !os.IsNotExist(err) {
-		return err
+// initialized reports whether init has created the groot
+// directory and bare repo, or only the directory with --binary-only.
+func (g *groot) initialized() bool {
+	_, err := os.Stat(g.paths.base)
+	if err != nil {
+		return false
 	}
+	if g.cloned() {
+		return true
+	}
+	s, err := g.loadState()
+	return err == nil && s.BinaryOnly
+}
+
+// checkSharedBare verifies that dir is a bare repo that
+// can be used as a clone reference.
+func checkSharedBare(dir string) error {
+	out, err := gitCommand(context.Background(), "--git-dir", dir, "rev-parse", "--is-bare-repository").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("shared bare repo %s is not a readable bare git repository", dir)
+	}
+	return nil
+}
 
-	branch := "groot." + tag
+// activate points the bin directory at tag's bin directory, using a
+// symlink where possible and a directory of shim scripts otherwise.
+func (g *groot) activate(tag string) error {
+	bin := filepath.Join(g.versionDir(tag), "bin")
 
-	err = g.git("branch", branch, tag)
+	err := g.restoreSnapshot(tag)
 	if err != nil {
 		return err
 	}
 
-	worktreePath := filepath.Join(g.baseDir, tag)
-	err = g.git("worktree", "add", worktreePath, branch)
+	_, err = os.Stat(bin)
 	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command("./make.bash")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Dir = filepath.Join(worktreePath, "src")
-	cmd.Env = append(os.Environ(), "GOROOT_BOOTSTRAP="+g.binaryDir)
-	return cmd.Run()
-}
+	// Shims are always rewritten, the version's set of
+	// executables may have changed.
+	if !g.noSymlink && g.linkedTo(bin) {
+		return nil
+	}
 
-func (g *groot) list() error {
-	return g.git("worktree", "list")
-}
+	activePath := g.paths.active
+	err = os.MkdirAll(filepath.Dir(activePath), stateDirMode)
+	if err != nil {
+		return err
+	}
+
+	if g.noSymlink {
+		err = g.deactivate()
+		if err != nil {
+			return err
+		}
+		return writeShims(activePath, tag, bin)
+	}
 
-func env(g groot, args ...string) int {
-	fmt.Printf("export PATH=\"$PATH:%s\"\n", filepath.Join(g.baseDir, "bin"))
+	target := bin
+	if g.config.ShimGenerations {
+		target, err = g.newShimGeneration(tag, bin)
+		if err != nil {
+			return err
+		}
+	}
 
-	finfos, err := ioutil.ReadDir(g.baseDir)
+	// Create the link beside the active one and rename it into
+	// place, so bin doesn't disappear while switching versions.
+	tmp := activePath + ".tmp"
+	os.Remove(tmp)
+	err = os.Symlink(target, tmp)
+	if symlinkUnsupported(err) {
+		warnln("Unable to create symlink, falling back to shims:", err)
+		err = g.deactivate()
+		if err != nil {
+			return err
+		}
+		return writeShims(activePath, tag, bin)
+	}
 	if err != nil {
-		return printError(err)
+		return err
 	}
 
-	for _, finfo := range finfos {
-		name := finfo.Name()
-		if name == "bin" || strings.HasPrefix(name, ".") {
-			continue
+	err = os.Rename(tmp, activePath)
+	if err != nil {
+		// Shim directories, and symlinks on some platforms,
+		// can't be replaced by a rename.
+		err = g.deactivate()
+		if err == nil {
+			err = os.Rename(tmp, activePath)
 		}
-
-		fmt.Printf("alias %s=%s\n", name, filepath.Join(g.baseDir, name, "bin/go"))
 	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
 
-	return 0
+// linkedTo reports whether the active link is a symlink to bin, or to
+// a shim generation of its version.
+func (g *groot) linkedTo(bin string) bool {
+	target, err := os.Readlink(g.paths.active)
+	if err != nil {
+		return false
+	}
+	if tag, err := readShimMarker(target); err == nil {
+		return filepath.Join(g.versionDir(tag), "bin") == bin
+	}
+	return target == bin
 }
 
-func add(g groot, args ...string) int {
-	if len(args) < 1 {
-		fmt.Println(os.Args[0], "add [tag]")
-		return 1
+// deactivate removes the bin symlink or shim directory.
+func (g *groot) deactivate() error {
+	activePath := g.paths.active
+
+	finfo, err := os.Lstat(activePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if finfo.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(activePath)
 	}
-	tag := args[0]
 
-	err := g.branchAndBuild(tag)
+	// Make sure it's ours before removing a directory.
+	_, err = readShimMarker(activePath)
 	if err != nil {
-		return printError(err)
+		return err
 	}
-	return 0
+	return os.RemoveAll(activePath)
 }
 
-func list(g groot, _ ...string) int {
-	err := g.git("worktree", "list")
+// activeVersion returns the name of the active version,
+// or an empty string if no version is active.
+func (g *groot) activeVersion() (string, error) {
+	activePath := g.paths.active
+
+	finfo, err := os.Lstat(activePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
 	if err != nil {
-		return printError(err)
+		return "", err
 	}
-	return 0
-}
 
-func activate(g groot, args ...string) int {
-	if len(args) < 1 {
-		fmt.Println(os.Args[0], "activate [tag]")
-		return 1
+	if finfo.Mode()&os.ModeSymlink == 0 {
+		return readShimMarker(activePath)
 	}
-	tag := args[0]
 
-	err := g.activate(tag)
+	target, err := os.Readlink(activePath)
 	if err != nil {
-		return printError(err)
+		return "", err
+	}
+	// A shim generation records its version.
+	if tag, err := readShimMarker(target); err == nil {
+		return tag, nil
 	}
-	fmt.Println(tag, "activated!")
-	return 0
+	return filepath.Base(filepath.Dir(target)), nil
+}
+
+func (g *groot) git(args ...string) error {
+	return g.runGit(g.gitArgs(args...)...)
+}
+
+// gitArgs prefixes args with the options used for every git
+// invocation against the bare repo.
+func (g *groot) gitArgs(args ...string) []string {
+	return append([]string{"--git-dir", g.paths.git}, args...)
+}
+
+// gitOutput runs git against the bare repo and returns its stdout.
+func (g *groot) gitOutput(args ...string) (string, error) {
+	cmd := gitCommand(g.context(), g.gitArgs(args...)...)
+	cmd.Stderr = os.Stderr
+	debugln("Running: git", strings.Join(g.gitArgs(args...), " "))
+
+	start := time.Now()
+	out, err := cmd.Output()
+	g.recordOp(cmd, start, err)
+	return string(out), err
 }
 
-func available(g groot, _ ...string) int {
-	err := g.git("tag", "--list", "go*")
+// tags returns the release tags in the bare repo, in ascending order.
+// The list is cached for the rest of the command and on disk.
+func (g *groot) tags() ([]string, error) {
+	if g.tagList != nil {
+		return g.tagList, nil
+	}
+	if tags := g.cachedTags(); tags != nil {
+		g.tagList = tags
+		return tags, nil
+	}
+
+	tags, err := g.backend().tags()
 	if err != nil {
-		return printError(err)
+		return nil, err
 	}
-	return 0
+
+	sortTags(tags)
+	g.cacheTags(tags)
+	g.tagList = tags
+	return tags, nil
 }
 
-func initGroot(g groot, _ ...string) int {
-	err := g.init()
+// tagDates returns the date of the commit each release tag in the bare
+// repo points to. Annotated tags are peeled, so the date is that of the
+// release commit rather than of the tag object.
+func (g *groot) tagDates() (map[string]time.Time, error) {
+	err := g.requireExecGit("--since-date")
 	if err != nil {
-		return printError(err)
+		return nil, err
+	}
+	out, err := g.gitOutput("for-each-ref",
+		"--format=%(refname:strip=2) %(*committerdate:unix) %(committerdate:unix)",
+		"refs/tags/go*")
+	if err != nil {
+		return nil, fmt.Errorf("reading tag dates: %v", err)
+	}
+	dates := make(map[string]time.Time)
+	for _, line := range strings.Split(out, "\n") {
+		// Lightweight tags have no peeled date, leaving two fields.
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		dates[fields[0]] = time.Unix(sec, 0)
 	}
-	return 0
+	return dates, nil
 }
 
-func printError(err error) int {
-	log.Println("Error:", err)
-	return 1
+// context returns the context commands are run with, which is
+// canceled when groot is interrupted.
+func (g *groot) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
 }
 
-func downloadBinaryRelease(dir string) error {
-	dist := runtime.GOOS + "/" + runtime.GOARCH
-	hash, ok := distToHash[dist]
-	if !ok {
-		return fmt.Errorf("Unknown OS/Architecture: %s", dist)
+// reservedNames are entries in the versions directory that aren't versions.
+var reservedNames = map[string]bool{
+	"bin":       true,
+	"cache":     true,
+	"manifests": true,
+}
+
+// resolveInstalled returns the installed version spec refers to,
+// see resolveTag.
+func (g *groot) resolveInstalled(spec string, prerelease bool) (string, error) {
+	names, err := g.installed()
+	if err != nil {
+		return "", err
 	}
-	if hash == "" {
-		return fmt.Errorf("Unsupported OS/Architecture: %s", dist)
+	name, err := resolveTag(spec, names, prerelease)
+	if err != nil {
+		// Archived snapshots aren't directories yet.
+		if isSnapshot(spec) && g.exists(spec) {
+			return spec, nil
+		}
+		if _, anyErr := resolveTag(spec, names, true); anyErr != nil {
+			return "", g.notInstalled(spec)
+		}
+		return "", fmt.Errorf("%v among installed versions", err)
 	}
+	return name, nil
+}
 
-	url := fmt.Sprintf("https://redirector.gvt1.com/edgedl/go/go%s.%s-%s.tar.gz", binaryRelease, runtime.GOOS, runtime.GOARCH)
-	resp, err := http.Get(url)
+// installed returns the names of the installed versions.
+func (g *groot) installed() ([]string, error) {
+	finfos, err := ioutil.ReadDir(g.paths.base)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var names []string
+	for _, finfo := range finfos {
+		name := finfo.Name()
+		if !finfo.IsDir() || reservedNames[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		names = append(names, name)
 	}
-	defer resp.Body.Close()
+	return names, nil
+}
+
+func env(fs *flag.FlagSet, g *groot) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print the variables as a JSON object")
+	gorootOnly := fs.Bool("goroot-only", false, "with a version, only set GOROOT")
+	addToProfile := fs.Bool("add-to-profile", false, "add the line that runs env to your shell's rc file")
+	return func(args []string) error {
+		var err error
+		if len(args) > 1 || *gorootOnly && len(args) == 0 || *addToProfile && (len(args) > 0 || *asJSON) {
+			return errUsage
+		}
+
+		if *addToProfile {
+			err = g.addToProfile()
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+
+		if len(args) == 0 {
+			bin, err := g.envBin()
+			if err != nil {
+				return err
+			}
+			if *asJSON {
+				vars := make(map[string]string)
+				if bin != "" {
+					vars["PATH"] = os.Getenv("PATH") + string(os.PathListSeparator) + bin
+				}
+				return printEnvJSON(vars)
+			}
+
+			switch {
+			case bin == "":
+				fmt.Println("# No version is active and there's no go on PATH to fall back to")
+			case bin != g.paths.active:
+				fmt.Printf("# No version is active; falling back to the go in %s\n", filepath.Dir(bin))
+				fallthrough
+			default:
+				fmt.Printf("export PATH=\"$PATH:%s\"\n", bin)
+			}
 
-	if resp.StatusCode != 200 {
-		log.Println("Downloading binary release: unexpected status:", resp.Status)
-		if ct := resp.Header.Get("Content-Type"); strings.Contains(ct, "text/plain") {
-			body, err := ioutil.ReadAll(resp.Body)
-			if err == nil {
-				log.Println(string(body))
+			names, err := g.installed()
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				fmt.Printf("alias %s=%s\n", name, filepath.Join(g.versionDir(name), "bin/go"))
 			}
+			return nil
 		}
-		return errors.New("")
+
+		name := args[0]
+		if !g.exists(name) {
+			return fmt.Errorf("%s isn't installed; see `groot list`", name)
+		}
+		return g.printVersionEnv(name, *asJSON, *gorootOnly)
 	}
+}
 
-	hasher := sha256.New()
+// envBin returns the directory env adds to PATH: groot's bin if
+// it resolves, otherwise the bin directory of the system's go, so a
+// sourced env doesn't add a missing directory while no version is
+// active. It's empty if there's neither.
+func (g *groot) envBin() (string, error) {
+	_, err := os.Stat(g.paths.active)
+	if err == nil {
+		return g.paths.active, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	return systemGoBin(g.paths.active), nil
+}
 
-	err = extractTarGz(io.TeeReader(resp.Body, hasher), dir)
+// printVersionEnv prints the environment that uses the installed
+// version name in the current shell only.
+func (g *groot) printVersionEnv(name string, asJSON, gorootOnly bool) error {
+	err := g.restoreSnapshot(name)
 	if err != nil {
 		return err
 	}
 
-	if got := hex.EncodeToString(hasher.Sum(nil)); got != hash {
-		log.Println("Downloaded binary release does not match published SHA256 hash.")
-		fmt.Println(hash)
-		fmt.Println(got)
-		return errors.New("")
+	dir := g.versionDir(name)
+	bin := filepath.Join(dir, "bin")
+	if asJSON {
+		vars := map[string]string{"GOROOT": dir}
+		if !gorootOnly {
+			vars["PATH"] = bin + string(os.PathListSeparator) + os.Getenv("PATH")
+		}
+		return printEnvJSON(vars)
 	}
 
+	fmt.Printf("export GOROOT=\"%s\"\n", dir)
+	if !gorootOnly {
+		fmt.Printf("export PATH=\"%s:$PATH\"\n", bin)
+	}
 	return nil
 }
 
-func extractTarGz(r io.Reader, dir string) error {
-	gr, err := gzip.NewReader(r)
+// printEnvJSON prints the final values of vars as a JSON object.
+func printEnvJSON(vars map[string]string) error {
+	out, err := json.MarshalIndent(vars, "", "\t")
 	if err != nil {
 		return err
 	}
-	tr := tar.NewReader(gr)
+	fmt.Println(string(out))
+	return nil
+}
 
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
+func add(fs *flag.FlagSet, g *groot) func(args []string) error {
+	var opts buildOptions
+	fs.StringVar(&opts.goamd64, "goamd64", "", "build with GOAMD64 set to `level` (v1-v4)")
+	fs.StringVar(&opts.goarm, "goarm", "", "build with GOARM set to `version` (5-7)")
+	fs.StringVar(&opts.experiment, "experiment", "", "build with GOEXPERIMENT set to `names`, comma separated")
+	fs.StringVar(&opts.as, "name", "", "install the build as `name` instead of one derived from the tag and options")
+	fs.BoolVar(&opts.test, "test", false, "run the std tests with run.bash after building")
+	fs.DurationVar(&opts.testTimeout, "timeout", 0, "abort --test after `duration`")
+	fs.StringVar(&opts.bootstrap, "bootstrap", "", "use the installed `version` as GOROOT_BOOTSTRAP")
+	fs.StringVar(&opts.gorootFinal, "goroot-final", "", "build with GOROOT_FINAL set to `path`, where the toolchain will be installed")
+	fs.Var(envFileFlag{&opts.extraEnv}, "env-file", "add KEY=VALUE lines from `file` to the build environment")
+	fs.Var(envFlag{&opts.extraEnv}, "env", "add `KEY=VALUE` to the build environment (repeatable)")
+	binary := fs.Bool("binary", false, "install the official binary release instead of building from source")
+	force := fs.Bool("force", false, "remove an existing install of the version and install it again")
+	fs.BoolVar(&opts.minimal, "minimal", false, "leave test/, doc/, and api/ out of the checkout or binary release to save space")
+	fs.BoolVar(&opts.discardObjects, "discard-objects", false, "remove intermediate build objects after building")
+	fs.BoolVar(&opts.detach, "detach", false, "remove the git metadata after building; the version can't be rebuilt in place")
+	fs.StringVar(&opts.logFile, "log-file", "", "also write the output of make.bash to `path`")
+	fs.BoolVar(&opts.quiet, "quiet", false, "only write the output of make.bash to the log file")
+	fs.DurationVar(&opts.stallTimeout, "stall-timeout", 0, "abort the build if make.bash prints nothing for `duration`")
+	fs.StringVar(&g.arch, "arch", "", "with --binary, install binaries for `GOARCH` instead of the host architecture")
+	keep := fs.Int("keep", g.config.MaxVersions, "keep at most `n` installed versions, removing the oldest")
+	from := fs.String("from", "", "install the binary release archive `file` instead of downloading it")
+	inspect := fs.Bool("inspect", false, "with --from, list the archive's entries and check them without installing")
+	fs.StringVar(&g.mirrorFile, "mirror-file", g.mirrorFile, "verify downloads only against the checksums listed in `file`")
+	parallel := fs.Bool("parallel-download", false, "with --binary and several versions, download them concurrently")
+	jobs := fs.Int("jobs", 4, "with --parallel-download, download at most `n` versions at a time")
+	rc := fs.Bool("rc", false, "install the newest beta or release candidate of the release in progress")
+	branch := fs.String("branch", "", "build the head of `branch` instead of a tag, installed under the branch's name")
+	fs.BoolVar(&opts.noBuild, "no-build", false, "only check out the source, without running make.bash")
+	return func(args []string) error {
+		var err error
+		if *inspect {
+			if *from == "" {
+				return usageErrorf("--inspect requires --from")
+			}
+			return inspectFrom(*from)
 		}
-		if err != nil {
-			return err
+		if *from != "" {
+			*binary = true
+			if len(args) == 0 {
+				if tag, ok := archiveTag(*from); ok {
+					args = []string{tag}
+				}
+			}
 		}
 
-		name := filepath.Join(dir, strings.TrimPrefix(hdr.Name, "go"))
-
-		switch hdr.Typeflag {
-		case tar.TypeDir:
-			fmt.Printf("Directory: %s\n", name)
-			err := os.MkdirAll(name, os.FileMode(hdr.Mode))
-			if err != nil {
-				return err
+		if *rc {
+			if len(args) > 0 || *from != "" {
+				return usageErrorf("--rc can't be used with a version or --from")
 			}
-		case tar.TypeReg:
-			fmt.Printf("File: %s\n", name)
-			f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(hdr.Mode))
+			tag, err := g.latestPrerelease(*binary)
 			if err != nil {
 				return err
 			}
-			_, err = io.Copy(f, tr)
-			if err != nil {
-				return err
+			infoln("Resolved --rc to", tag)
+			args = []string{tag}
+		}
+
+		if *branch != "" {
+			if len(args) > 0 || *binary || *rc {
+				return usageErrorf("--branch can't be used with a version, --binary, --from, or --rc")
+			}
+			args = []string{*branch}
+			// Branch names may contain slashes, install names can't.
+			if opts.as == "" && strings.Contains(*branch, "/") {
+				opts.as = strings.Replace(*branch, "/", "-", -1)
 			}
-		default:
-			return fmt.Errorf("Unexpected type %c\n", hdr.Typeflag)
 		}
-	}
 
-	return nil
+		if len(args) < 1 {
+			return errUsage
+		}
+		for i, arg := range args {
+			args[i] = normalizeTag(arg)
+		}
+		g.minimalBinary = opts.minimal && (*binary || *from != "")
+		if len(args) > 1 {
+			return g.addBinaries(args, *binary && *from == "", *force, *parallel, *jobs, *keep)
+		}
+		opts.tag = args[0]
+		if *branch != "" {
+			// Taken as is, even if it looks like a version.
+			opts.tag = *branch
+		}
+
+		if !*binary && g.arch != "" {
+			return usageErrorf("--arch requires --binary")
+		}
+		if opts.noBuild && (*binary || opts.test || opts.discardObjects || opts.detach) {
+			return usageErrorf("--no-build can't be used with --binary, --from, --test, --discard-objects, or --detach")
+		}
+		if *binary && opts.detach {
+			return usageErrorf("--detach can't be used with --binary")
+		}
+		if *binary && opts.as != "" {
+			return usageErrorf("--name can't be used with --binary; use `groot rename` after installing")
+		}
+		// Branch names are only constrained by git, so like --name
+		// they're checked even without a slash.
+		if opts.as != "" || *branch != "" {
+			newName := opts.name()
+			names, err := g.installed()
+			if err != nil {
+				return err
+			}
+			// Reinstalling under the same name is handled by --force below.
+			var others []string
+			for _, n := range names {
+				if n != newName {
+					others = append(others, n)
+				}
+			}
+			err = checkName(newName, others)
+			if err != nil {
+				return err
+			}
+		}
+
+		name := opts.tag
+		if !*binary {
+			name = opts.name()
+
+			err = opts.validate()
+			if err != nil {
+				return err
+			}
+
+			if !opts.noBuild {
+				err = checkCToolchain(opts)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = g.ensureClone()
+			if err != nil {
+				return err
+			}
+
+			if *branch != "" {
+				err = g.checkBranch(*branch)
+			} else {
+				err = g.checkTag(opts)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = g.checkExperiments(opts)
+			if err != nil {
+				return err
+			}
+
+			// Checked out for building by hand, the bootstrap is the
+			// user's choice.
+			if !opts.noBuild {
+				if opts.bootstrap == "" {
+					opts.bootstrap = g.chooseBootstrap(name, opts)
+				}
+				err = g.checkBootstrap(opts)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		var reactivate bool
+		if _, err := os.Stat(longPath(g.versionDir(name))); err == nil {
+			if !*force {
+				return fmt.Errorf("%s is already installed; use --force to rebuild", name)
+			}
+
+			// The active link is kept and refreshed once reinstalled.
+			active, err := g.activeVersion()
+			if err != nil {
+				return err
+			}
+			reactivate = active == name
+			if _, err := readShimMarker(g.paths.active); reactivate && err == nil {
+				g.noSymlink = true
+			}
+
+			infoln("Removing", name)
+			err = g.removeVersion(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case *from != "":
+			err = g.installArchive(opts.tag, *from)
+		case *binary:
+			g.warnTranslated()
+			err = g.installBinary(opts.tag)
+		default:
+			err = g.branchAndBuild(opts)
+		}
+		if err != nil {
+			if reactivate {
+				warnf("%s was active and is no longer installed", name)
+			}
+			return err
+		}
+
+		if reactivate && opts.noBuild {
+			warnf("%s was active and isn't built; activate it once it is\n", name)
+			reactivate = false
+		}
+		if reactivate {
+			err = g.activate(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		if *keep > 0 {
+			err = g.removeOldest(*keep, name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addBinaries installs several binary releases for add.
+func (g *groot) addBinaries(tags []string, binary, force, parallel bool, jobs, keep int) error {
+	if !binary {
+		return usageErrorf("several versions can only be added with --binary")
+	}
+	if force {
+		return usageErrorf("--force takes a single version")
+	}
+	if !parallel {
+		jobs = 1
+	}
+
+	var todo []string
+	for _, tag := range tags {
+		if _, err := os.Stat(longPath(g.versionDir(tag))); err == nil {
+			infoln(tag, "is already installed")
+			continue
+		}
+		todo = append(todo, tag)
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	g.warnTranslated()
+	err := g.installBinaries(todo, jobs)
+	if err != nil {
+		return err
+	}
+
+	if keep > 0 {
+		err = g.removeOldest(keep, todo[len(todo)-1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func which(g *groot, args []string) error {
+	name, err := g.resolveInstalled(args[0], false)
+	if err != nil {
+		return err
+	}
+	err = g.restoreSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	gobin := filepath.Join(g.versionDir(name), "bin", "go")
+	if runtime.GOOS == "windows" {
+		gobin += ".exe"
+	}
+
+	_, err = os.Stat(gobin)
+	if err != nil {
+		return err
+	}
+	fmt.Println(gobin)
+	return nil
+}
+
+// pathCmd prints the bin directory to put first on PATH for the active
+// version, or for an installed version, for tools that prepend it
+// themselves instead of evaluating the output of env.
+func pathCmd(g *groot, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 0 {
+		fmt.Println(g.paths.active)
+		return nil
+	}
+
+	name, err := g.resolveInstalled(args[0], false)
+	if err != nil {
+		return err
+	}
+	err = g.restoreSnapshot(name)
+	if err != nil {
+		return err
+	}
+	bin := filepath.Join(g.versionDir(name), "bin")
+	_, err = os.Stat(filepath.Join(bin, exeName("go")))
+	if err != nil {
+		return err
+	}
+	fmt.Println(bin)
+	return nil
+}
+
+func list(fs *flag.FlagSet, g *groot) func(args []string) error {
+	showBootstrap := fs.Bool("bootstrap", false, "show the bootstrap toolchain that built each version")
+	long := fs.Bool("long", false, "show where each version came from, and its labels and note")
+	tree := fs.Bool("tree", false, "group versions by minor release line, newest first")
+	showSize := fs.Bool("size", false, "show the disk usage of each version, and of each group with --tree")
+	var filters labelFilters
+	fs.Var(&filters, "label", "only list versions whose labels match `filter`: key=value, key!=value, key, or !key; repeatable")
+	return func(args []string) error {
+		names, err := g.installed()
+		if err != nil {
+			return err
+		}
+
+		active, err := g.activeVersion()
+		if err != nil {
+			warnln("Determining active version:", err)
+		}
+
+		snaps, err := g.snapshots()
+		if err != nil {
+			return err
+		}
+
+		s, err := g.loadState()
+		if err != nil {
+			return err
+		}
+
+		// Marking EOL versions is best effort, the list is still useful
+		// without it.
+		supported, err := g.supportWindow()
+		if err != nil {
+			debugln("Determining the supported releases:", err)
+		}
+		eolNotes := func(name string) string {
+			tag := name
+			if inst, ok := s.Installs[name]; ok {
+				tag = inst.Tag
+			}
+			if eol(tag, supported) {
+				return "\t" + eolNote
+			}
+			return ""
+		}
+
+		notes := func(name string) string {
+			inst, ok := s.Installs[name]
+			if !ok {
+				if *long {
+					return "\t" + sourceUnknown + eolNotes(name)
+				}
+				return eolNotes(name)
+			}
+			var notes string
+			if *long {
+				i := *inst
+				g.backfillProvenance(&i, true)
+				notes += "\t" + i.Provenance.String()
+			}
+			if *showBootstrap && inst.Kind == kindSource {
+				notes += "\tbootstrap " + inst.bootstrapDescription()
+			}
+			if inst.Experiment != "" {
+				notes += "\tGOEXPERIMENT=" + inst.Experiment
+			}
+			if inst.Detached {
+				notes += "\t(detached)"
+			}
+			if inst.Failed != "" {
+				notes += "\tFAILED smoke test"
+			}
+			if g.unbuilt(name, *inst) {
+				notes += "\tnot built"
+			}
+			if *long && len(inst.Labels) > 0 {
+				notes += "\t" + formatLabels(inst.Labels)
+			}
+			if *long && inst.Note != "" {
+				notes += "\t" + strconv.Quote(inst.Note)
+			}
+			return notes + eolNotes(name)
+		}
+		selected := func(name string) bool {
+			return filters.match(s.Installs[name])
+		}
+
+		marker := func(name string) string {
+			if name == active {
+				return "*"
+			}
+			return " "
+		}
+		sizes := make(map[string]int64)
+		size := func(name string) (int64, string) {
+			if !*showSize {
+				return 0, ""
+			}
+			n, ok := sizes[name]
+			if !ok {
+				var err error
+				n, err = dirSize(g.versionDir(name))
+				if err != nil {
+					return 0, "\t?"
+				}
+				sizes[name] = n
+			}
+			return n, "\t" + formatSize(n)
+		}
+
+		// In the tree, versions are indented under their group.
+		indent := ""
+		if *tree {
+			indent = "  "
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		// Snapshots are listed under tip, newest first.
+		printSnapshots := func() {
+			for i := len(snaps) - 1; i >= 0; i-- {
+				snap := snaps[i]
+				if !selected(snap.name) {
+					continue
+				}
+				if snap.archived {
+					fmt.Fprintf(w, "%s%s   %s\t%s\t%s\n", indent, marker(snap.name), snap.name, "archived", g.snapshotArchive(snap.name))
+					continue
+				}
+				_, sizeCol := size(snap.name)
+				fmt.Fprintf(w, "%s%s   %s\t%s\t%s%s\n", indent, marker(snap.name), snap.name, g.installPlatform(snap.name), g.versionDir(snap.name), sizeCol)
+			}
+			snaps = nil
+		}
+		printVersion := func(name string) {
+			if selected(name) {
+				_, sizeCol := size(name)
+				fmt.Fprintf(w, "%s%s %s\t%s\t%s%s%s\n", indent, marker(name), name, g.installPlatform(name), g.versionDir(name), sizeCol, notes(name))
+			}
+			if name == tipTag {
+				printSnapshots()
+			}
+		}
+
+		var versions []string
+		for _, name := range names {
+			if !isSnapshot(name) {
+				versions = append(versions, name)
+			}
+		}
+		if *tree {
+			tags := make(map[string]string)
+			for name, inst := range s.Installs {
+				tags[name] = inst.Tag
+				if _, ok := releaseLine(inst.Tag); !ok && inst.Provenance != nil && inst.Provenance.Ref != "" {
+					tags[name] = inst.Provenance.Ref
+				}
+			}
+			for _, group := range groupInstalls(versions, tags) {
+				var visible []string
+				for _, name := range group.names {
+					if selected(name) || name == tipTag {
+						visible = append(visible, name)
+					}
+				}
+				if len(visible) == 0 {
+					continue
+				}
+				if *showSize {
+					var total int64
+					for _, name := range visible {
+						if selected(name) {
+							n, _ := size(name)
+							total += n
+						}
+						if name == tipTag {
+							for _, snap := range snaps {
+								if !snap.archived && selected(snap.name) {
+									n, _ := size(snap.name)
+									total += n
+								}
+							}
+						}
+					}
+					fmt.Fprintf(w, "%s\t\t\t%s\n", group.label, formatSize(total))
+				} else {
+					// The tab keeps the rows of every group aligned.
+					fmt.Fprintf(w, "%s\t\n", group.label)
+				}
+				for _, name := range visible {
+					printVersion(name)
+				}
+			}
+		} else {
+			for _, name := range versions {
+				printVersion(name)
+			}
+		}
+		// tip itself may have been removed.
+		printSnapshots()
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+
+		if drifts, err := g.findDrift(); err == nil && len(drifts) > 0 {
+			warnf("%d inconsistencies between versions, worktrees, and state; see `groot doctor` and run `groot prune` to reconcile", len(drifts))
+		}
+		return nil
+	}
+}
+
+// installPlatform returns the GOOS/GOARCH an installed version
+// runs as, which may differ from groot's own.
+func (g *groot) installPlatform(name string) string {
+	out, err := g.goCmd(name, "env", "GOHOSTOS", "GOHOSTARCH").Output()
+	fields := strings.Fields(string(out))
+	if err != nil || len(fields) != 2 {
+		return "unknown"
+	}
+	return fields[0] + "/" + fields[1]
+}
+
+func activate(fs *flag.FlagSet, g *groot) func(args []string) error {
+	fs.BoolVar(&g.noSymlink, "no-symlink", false, "write shim scripts instead of symlinking bin")
+	prerelease := fs.Bool("prerelease", false, "consider betas and release candidates when matching a partial version")
+	printEnv := fs.Bool("print", false, "print the environment using the version in the current shell instead of activating it")
+	fs.BoolVar(printEnv, "local-only", false, "same as --print")
+	force := fs.Bool("force", false, "activate the version even if it failed its smoke test")
+	return func(args []string) error {
+		var err error
+		var tag string
+		switch len(args) {
+		case 0:
+			var p string
+			p, tag, err = g.localVersion()
+			if err != nil {
+				return err
+			}
+			if p == "" {
+				return usageErrorf("Without a version, a .go-version or .tool-versions file in the current directory or a parent is used.")
+			}
+			if !*printEnv {
+				// The output of --print is evaluated by the shell.
+				infoln("Using", tag, "from", p)
+			}
+		case 1:
+			tag, err = g.resolveInstalled(args[0], *prerelease)
+			if err != nil {
+				return err
+			}
+		default:
+			return errUsage
+		}
+
+		if inst, err := g.installInfo(tag); err == nil && inst.Failed != "" && !*force {
+			return fmt.Errorf("%s failed its smoke test: %s\nSee %s; use --force to activate it anyway",
+				tag, inst.Failed, filepath.Join(g.versionDir(tag), smokeLogFile))
+		}
+		// Without bin/go, even --force would activate nothing.
+		if inst, err := g.installInfo(tag); err == nil && g.unbuilt(tag, inst) {
+			return fmt.Errorf("%s was added with --no-build and has no go command; build it with `groot rebuild %s` or make.bash first", tag, tag)
+		}
+
+		if *printEnv {
+			return g.printVersionEnv(tag, false, false)
+		}
+
+		if !g.noSymlink && g.linkedTo(filepath.Join(g.versionDir(tag), "bin")) {
+			fmt.Println(tag, "is already active")
+			return nil
+		}
+
+		err = g.activate(tag)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s activated; %s\n", tag, g.describeGoResolution())
+		return nil
+	}
+}
+
+func current(g *groot, _ []string) error {
+	tag, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+	if tag == "" {
+		fmt.Println("No version is active.")
+		return exitStatus(exitError)
+	}
+
+	p, local, err := g.localVersion()
+	switch {
+	case err != nil:
+		fmt.Println(tag, "(global)")
+		warnln("Ignoring", err)
+	case p == "":
+		fmt.Println(tag)
+	case local == tag:
+		fmt.Println(tag, "(set by "+p+")")
+	default:
+		fmt.Println(tag, "(global)")
+		fmt.Printf("%s requests %s; run `groot activate` to switch\n", p, local)
+	}
+	return nil
+}
+
+func deactivate(g *groot, _ []string) error {
+	err := g.deactivate()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func available(fs *flag.FlagSet, g *groot) func(args []string) error {
+	fs.BoolVar(&g.refresh, "refresh", false, "revalidate cached release metadata")
+	perMinor := fs.Bool("latest-per-minor", false, "only show the newest patch release of each minor version")
+	remote := fs.Bool("remote", false, "list releases from go.dev instead of the local clone")
+	remoteGit := fs.Bool("remote-git", false, "list the tags of the Go repository with git ls-remote instead of the local clone")
+	prerelease := fs.Bool("prerelease", false, "include betas and release candidates")
+	binaryOnly := fs.Bool("binary", false, "only show versions with a binary release for the platform")
+	goos := fs.String("os", runtime.GOOS, "with --binary, check for binaries for `GOOS`")
+	goarch := fs.String("arch", runtime.GOARCH, "with --binary, check for binaries for `GOARCH`")
+	supportedOnly := fs.Bool("supported", false, "only show releases of the minor versions that still receive security updates")
+	sinceDate := fs.String("since-date", "", "only show releases tagged on or after `YYYY-MM-DD`, with their dates")
+	return func(args []string) error {
+		var err error
+		var since time.Time
+		if *sinceDate != "" {
+			if *remote || *remoteGit {
+				return usageErrorf("--since-date can't be used with --remote or --remote-git")
+			}
+			since, err = time.ParseInLocation("2006-01-02", *sinceDate, time.Local)
+			if err != nil {
+				return usageErrorf("--since-date must be a date such as 2023-01-01")
+			}
+		}
+
+		if g.useGoDev() && !*remoteGit && *sinceDate == "" {
+			*remote = true
+		}
+
+		// Releases newer than the supported lines, such as a release
+		// candidate, are shown too.
+		var supported map[string]bool
+		if *supportedOnly {
+			supported, err = g.supportWindow()
+			if err != nil {
+				return err
+			}
+		}
+
+		if *remote {
+			err = g.availableRemote(*perMinor, *prerelease, *binaryOnly, *goos, *goarch, supported)
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+
+		var tags []string
+		if *remoteGit {
+			tags, err = g.remoteTags()
+		} else {
+			tags, err = g.tags()
+		}
+		if err != nil {
+			return err
+		}
+		if !*prerelease {
+			tags = stableTags(tags)
+		}
+		if *binaryOnly {
+			tags, err = g.withBinary(tags, *goos, *goarch)
+			if err != nil {
+				return err
+			}
+		}
+
+		var dates map[string]time.Time
+		if *sinceDate != "" {
+			dates, err = g.tagDates()
+			if err != nil {
+				return err
+			}
+			var recent []string
+			for _, tag := range tags {
+				if date, ok := dates[tag]; ok && !date.Before(since) {
+					recent = append(recent, tag)
+				}
+			}
+			tags = recent
+		}
+
+		if *perMinor {
+			tags = latestPerMinor(tags)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, tag := range tags {
+			if supported != nil && eol(tag, supported) {
+				continue
+			}
+			if dates != nil {
+				fmt.Fprintf(tw, "%s\t%s\n", tag, dates[tag].Format("2006-01-02"))
+				continue
+			}
+			fmt.Fprintln(tw, tag)
+		}
+		tw.Flush()
+		return nil
+	}
+}
+
+func initGroot(fs *flag.FlagSet, g *groot) func(args []string) error {
+	insecure := fs.Bool("insecure-skip-verify", false, "disable TLS certificate verification for downloads")
+	fs.BoolVar(&g.noSymlink, "no-symlink", false, "write shim scripts instead of symlinking bin")
+	fs.StringVar(&g.sharedBare, "bare-dir-reuse", os.Getenv("GROOT_SHARED_BARE"), "borrow objects from the shared bare repo at `path`")
+	fs.StringVar(&g.arch, "arch", "", "download the bootstrap for `GOARCH` instead of the host architecture")
+	var opts initOptions
+	fs.BoolVar(&opts.skipBuild, "skip-build", false, "download the bootstrap and clone the repository without building any versions")
+	fs.StringVar(&opts.binaryOnly, "binary-only", "", "only install and activate the binary release `version`, cloning later if needed")
+	fs.BoolVar(&opts.force, "force", false, "remove an existing clone and bootstrap and set them up again")
+	fs.StringVar(&opts.repoURL, "repo-url", os.Getenv("GROOT_REPO_URL"), "clone the Go repository from `url` instead of "+defaultRepoURL)
+	return func(args []string) error {
+		var err error
+		g.warnTranslated()
+
+		if *insecure {
+			err = g.setInsecureSkipVerify()
+			if err != nil {
+				return err
+			}
+		}
+
+		if opts.skipBuild && opts.binaryOnly != "" {
+			return usageErrorf("--skip-build and --binary-only are mutually exclusive")
+		}
+		if opts.repoURL != "" {
+			err = checkRepoURL(opts.repoURL)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = g.init(opts)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// parseFlags parses args with fs, allowing flags to be interspersed
+// with positional arguments. The positional arguments are returned.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	return positional, nil
+}
+
+func printError(err error) int {
+	log.Println("Error:", err)
+	var ccErr *noCToolchainError
+	if errors.As(err, &ccErr) {
+		return exitNoCToolchain
+	}
+	return exitError
+}
+
+// confirm asks question and reports whether the answer was yes.
+func confirm(question string) bool {
+	fmt.Print(question, " [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
 }
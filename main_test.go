@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// captureOutput runs f with os.Stdout, os.Stderr, and the log package
+// writing to buffers, and returns what was written.
+func captureOutput(t *testing.T, f func()) (stdout, stderr string) {
+	t.Helper()
+	read := func(r *os.File, buf *bytes.Buffer, done chan<- struct{}) {
+		io.Copy(buf, r)
+		r.Close()
+		close(done)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outBuf, errBuf bytes.Buffer
+	outDone, errDone := make(chan struct{}), make(chan struct{})
+	go read(outR, &outBuf, outDone)
+	go read(errR, &errBuf, errDone)
+
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	log.SetOutput(errW)
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+		log.SetOutput(origErr)
+	}()
+
+	f()
+
+	outW.Close()
+	errW.Close()
+	<-outDone
+	<-errDone
+	return outBuf.String(), errBuf.String()
+}
+
+// runGroot runs groot with args as if from the command line, and
+// returns its output and exit code. The log level is restored
+// afterwards.
+func runGroot(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	origArgs, origVerbosity := os.Args, verbosity
+	defer func() { os.Args, verbosity = origArgs, origVerbosity }()
+	os.Args = append([]string{"groot"}, args...)
+	stdout, stderr = captureOutput(t, func() { code = run() })
+	return stdout, stderr, code
+}
+
+// mustRunGroot runs groot with args in the groot directory home, and
+// fails the test if it exits non-zero. It returns the output.
+func mustRunGroot(t *testing.T, home string, args ...string) string {
+	t.Helper()
+	stdout, stderr, code := runGroot(t, append([]string{"--home", home}, args...)...)
+	if code != 0 {
+		t.Fatalf("groot %s: exit code %d\nstdout:\n%s\nstderr:\n%s", strings.Join(args, " "), code, stdout, stderr)
+	}
+	return stdout
+}
+
+// fakeMakeBash installs a go command that reports the version in
+// VERSION and answers the smoke test, standing in for a real build.
+const fakeMakeBash = `#!/bin/sh
+set -e
+v=$(cat ../VERSION)
+mkdir -p ../bin
+cat > ../bin/go <<EOF
+#!/bin/sh
+case "\$1" in
+version) echo "go version $v linux/amd64" ;;
+env) cd "\$(dirname "\$0")/.." && pwd ;;
+run) echo "hello from groot" ;;
+esac
+EOF
+chmod +x ../bin/go
+`
+
+// newTestHome returns a groot directory whose clone is of a small
+// repository tagged go1.21.0 and go1.22.0, whose make.bash only
+// installs a fake go command, and whose default bootstrap is a fake
+// go1.22.6. It needs git and sh.
+func newTestHome(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake toolchain is a shell script")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is needed to create the test repository")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	home := filepath.Join(dir, "home")
+
+	gitIn := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=groot", "-c", "user.email=groot@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = gitEnv()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeTestFile(t, filepath.Join(repo, "src", "make.bash"), fakeMakeBash, 0755)
+	gitIn(repo, "init", "-q")
+	for _, tag := range []string{"go1.21.0", "go1.22.0"} {
+		writeTestFile(t, filepath.Join(repo, "VERSION"), tag+"\n", 0644)
+		gitIn(repo, "add", "-A")
+		gitIn(repo, "commit", "-q", "-m", tag)
+		gitIn(repo, "tag", tag)
+	}
+	gitIn(dir, "clone", "-q", "--bare", repo, filepath.Join(home, ".bare"))
+
+	writeTestFile(t, filepath.Join(home, ".binary", "bin", "go"), "#!/bin/sh\necho go version go1.22.6 linux/amd64\n", 0755)
+	return home
+}
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, []byte(content), mode)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const layoutXDG = "xdg"
+
+func migrate(fs *flag.FlagSet, g *groot) func(args []string) error {
+	toXDG := fs.Bool("xdg", false, "move to the XDG base directory layout")
+	return func(args []string) error {
+		if !*toXDG {
+			return errUsage
+		}
+
+		err := g.migrateXDG()
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// migrateXDG moves an installation in the legacy layout to the
+// XDG layout, repairing worktrees and the active version link.
+func (g *groot) migrateXDG() error {
+	if g.config.Layout == layoutXDG {
+		return errors.New("already using the XDG layout")
+	}
+
+	from := g.paths
+	if from != legacyPaths(filepath.Join(g.homeDir, ".groot")) {
+		return errors.New("only the default groot directory can be migrated")
+	}
+	to := xdgPaths(g.homeDir)
+
+	names, err := g.installed()
+	if err != nil {
+		return err
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{to.base, to.state, filepath.Dir(to.cache), filepath.Dir(to.config)} {
+		err := mkdirAll(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The active link points into the old location, it's
+	// recreated once everything has moved.
+	err = g.deactivate()
+	if err != nil {
+		return err
+	}
+
+	moves := map[string]string{
+		from.git:        to.git,
+		from.binary:     to.binary,
+		from.bootstraps: to.bootstraps,
+		from.cache:      to.cache,
+
+		filepath.Join(from.state, "manifests"): filepath.Join(to.state, "manifests"),
+		filepath.Join(from.state, ".tests"):    filepath.Join(to.state, ".tests"),
+		filepath.Join(from.state, ".builds"):   filepath.Join(to.state, ".builds"),
+	}
+	var worktrees []string
+	for _, name := range names {
+		moves[g.versionDir(name)] = filepath.Join(to.base, name)
+		worktrees = append(worktrees, filepath.Join(to.base, name))
+	}
+
+	// Remaining files are state, other than the config which is
+	// rewritten below.
+	finfos, err := ioutil.ReadDir(from.base)
+	if err != nil {
+		return err
+	}
+	for _, finfo := range finfos {
+		path := filepath.Join(from.base, finfo.Name())
+		if finfo.Mode().IsRegular() && path != from.config && path != from.config+".lock" {
+			moves[path] = filepath.Join(to.state, finfo.Name())
+		}
+	}
+
+	for src, dst := range moves {
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		infoln("Moving", src, "to", dst)
+		err := os.Rename(src, dst)
+		if err != nil {
+			return fmt.Errorf("migration incomplete: %v", err)
+		}
+	}
+
+	g.paths = to
+
+	// Worktrees record absolute paths to the repo and vice versa.
+	if len(worktrees) > 0 && g.requireExecGit("worktree repair") == nil {
+		err = g.git(append([]string{"worktree", "repair"}, worktrees...)...)
+		if err != nil {
+			return fmt.Errorf("repairing worktrees: %v", err)
+		}
+	}
+
+	cfg := g.config
+	cfg.Layout = layoutXDG
+	err = writeJSONFile(to.config, &cfg)
+	if err != nil {
+		return err
+	}
+	os.Remove(from.config)
+	os.Remove(from.config + ".lock")
+
+	if active != "" {
+		err = g.activate(active)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only removed if nothing unexpected was left behind.
+	os.Remove(from.base)
+
+	// Versions that don't find GOROOT relative to the go binary
+	// still refer to the old location.
+	for _, name := range names {
+		inst, err := g.installInfo(name)
+		if err != nil || relocatable(inst.Tag) {
+			continue
+		}
+		if err := g.checkGOROOT(name); err != nil {
+			warnf("%v\nRebuild %s to use it from the new location.", err, name)
+		}
+	}
+
+	fmt.Println("Migrated to the XDG layout:")
+	fmt.Println("  toolchains:", to.base)
+	fmt.Println("  config:    ", to.config)
+	fmt.Println("  state:     ", to.state)
+	fmt.Println("  cache:     ", to.cache)
+	fmt.Println(`Update your shell's rc file to re-run 'eval "$(groot env)"', the bin directory has moved.`)
+	return nil
+}
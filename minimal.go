@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// minimalExclude are the top-level directories of the Go tree left out
+// of minimal installs. make.bash doesn't need them; run.bash does.
+var minimalExclude = []string{"test", "doc", "api"}
+
+// addSparseWorktree creates branch at rev and adds a worktree of it
+// at dir, checking out everything but minimalExclude.
+func (g *groot) addSparseWorktree(dir, branch, rev string) error {
+	err := g.requireExecGit("--minimal")
+	if err != nil {
+		return err
+	}
+	err = g.git("branch", branch, rev)
+	if err != nil {
+		return err
+	}
+	err = g.git("worktree", "add", "--no-checkout", dir, branch)
+	if err != nil {
+		return err
+	}
+	err = g.lockWorktree(dir)
+	if err != nil {
+		return err
+	}
+
+	err = g.setSparse(dir)
+	if err != nil {
+		return err
+	}
+	// --no-checkout leaves the index empty.
+	return g.runGit("-C", dir, "reset", "--quiet", "--hard")
+}
+
+// setSparse limits the checkout of the worktree dir to everything but
+// minimalExclude.
+func (g *groot) setSparse(dir string) error {
+	patterns := []string{"/*"}
+	for _, d := range minimalExclude {
+		patterns = append(patterns, "!/"+d+"/")
+	}
+	// Sparse checkout settings are per worktree, other
+	// versions keep their full checkout.
+	return g.runGit(append([]string{"-C", dir, "sparse-checkout", "set", "--no-cone"}, patterns...)...)
+}
+
+// sparseCheckout reports whether the worktree dir is a sparse checkout,
+// which is the case for minimal installs even if a failed build kept
+// that from being recorded.
+func sparseCheckout(dir string) bool {
+	out, err := gitCommand(context.Background(), "-C", dir, "config", "--get", "core.sparseCheckout").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// densify checks out the paths left out of the minimal install name.
+func (g *groot) densify(name string) error {
+	infoln("Checking out", name, "in full")
+	err := g.runGit("-C", g.versionDir(name), "sparse-checkout", "disable")
+	if err != nil {
+		return err
+	}
+	return g.updateState(func(s *state) error {
+		if inst, ok := s.Installs[name]; ok {
+			inst.Minimal = false
+		}
+		return nil
+	})
+}
+
+func rebuild(fs *flag.FlagSet, g *groot) func(args []string) error {
+	var flags buildOptions
+	fs.BoolVar(&flags.test, "test", false, "run the std tests with run.bash after building")
+	fs.DurationVar(&flags.testTimeout, "timeout", 0, "abort --test after `duration`")
+	fs.StringVar(&flags.logFile, "log-file", "", "also write the output of make.bash to `path`")
+	fs.BoolVar(&flags.quiet, "quiet", false, "only write the output of make.bash to the log file")
+	fs.DurationVar(&flags.stallTimeout, "stall-timeout", 0, "abort the build if make.bash prints nothing for `duration`")
+	staleBootstrap := fs.Bool("stale-bootstrap", false, "rebuild every version built with an older bootstrap than the default")
+	return func(args []string) error {
+		var err error
+		if *staleBootstrap {
+			if len(args) > 0 || flags.logFile != "" {
+				return usageErrorf("--stale-bootstrap can't be used with a version or --log-file")
+			}
+			names, err := g.staleBootstrapped()
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Println("No versions were built with an older bootstrap")
+				return nil
+			}
+			for _, name := range names {
+				infoln("Rebuilding", name)
+				err = g.rebuild(name, flags, true)
+				if err != nil {
+					return fmt.Errorf("rebuilding %s: %v", name, err)
+				}
+			}
+			return nil
+		}
+
+		if len(args) < 1 {
+			return errUsage
+		}
+		name := args[0]
+
+		if _, err := os.Stat(g.versionDir(name)); err != nil {
+			return err
+		}
+		err = g.rebuild(name, flags, false)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// rebuild builds the installed source version name again with its
+// recorded options, and the test and log settings of flags. With
+// defaultBootstrap, the default bootstrap is used instead of the
+// recorded one.
+func (g *groot) rebuild(name string, flags buildOptions, defaultBootstrap bool) error {
+	inst, err := g.installInfo(name)
+	if err != nil {
+		return err
+	}
+	if inst.Kind != kindSource {
+		return fmt.Errorf("%s is a binary install; use `groot add --binary --force %s` to install it again", name, inst.Tag)
+	}
+	if inst.Detached {
+		return fmt.Errorf("%s was detached from git; use `groot add --force %s` to build it again", name, inst.Tag)
+	}
+
+	opts := inst.options()
+	opts.test = flags.test
+	opts.testTimeout = flags.testTimeout
+	opts.logFile = flags.logFile
+	opts.quiet = flags.quiet
+	opts.stallTimeout = flags.stallTimeout
+	if defaultBootstrap {
+		opts.bootstrap = ""
+	}
+
+	// The tests need the whole tree.
+	if opts.test && sparseCheckout(g.versionDir(name)) {
+		err = g.densify(name)
+		if err != nil {
+			return err
+		}
+		opts.minimal = false
+	}
+
+	return g.build(name, opts)
+}
+
+// versionDetails is what info reports about an installed version.
+type versionDetails struct {
+	Name        string            `json:"name"`
+	Tag         string            `json:"tag"`
+	Kind        string            `json:"kind"`
+	Active      bool              `json:"active"`
+	Installed   *time.Time        `json:"installed,omitempty"`
+	Built       *time.Time        `json:"built,omitempty"`
+	Directory   string            `json:"directory"`
+	Size        int64             `json:"size"`
+	GoVersion   string            `json:"go_version,omitempty"`
+	Commit      string            `json:"commit,omitempty"`
+	Provenance  *provenance       `json:"provenance,omitempty"`
+	Minimal     bool              `json:"minimal,omitempty"`
+	Detached    bool              `json:"detached,omitempty"`
+	Bootstrap   string            `json:"bootstrap,omitempty"`
+	Experiments string            `json:"experiments,omitempty"`
+	GOROOTFinal string            `json:"goroot_final,omitempty"`
+	Env         []string          `json:"env,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Note        string            `json:"note,omitempty"`
+	Failed      string            `json:"failed,omitempty"`
+	NotBuilt    bool              `json:"not_built,omitempty"`
+}
+
+// versionDetails collects what's known about the installed version
+// name. Details that can't be determined are left empty.
+func (g *groot) versionDetails(name string) (versionDetails, error) {
+	dir := g.versionDir(name)
+	inst, err := g.installInfo(name)
+	if err != nil {
+		return versionDetails{}, err
+	}
+
+	d := versionDetails{
+		Name:        name,
+		Tag:         inst.Tag,
+		Kind:        inst.Kind,
+		Directory:   dir,
+		Experiments: inst.Experiment,
+		GOROOTFinal: inst.GOROOTFinal,
+		Provenance:  inst.Provenance,
+		Env:         inst.Env,
+		Labels:      inst.Labels,
+		Note:        inst.Note,
+		Failed:      inst.Failed,
+		NotBuilt:    g.unbuilt(name, inst),
+	}
+	if !inst.Installed.IsZero() {
+		d.Installed = &inst.Installed
+	}
+	if active, err := g.activeVersion(); err == nil {
+		d.Active = active == name
+	}
+	// The go binary is written last by make.bash and extraction.
+	if finfo, err := os.Stat(filepath.Join(dir, "bin", exeName("go"))); err == nil {
+		built := finfo.ModTime()
+		d.Built = &built
+	}
+	if out, err := goVersion(dir); err == nil {
+		d.GoVersion = out
+	}
+	d.Size, err = dirSize(dir)
+	if err != nil {
+		return versionDetails{}, err
+	}
+
+	d.Minimal = inst.Minimal
+	if inst.Kind == kindSource {
+		d.Minimal = d.Minimal || sparseCheckout(dir)
+		d.Detached = inst.Detached
+		d.Bootstrap = inst.bootstrapDescription()
+		d.Commit = inst.Commit
+		if !inst.Detached {
+			if head, err := g.backend().head(dir); err == nil {
+				d.Commit = head
+			}
+		}
+	}
+	return d, nil
+}
+
+func info(fs *flag.FlagSet, g *groot) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print the details as a JSON object")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		name, err := g.resolveInstalled(args[0], false)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(g.versionDir(name)); err != nil {
+			return err
+		}
+		d, err := g.versionDetails(name)
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			out, err := json.MarshalIndent(d, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Name:\t%s\n", d.Name)
+		fmt.Fprintf(w, "Tag:\t%s\n", d.Tag)
+		fmt.Fprintf(w, "Kind:\t%s\n", d.Kind)
+		fmt.Fprintf(w, "Active:\t%t\n", d.Active)
+		if d.GoVersion != "" {
+			fmt.Fprintf(w, "Go version:\t%s\n", d.GoVersion)
+		}
+		if d.Installed != nil {
+			fmt.Fprintf(w, "Installed:\t%s\n", d.Installed.Format(time.RFC3339))
+		}
+		if d.Built != nil {
+			fmt.Fprintf(w, "Built:\t%s\n", d.Built.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Origin:\t%s\n", d.Provenance)
+		if len(d.Labels) > 0 {
+			fmt.Fprintf(w, "Labels:\t%s\n", formatLabels(d.Labels))
+		}
+		if d.Failed != "" {
+			fmt.Fprintf(w, "Failed:\t%s\n", d.Failed)
+		}
+		if d.NotBuilt {
+			fmt.Fprintf(w, "Built:\tno, added with --no-build\n")
+		}
+		if d.Note != "" {
+			fmt.Fprintf(w, "Note:\t%s\n", d.Note)
+		}
+		fmt.Fprintf(w, "Directory:\t%s\n", d.Directory)
+		fmt.Fprintf(w, "Size:\t%s\n", formatSize(d.Size))
+		if d.Kind != kindSource && d.Minimal {
+			fmt.Fprintf(w, "Minimal:\t%t\n", d.Minimal)
+		}
+		if d.Kind == kindSource {
+			if d.Commit != "" {
+				fmt.Fprintf(w, "Commit:\t%s\n", d.Commit)
+			}
+			fmt.Fprintf(w, "Minimal:\t%t\n", d.Minimal)
+			if d.Detached {
+				fmt.Fprintf(w, "Detached:\t%t\n", d.Detached)
+			}
+			fmt.Fprintf(w, "Bootstrap:\t%s\n", d.Bootstrap)
+			if d.Experiments != "" {
+				fmt.Fprintf(w, "Experiments:\t%s\n", d.Experiments)
+			}
+			if d.GOROOTFinal != "" {
+				fmt.Fprintf(w, "GOROOT_FINAL:\t%s\n", d.GOROOTFinal)
+			}
+			for _, kv := range d.Env {
+				fmt.Fprintf(w, "Build env:\t%s\n", kv)
+			}
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// windowsInvalidChars can't appear in file names on Windows.
+const windowsInvalidChars = `<>:"|?*`
+
+// checkName returns an error if name can't be used as the name of an
+// install chosen by the user. Names become directories in the versions
+// directory, branch names, and shim names, so the rules are those of
+// all platforms rather than just the current one. installed are the
+// names already in use.
+func checkName(name string, installed []string) error {
+	if name == "" {
+		return fmt.Errorf("the name is empty")
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("name %q starts or ends with a space", name)
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("name %q starts with '.', which would hide it", name)
+	}
+	if strings.HasSuffix(name, ".") {
+		return fmt.Errorf("name %q ends with '.', which Windows drops", name)
+	}
+	for _, r := range name {
+		switch {
+		case r == '/' || r == '\\':
+			return fmt.Errorf("name %q contains the path separator %q", name, r)
+		case strings.ContainsRune(windowsInvalidChars, r):
+			return fmt.Errorf("name %q contains %q, which is invalid in file names on Windows", name, r)
+		case unicode.IsControl(r) || unicode.IsSpace(r):
+			return fmt.Errorf("name %q contains the whitespace or control character %q", name, r)
+		}
+	}
+	// Also not a valid git branch name.
+	if strings.Contains(name, "..") || strings.HasSuffix(name, ".lock") {
+		return fmt.Errorf("name %q can't be used as a git branch name", name)
+	}
+	if reservedWindowsName(name) {
+		return fmt.Errorf("name %q is a reserved device name on Windows", name)
+	}
+
+	for reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("name %q is reserved for groot's %s directory", name, reserved)
+		}
+	}
+	if strings.EqualFold(name, tipTag) || isSnapshot(strings.ToLower(name)) {
+		return fmt.Errorf("name %q is reserved for builds of tip", name)
+	}
+
+	for _, other := range installed {
+		if other == name {
+			return fmt.Errorf("%s is already installed", name)
+		}
+		// On case-insensitive filesystems these are the same directory.
+		if strings.EqualFold(other, name) {
+			return fmt.Errorf("name %q differs only in case from the installed %s", name, other)
+		}
+	}
+	return nil
+}
+
+// checkNewName checks that name can be given to a new install.
+func (g *groot) checkNewName(name string) error {
+	names, err := g.installed()
+	if err != nil {
+		return err
+	}
+	return checkName(name, names)
+}
+
+func rename(g *groot, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	from, to := args[0], args[1]
+
+	if _, err := os.Stat(longPath(g.versionDir(from))); err != nil {
+		return err
+	}
+	if from == tipTag || isSnapshot(from) {
+		return errors.New("tip and its snapshots can't be renamed")
+	}
+	err := g.checkNewName(to)
+	if err != nil {
+		return err
+	}
+
+	err = g.renameVersion(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Renamed", from, "to", to)
+	return nil
+}
+
+// renameVersion moves the install from to the name to, along with its
+// worktree, branch, manifest, and state.
+func (g *groot) renameVersion(from, to string) error {
+	inst, err := g.installInfo(from)
+	if err != nil {
+		return err
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+
+	fromDir, toDir := g.versionDir(from), g.versionDir(to)
+	_, worktree := g.backend().(*execGit)
+	worktree = worktree && inst.Kind == kindSource && !inst.Detached
+	if worktree {
+		err = g.unlockWorktree(fromDir)
+		if err == nil {
+			err = g.git("worktree", "move", fromDir, toDir)
+		}
+		if err == nil {
+			err = g.lockWorktree(toDir)
+		}
+		if err == nil {
+			err = g.git("branch", "-m", "groot."+from, "groot."+to)
+		}
+	} else {
+		err = os.Rename(longPath(fromDir), longPath(toDir))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []func(string) string{g.manifestPath, g.buildLogPath, g.testLogPath, g.testResultPath} {
+		err = os.Rename(path(from), path(to))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	os.Remove(g.testResultPath(from) + ".lock")
+	err = g.updateState(func(s *state) error {
+		if i, ok := s.Installs[from]; ok {
+			s.Installs[to] = i
+			delete(s.Installs, from)
+		}
+		if s.PreferredBootstrap == from {
+			s.PreferredBootstrap = to
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if active == from {
+		if _, err := readShimMarker(g.paths.active); err == nil {
+			g.noSymlink = true
+		}
+		err = g.activate(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Versions that don't find GOROOT relative to the go binary
+	// still refer to the old location.
+	if !relocatable(inst.Tag) {
+		if err := g.checkGOROOT(to); err != nil {
+			warnf("%v\nRebuild %s to use it from the new location.", err, to)
+		}
+	}
+	return nil
+}
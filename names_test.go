@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckName(t *testing.T) {
+	installed := []string{"go1.21.6", "Work"}
+	tests := []struct {
+		name string
+		want string // substring of the error, empty if name is valid
+	}{
+		{"go1.22.1", ""},
+		{"work2", ""},
+		{"go1.21.6-goamd64v3", ""},
+		{"my_go", ""},
+		{"a.b", ""},
+		{"binary", ""},
+		{"tests", ""},
+		{"tipster", ""},
+		{"console", ""},
+		{"com0", ""},
+		{"lock", ""},
+		{"x.lockfile", ""},
+
+		{"", "empty"},
+		{" go", "starts or ends with a space"},
+		{"go ", "starts or ends with a space"},
+		{"go\n", "starts or ends with a space"},
+
+		// Separators.
+		{"a/b", "path separator '/'"},
+		{"a\\b", "path separator '\\\\'"},
+		{"/abs", "path separator"},
+
+		// Leading and trailing dots.
+		{".", "starts with '.'"},
+		{".hidden", "starts with '.'"},
+		{".bare", "starts with '.'"},
+		{"go1.", "ends with '.'"},
+		{"go...", "ends with '.'"},
+
+		// Characters invalid on Windows.
+		{"a<b", `contains '<'`},
+		{"a>b", `contains '>'`},
+		{"c:go", `contains ':'`},
+		{`a"b`, `contains '"'`},
+		{"a|b", `contains '|'`},
+		{"go?", `contains '?'`},
+		{"go*", `contains '*'`},
+		{"a b", "whitespace or control character ' '"},
+		{"a\tb", "whitespace or control character"},
+		{"a\x00b", "whitespace or control character"},
+		{"a\x7fb", "whitespace or control character"},
+		{"a\u00a0b", "whitespace or control character"},
+
+		// Not valid as git branch names.
+		{"..", "starts with '.'"},
+		{"a..b", "git branch name"},
+		{"go.lock", "git branch name"},
+
+		// Reserved device names on Windows.
+		{"con", "reserved device name"},
+		{"CON", "reserved device name"},
+		{"Prn", "reserved device name"},
+		{"aux", "reserved device name"},
+		{"nul", "reserved device name"},
+		{"aux.go", "reserved device name"},
+		{"com1", "reserved device name"},
+		{"COM9", "reserved device name"},
+		{"lpt3", "reserved device name"},
+		{"lpt1.txt", "reserved device name"},
+		{"conin$", "reserved device name"},
+		{"CONOUT$", "reserved device name"},
+
+		// groot's own directories.
+		{"bin", "reserved for groot's bin directory"},
+		{"BIN", "reserved for groot's bin directory"},
+		{"cache", "reserved for groot's cache directory"},
+		{"manifests", "reserved for groot's manifests directory"},
+		{"Manifests", "reserved for groot's manifests directory"},
+
+		// tip and its snapshots.
+		{"tip", "reserved for builds of tip"},
+		{"TIP", "reserved for builds of tip"},
+		{"tip-2024-03-01", "reserved for builds of tip"},
+		{"Tip-2024-03-01-2", "reserved for builds of tip"},
+
+		// Installed names, including those differing only in case.
+		{"go1.21.6", "already installed"},
+		{"Work", "already installed"},
+		{"work", `differs only in case from the installed Work`},
+		{"WORK", `differs only in case from the installed Work`},
+		{"GO1.21.6", `differs only in case from the installed go1.21.6`},
+	}
+	for _, tt := range tests {
+		err := checkName(tt.name, installed)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("checkName(%q) = %v, want nil", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("checkName(%q) = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestAddBranchName checks that add --branch rejects branches whose
+// names can't be used for installs, not only those with a slash.
+func TestAddBranchName(t *testing.T) {
+	home := newTestHome(t)
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"bin", "reserved for groot's bin directory"},
+		{"CON", "reserved device name"},
+		{"tip", "reserved for builds of tip"},
+		{"release.lock", "git branch name"},
+	}
+	for _, tt := range tests {
+		_, stderr, code := runGroot(t, "--home", home, "add", "--branch", tt.branch)
+		if code == 0 {
+			t.Errorf("add --branch %s succeeded", tt.branch)
+			continue
+		}
+		if !strings.Contains(stderr, tt.want) {
+			t.Errorf("add --branch %s: got %q, want an error containing %q", tt.branch, stderr, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// opLogFile is the rolling log of the subprocesses groot ran, under
+// the state directory, included in bugreport bundles.
+const opLogFile = "operations.log"
+
+// opLogKeep is the number of records kept in the operation log.
+const opLogKeep = 200
+
+// opLogMu serializes writes to the operation log by parallel builds.
+var opLogMu sync.Mutex
+
+// opRecord is a subprocess run by groot, one JSON object per line of
+// the operation log.
+type opRecord struct {
+	Time     time.Time `json:"time"`
+	Args     []string  `json:"args"`
+	Dir      string    `json:"dir,omitempty"`
+	Exit     int       `json:"exit"`            // -1 if it didn't exit normally
+	Error    string    `json:"error,omitempty"` // why it failed to run or was killed
+	Duration string    `json:"duration"`
+}
+
+func (g *groot) opLogPath() string {
+	return filepath.Join(g.paths.state, opLogFile)
+}
+
+// exec runs name with args attached to groot's output, recording it
+// in the operation log.
+func (g *groot) exec(name string, args ...string) error {
+	return g.runRecorded(exec.CommandContext(g.context(), name, args...))
+}
+
+// runRecorded runs cmd attached to groot's output, as
+// runAttachedOutput does, recording it in the operation log.
+func (g *groot) runRecorded(cmd *exec.Cmd) error {
+	start := time.Now()
+	err := runAttachedOutput(cmd)
+	g.recordOp(cmd, start, err)
+	return err
+}
+
+// recordOp appends cmd, started at start and finished with err, to the
+// operation log, dropping the oldest records beyond opLogKeep. The log
+// is best effort; failing to write it doesn't fail the command.
+func (g *groot) recordOp(cmd *exec.Cmd, start time.Time, err error) {
+	rec := opRecord{
+		Time:     start,
+		Args:     cmd.Args,
+		Dir:      cmd.Dir,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if code, ok := exitCode(err); ok {
+		rec.Exit = code
+		if code == -1 {
+			rec.Error = err.Error()
+		}
+	} else if err != nil {
+		rec.Exit = -1
+		rec.Error = err.Error()
+	}
+	line, jerr := json.Marshal(rec)
+	if jerr != nil {
+		return
+	}
+
+	opLogMu.Lock()
+	defer opLogMu.Unlock()
+	if _, serr := os.Stat(g.paths.state); serr != nil {
+		// Not initialized, or the state directory is being removed.
+		return
+	}
+	lines := append(g.readOpLog(), line)
+	if len(lines) > opLogKeep {
+		lines = lines[len(lines)-opLogKeep:]
+	}
+	data := append(bytes.Join(lines, []byte("\n")), '\n')
+	if werr := writeFileAtomic(g.opLogPath(), data, stateFileMode); werr != nil {
+		debugln("Writing the operation log:", werr)
+	}
+}
+
+// readOpLog returns the lines of the operation log, oldest first.
+func (g *groot) readOpLog() [][]byte {
+	data, err := ioutil.ReadFile(g.opLogPath())
+	if err != nil {
+		return nil
+	}
+	var lines [][]byte
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		if len(s.Bytes()) > 0 {
+			lines = append(lines, append([]byte(nil), s.Bytes()...))
+		}
+	}
+	return lines
+}
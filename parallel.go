@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// downloadProgress totals the bytes of several concurrent downloads.
+type downloadProgress struct {
+	read    int64
+	total   int64 // of the downloads whose size is known
+	unknown int32 // downloads without a Content-Length
+	done    int32
+}
+
+func (p *downloadProgress) start(size int64) {
+	if size < 0 {
+		atomic.AddInt32(&p.unknown, 1)
+		return
+	}
+	atomic.AddInt64(&p.total, size)
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	atomic.AddInt64(&p.read, int64(len(b)))
+	return len(b), nil
+}
+
+func (p *downloadProgress) String() string {
+	s := formatSize(atomic.LoadInt64(&p.read))
+	if atomic.LoadInt32(&p.unknown) == 0 {
+		s += " of " + formatSize(atomic.LoadInt64(&p.total))
+	}
+	return s
+}
+
+// installBinaries installs the binary releases of tags, downloading up
+// to jobs archives at a time. Each archive is verified as soon as it's
+// downloaded and the versions are then extracted one by one.
+func (g *groot) installBinaries(tags []string, jobs int) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	goos, goarch := g.platform()
+	type pending struct {
+		tag  string
+		hash string
+		dl   *verifiedDownload
+		err  error
+	}
+	var todo []*pending
+	for _, tag := range tags {
+		p := &pending{tag: tag}
+		filename := archiveName(tag, goos, goarch)
+		hash, err := g.lookupChecksum(filename)
+		if err != nil {
+			p.err = fmt.Errorf("no binary release of %s for %s/%s: %v", tag, goos, goarch, err)
+		}
+		p.hash = hash
+		todo = append(todo, p)
+	}
+
+	infof("Downloading %d versions, %d at a time\n", len(todo), jobs)
+	progress := new(downloadProgress)
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(2 * time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				infof("  %s downloaded, %d/%d versions done\n", progress, atomic.LoadInt32(&progress.done), len(todo))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for _, p := range todo {
+		if p.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(p *pending) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			url := downloadURL + archiveName(p.tag, goos, goarch)
+			p.dl, p.err = g.downloadVerified(url, p.hash, progress)
+			atomic.AddInt32(&progress.done, 1)
+		}(p)
+	}
+	wg.Wait()
+	close(stop)
+	infof("  %s downloaded\n", progress)
+
+	failed := 0
+	for _, p := range todo {
+		if p.err == nil {
+			infoln("Installing", p.tag)
+			origin := &provenance{Source: sourceBinary, URL: downloadURL + archiveName(p.tag, goos, goarch)}
+			p.err = g.installExtracted(p.tag, origin, func(dir string, filter *archiveFilter) error {
+				return extractArchive(p.dl.f, p.dl.name, p.dl.contentType, dir, !g.config.SkipExtractCheck, filter)
+			})
+		}
+		if p.dl != nil {
+			p.dl.remove()
+		}
+		if p.err != nil {
+			log.Printf("%s: %v", p.tag, p.err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d versions failed to install", failed, len(todo))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// paths holds the resolved locations of everything groot manages.
+// All path construction goes through it so the legacy and XDG
+// layouts can't drift.
+type paths struct {
+	base       string // installed versions
+	git        string // bare clone of the Go repo
+	binary     string // bootstrap toolchain downloaded by init
+	bootstraps string // bootstrap toolchains downloaded later
+	active     string // symlink or shim directory of the active version
+	config     string // config file
+	state      string // small state files
+	cache      string // regenerable data: downloaded metadata, logs
+}
+
+// legacyPaths keeps everything under a single directory.
+func legacyPaths(dir string) paths {
+	return paths{
+		base:       dir,
+		git:        filepath.Join(dir, ".bare"),
+		binary:     filepath.Join(dir, ".binary"),
+		bootstraps: filepath.Join(dir, ".bootstraps"),
+		active:     filepath.Join(dir, "bin"),
+		config:     filepath.Join(dir, "config.json"),
+		state:      dir,
+		cache:      filepath.Join(dir, "cache"),
+	}
+}
+
+// xdgPaths follows the XDG base directory specification, keeping
+// toolchains in the data directory and state and caches separate.
+func xdgPaths(home string) paths {
+	xdg := func(env, def string) string {
+		if dir := os.Getenv(env); filepath.IsAbs(dir) {
+			return filepath.Join(dir, "groot")
+		}
+		return filepath.Join(home, def, "groot")
+	}
+
+	data := xdg("XDG_DATA_HOME", filepath.Join(".local", "share"))
+	return paths{
+		base:       data,
+		git:        filepath.Join(data, ".bare"),
+		binary:     filepath.Join(data, ".binary"),
+		bootstraps: filepath.Join(data, ".bootstraps"),
+		active:     filepath.Join(data, "bin"),
+		config:     filepath.Join(xdg("XDG_CONFIG_HOME", ".config"), "config.json"),
+		state:      xdg("XDG_STATE_HOME", filepath.Join(".local", "state")),
+		cache:      xdg("XDG_CACHE_HOME", ".cache"),
+	}
+}
+
+// resolvePaths determines the layout in use for a user with the given
+// home directory. The XDG layout is used once opted into with
+// `groot migrate --xdg`, which records it in the XDG config file.
+func resolvePaths(home string) (paths, error) {
+	p := xdgPaths(home)
+
+	cfg, err := loadConfig(p.config)
+	if err != nil {
+		return paths{}, err
+	}
+	if cfg.Layout == layoutXDG {
+		return p, nil
+	}
+	return legacyPaths(filepath.Join(home, ".groot")), nil
+}
+
+// versionDir returns the directory of an installed version.
+func (g *groot) versionDir(name string) string {
+	return filepath.Join(g.paths.base, name)
+}
+
+func printPaths(fs *flag.FlagSet, g *groot) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print as JSON")
+	return func(args []string) error {
+		var err error
+		// The link target is empty if no version is active.
+		target, _ := os.Readlink(g.paths.active)
+
+		if *asJSON {
+			out, err := json.MarshalIndent(map[string]string{
+				"base":       g.paths.base,
+				"git":        g.paths.git,
+				"binary":     g.paths.binary,
+				"bootstraps": g.paths.bootstraps,
+				"bin":        g.paths.active,
+				"bin_target": target,
+				"config":     g.paths.config,
+				"state":      g.paths.state,
+				"cache":      g.paths.cache,
+			}, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		bin := g.paths.active
+		if target != "" {
+			bin += " -> " + target
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 1, ' ', 0)
+		fmt.Fprintf(w, "base:\t%s\n", g.paths.base)
+		fmt.Fprintf(w, "git:\t%s\n", g.paths.git)
+		fmt.Fprintf(w, "binary:\t%s\n", g.paths.binary)
+		fmt.Fprintf(w, "bootstraps:\t%s\n", g.paths.bootstraps)
+		fmt.Fprintf(w, "bin:\t%s\n", bin)
+		fmt.Fprintf(w, "config:\t%s\n", g.paths.config)
+		fmt.Fprintf(w, "state:\t%s\n", g.paths.state)
+		fmt.Fprintf(w, "cache:\t%s\n", g.paths.cache)
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
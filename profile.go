@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// profileMarker precedes the line env --add-to-profile adds, so that
+// running it again finds the line instead of adding another.
+const profileMarker = "# Added by groot env --add-to-profile"
+
+// shellProfile returns the name of the user's shell, from $SHELL, and
+// the rc file it reads for interactive shells.
+func (g *groot) shellProfile() (shell, path string, err error) {
+	shell = filepath.Base(os.Getenv("SHELL"))
+	switch shell {
+	case "bash":
+		// Terminals on macOS start login shells, which don't read
+		// .bashrc.
+		if runtime.GOOS == "darwin" {
+			return shell, filepath.Join(g.homeDir, ".bash_profile"), nil
+		}
+		return shell, filepath.Join(g.homeDir, ".bashrc"), nil
+	case "zsh":
+		dir := os.Getenv("ZDOTDIR")
+		if dir == "" {
+			dir = g.homeDir
+		}
+		return shell, filepath.Join(dir, ".zshrc"), nil
+	case "fish":
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			dir = filepath.Join(g.homeDir, ".config")
+		}
+		return shell, filepath.Join(dir, "fish", "config.fish"), nil
+	case ".", "":
+		return "", "", fmt.Errorf(`SHELL isn't set; add 'eval "$(groot env)"' to your shell's rc file`)
+	}
+	return "", "", fmt.Errorf(`%s isn't supported by --add-to-profile; add the equivalent of 'eval "$(groot env)"' to its rc file`, shell)
+}
+
+// profileLine returns the line that runs groot env in shell.
+func profileLine(shell string) string {
+	exe := "groot"
+	if _, err := exec.LookPath(exe); err != nil {
+		// Not on PATH yet, so the rc file needs the full path.
+		if p, err := os.Executable(); err == nil {
+			exe = strconv.Quote(p)
+		}
+	}
+	if shell == "fish" {
+		return exe + " env | source"
+	}
+	return fmt.Sprintf(`eval "$(%s env)"`, exe)
+}
+
+// addToProfile appends the line that puts the active version on PATH
+// to the rc file of the user's shell, unless an earlier run already
+// added it.
+func (g *groot) addToProfile() error {
+	if g.homeDir == "" {
+		return fmt.Errorf(`the home directory is unknown; add 'eval "$(groot env)"' to your shell's rc file`)
+	}
+	shell, path, err := g.shellProfile()
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if strings.Contains(string(data), profileMarker) {
+		fmt.Println(g.tildePath(path), "already sets up groot")
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	var prefix string
+	if len(data) > 0 {
+		prefix = "\n"
+		if data[len(data)-1] != '\n' {
+			prefix = "\n\n"
+		}
+	}
+	line := profileLine(shell)
+	_, err = fmt.Fprintf(f, "%s%s\n%s\n", prefix, profileMarker, line)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added '%s' to %s; open a new shell to pick it up\n", line, g.tildePath(path))
+	return nil
+}
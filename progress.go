@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// progressLine matches the progress git reports for each phase of a
+// clone or fetch, such as "Receiving objects:  45% (1234/2742)".
+var progressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)% `)
+
+// Ways of rendering git's progress.
+const (
+	progressTTY   = iota // rewrite a single line as git does
+	progressLines        // print a line every 10%, for logs
+	progressNone         // drop the progress, keeping other messages
+)
+
+// gitProgress renders the progress git writes to stderr. git only
+// reports progress when asked to with --progress if its stderr isn't
+// a terminal, and then rewrites the line with carriage returns, which
+// makes a mess of logs.
+type gitProgress struct {
+	w    io.Writer
+	mode int
+
+	buf     []byte // an incomplete line
+	phase   string
+	percent int  // last printed for phase
+	inLine  bool // a progress line was printed without a newline
+}
+
+// newGitProgress returns a gitProgress writing to w, in the mode
+// suited to stderr and the --no-progress flag.
+func (g *groot) newGitProgress(w io.Writer) *gitProgress {
+	p := &gitProgress{w: w, mode: progressLines}
+	switch {
+	case g.noProgress || verbosity < levelInfo:
+		p.mode = progressNone
+	case isTerminal(os.Stderr):
+		p.mode = progressTTY
+	}
+	return p
+}
+
+func (p *gitProgress) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexAny(p.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.line(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+func (p *gitProgress) line(s string) {
+	if s == "" {
+		return
+	}
+
+	m := progressLine.FindStringSubmatch(s)
+	if m == nil {
+		p.endLine()
+		fmt.Fprintln(p.w, s)
+		return
+	}
+
+	percent, _ := strconv.Atoi(m[2])
+	switch p.mode {
+	case progressTTY:
+		fmt.Fprintf(p.w, "\r%s\x1b[K", s)
+		p.inLine = true
+	case progressLines:
+		if m[1] != p.phase {
+			p.phase, p.percent = m[1], -1
+		}
+		if percent/10 > p.percent/10 || p.percent < 0 {
+			fmt.Fprintf(p.w, "%s: %d%%\n", m[1], percent)
+			p.percent = percent
+		}
+	}
+}
+
+func (p *gitProgress) endLine() {
+	if p.inLine {
+		fmt.Fprintln(p.w)
+		p.inLine = false
+	}
+}
+
+// Close writes any incomplete line and ends the progress line.
+func (p *gitProgress) Close() error {
+	if len(p.buf) > 0 {
+		p.line(string(p.buf))
+		p.buf = nil
+	}
+	p.endLine()
+	return nil
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
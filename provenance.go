@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Provenance sources.
+const (
+	sourceTag     = "tag"     // a release tag of the repo
+	sourceBranch  = "branch"  // the head of a branch, such as master for tip
+	sourceBinary  = "binary"  // a downloaded binary release
+	sourceArchive = "archive" // a binary release archive given with --from
+	sourceUnknown = "unknown"
+)
+
+// provenance records where an install came from.
+type provenance struct {
+	Source string `json:"source"`
+	// URL is the repo or download URL, or the path of an archive.
+	URL string `json:"url,omitempty"`
+	// Ref is the git ref a source install was checked out from.
+	Ref string `json:"ref,omitempty"`
+}
+
+// sourceProvenance returns the provenance of a build of opts.
+func (g *groot) sourceProvenance(opts buildOptions) *provenance {
+	source := sourceBranch
+	if _, ok := parseVersion(opts.tag); ok {
+		source = sourceTag
+	}
+	return &provenance{Source: source, URL: g.repoURL(), Ref: opts.ref()}
+}
+
+func (p *provenance) String() string {
+	url := p.URL
+	if url == "" {
+		url = "unknown origin"
+	}
+	switch p.Source {
+	case sourceTag, sourceBranch:
+		return p.Source + " " + p.Ref + " of " + url
+	case sourceBinary, sourceArchive:
+		return p.Source + " " + url
+	}
+	return sourceUnknown
+}
+
+// backfillProvenance fills in the provenance of an install recorded
+// before provenance was, from what can be determined for certain.
+// recorded is false if nothing was recorded about the install.
+func (g *groot) backfillProvenance(inst *installState, recorded bool) {
+	if inst.Provenance != nil {
+		return
+	}
+	inst.Provenance = &provenance{Source: sourceUnknown}
+	if !recorded {
+		// The tag was guessed from the directory name.
+		return
+	}
+
+	switch inst.Kind {
+	case kindBinary:
+		// The download URL wasn't recorded.
+		inst.Provenance.Source = sourceBinary
+	case kindSource:
+		if _, ok := g.backend().(*execGit); !ok {
+			return
+		}
+		url, err := g.gitOutput("config", "--get", "remote.origin.url")
+		if err != nil {
+			return
+		}
+		*inst.Provenance = *g.sourceProvenance(buildOptions{tag: inst.Tag})
+		inst.Provenance.URL = strings.TrimSpace(url)
+	}
+}
+
+// archiveProvenance returns the provenance of an install from the
+// local archive path.
+func archiveProvenance(path string) *provenance {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &provenance{Source: sourceArchive, URL: path}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseRate parses a download rate in bytes per second, such as
+// "2MB", "500k", or "1048576". Units are powers of 1024, as for curl's
+// --limit-rate; "0" or "" means unlimited.
+func parseRate(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"gib", 1 << 30}, {"gb", 1 << 30}, {"g", 1 << 30},
+		{"mib", 1 << 20}, {"mb", 1 << 20}, {"m", 1 << 20},
+		{"kib", 1 << 10}, {"kb", 1 << 10}, {"k", 1 << 10},
+		{"b", 1},
+	}
+	num := strings.ToLower(strings.TrimSpace(strings.TrimSuffix(s, "/s")))
+	if num == "" {
+		return 0, nil
+	}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q: use a number of bytes per second with an optional unit, such as 2MB or 500KB", s)
+	}
+	return int64(n * mult), nil
+}
+
+// rateLimit is a token bucket limiting the combined speed of the
+// downloads reading through it to rate bytes per second, with bursts
+// of up to a second's worth.
+type rateLimit struct {
+	rate int64
+
+	mu     sync.Mutex
+	tokens float64 // negative while downloads owe time
+	last   time.Time
+}
+
+func newRateLimit(rate int64) *rateLimit {
+	return &rateLimit{rate: rate, tokens: float64(rate), last: time.Now()}
+}
+
+// take records that n bytes were read and returns how long to wait
+// before reading more.
+func (l *rateLimit) take(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if max := float64(l.rate); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+}
+
+// rateLimitedReader reads from r no faster than its rateLimit allows.
+type rateLimitedReader struct {
+	r     io.Reader
+	limit *rateLimit
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth.
+	if max := int(r.limit.rate/10) + 1; len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.r.Read(p)
+	if wait := r.limit.take(n); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// limitRate returns r limited to the --max-rate or max_rate download
+// rate, shared with every other download of this run.
+func (g *groot) limitRate(r io.Reader) io.Reader {
+	if g.rateLimit == nil {
+		return r
+	}
+	return &rateLimitedReader{r: r, limit: g.rateLimit}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// refDrift describes how the worktree of a source install compares with
+// the ref it was built from.
+type refDrift struct {
+	name string
+	ref  string // the branch or tag
+
+	// For branch-based installs, the commits the worktree has that
+	// the branch doesn't, and the reverse.
+	ahead, behind int
+
+	// tagMoved is set for a tag-based install whose tag now points
+	// at a commit the worktree doesn't contain. Release tags never
+	// move upstream, so it means the tag was force-moved.
+	tagMoved  bool
+	tagCommit string
+}
+
+func (d refDrift) String() string {
+	switch {
+	case d.tagMoved:
+		return fmt.Sprintf("WARNING: tag %s now points at %s, which %s wasn't built from; the tag was moved, verify where the repository's tags come from", d.ref, d.tagCommit[:10], d.name)
+	case d.behind == 0 && d.ahead == 0:
+		return fmt.Sprintf("%s is up to date with %s", d.name, d.ref)
+	case d.ahead == 0:
+		return fmt.Sprintf("%s is %s behind %s", d.name, commits(d.behind), d.ref)
+	case d.behind == 0:
+		return fmt.Sprintf("%s is %s ahead of %s", d.name, commits(d.ahead), d.ref)
+	}
+	return fmt.Sprintf("%s has diverged from %s: %s ahead, %s behind", d.name, d.ref, commits(d.ahead), commits(d.behind))
+}
+
+func commits(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return strconv.Itoa(n) + " commits"
+}
+
+// tracksRef reports whether inst is a source install whose worktree
+// can drift from its ref: not detached and not a snapshot.
+func tracksRef(name string, inst installState) bool {
+	return inst.Kind == kindSource && !inst.Detached && !isSnapshot(name) && inst.Provenance != nil &&
+		(inst.Provenance.Source == sourceBranch || inst.Provenance.Source == sourceTag)
+}
+
+// setUpstream makes the branch of a branch-based install track the
+// branch it was built from, so `git status` in the worktree reports
+// how far it has drifted.
+func (g *groot) setUpstream(branch string, opts buildOptions) error {
+	if _, ok := parseVersion(opts.tag); ok {
+		return nil
+	}
+	if _, ok := g.backend().(*execGit); !ok {
+		return nil
+	}
+	return g.git("branch", "--quiet", "--set-upstream-to="+opts.ref(), branch)
+}
+
+// refDrift compares the worktree of the installed version name with the
+// ref it was built from, as of the last fetch.
+func (g *groot) refDrift(name string, inst installState) (refDrift, error) {
+	d := refDrift{name: name, ref: inst.Provenance.Ref}
+	head, err := g.backend().head(g.versionDir(name))
+	if err != nil {
+		return d, err
+	}
+	commit, err := g.backend().revParse(d.ref)
+	if err != nil {
+		return d, err
+	}
+	if commit == head {
+		return d, nil
+	}
+
+	if inst.Provenance.Source == sourceTag {
+		// Local commits on top of the tag are fine.
+		if _, err := g.gitOutput("merge-base", "--is-ancestor", commit, head); err != nil {
+			d.tagMoved, d.tagCommit = true, commit
+		}
+		return d, nil
+	}
+
+	out, err := g.gitOutput("rev-list", "--left-right", "--count", head+"..."+commit)
+	if err != nil {
+		return d, fmt.Errorf("comparing %s with %s: %v", name, d.ref, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return d, fmt.Errorf("unexpected git rev-list output %q", out)
+	}
+	d.ahead, _ = strconv.Atoi(fields[0])
+	d.behind, _ = strconv.Atoi(fields[1])
+	return d, nil
+}
+
+// refDrifts returns the drift of every installed version that tracks a
+// ref, skipping the ones that can't be compared.
+func (g *groot) refDrifts() ([]refDrift, error) {
+	if err := g.requireExecGit("comparing installs with their branches"); err != nil {
+		return nil, err
+	}
+	names, err := g.installed()
+	if err != nil {
+		return nil, err
+	}
+	var drifts []refDrift
+	for _, name := range names {
+		inst, err := g.installInfo(name)
+		if err != nil || !tracksRef(name, inst) {
+			continue
+		}
+		d, err := g.refDrift(name, inst)
+		if err != nil {
+			debugln("Comparing", name, "with its ref:", err)
+			continue
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
+
+// fetchedRefDrifts fetches and returns the refDrifts for status, if any of
+// the installed versions names track a ref. It's best effort: status
+// is still useful without them.
+func (g *groot) fetchedRefDrifts(names []string) []refDrift {
+	if _, ok := g.backend().(*execGit); !ok || !g.cloned() {
+		return nil
+	}
+	tracking := false
+	for _, name := range names {
+		if inst, err := g.installInfo(name); err == nil && tracksRef(name, inst) {
+			tracking = true
+			break
+		}
+	}
+	if !tracking {
+		return nil
+	}
+	if err := g.fetch(); err != nil {
+		warnln("Fetching to compare installs with their branches:", err)
+	}
+	drifts, err := g.refDrifts()
+	if err != nil {
+		debugln(err)
+	}
+	return drifts
+}
+
+// updateBranch fast-forwards the branch-based install name to the
+// head of its branch and rebuilds it, after confirmation unless yes
+// is set. With rebuild, it's rebuilt even if it's up to date.
+func (g *groot) updateBranch(name string, inst installState, yes, rebuild bool) error {
+	d, err := g.refDrift(name, inst)
+	if err != nil {
+		return err
+	}
+	fmt.Println(d)
+	switch {
+	case d.behind == 0 && rebuild:
+		return g.build(name, inst.options())
+	case d.behind == 0:
+		return nil
+	case d.ahead > 0:
+		return fmt.Errorf("%s has commits that aren't on %s and can't be fast-forwarded; rebase them in %s or use `groot add --force %s`", name, d.ref, g.versionDir(name), inst.Tag)
+	}
+	if !yes && !confirm(fmt.Sprintf("Fast-forward %s to %s and rebuild it?", name, d.ref)) {
+		return nil
+	}
+
+	err = g.backend().resetWorktree(g.versionDir(name), d.ref)
+	if err != nil {
+		return err
+	}
+	return g.build(name, inst.options())
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vcabbage/groot/internal/godl"
+)
+
+// Version sources.
+const (
+	sourceGit   = "git"
+	sourceGoDev = "go.dev"
+)
+
+// useGoDev reports whether versions are discovered from the go.dev
+// release metadata rather than the tags of the bare repo. It's also
+// the fallback before init has cloned the repo.
+func (g *groot) useGoDev() bool {
+	switch g.config.VersionSource {
+	case sourceGoDev:
+		return true
+	case "", sourceGit:
+	default:
+		warnf("Unknown version_source %q, using %s", g.config.VersionSource, sourceGit)
+	}
+	_, err := os.Stat(g.paths.git)
+	return os.IsNotExist(err)
+}
+
+// releases returns all published Go releases, newest first.
+func (g *groot) releases() ([]godl.Release, error) {
+	body, err := g.fetchCached(godl.URL, "releases.json")
+	if err != nil {
+		return nil, err
+	}
+	return godl.Parse(body)
+}
+
+// releaseChecksum returns the SHA256 of filename from the release metadata.
+func (g *groot) releaseChecksum(filename string) (string, error) {
+	rels, err := g.releases()
+	if err != nil {
+		return "", err
+	}
+
+	f, ok := godl.Find(rels, filename)
+	if !ok {
+		return "", fmt.Errorf("%s not found in release metadata", filename)
+	}
+	return f.SHA256, nil
+}
+
+// availableRemote prints the releases published on go.dev along
+// with whether each has a binary for goos/goarch and is installed.
+// With binaryOnly, releases without a binary are left out, and with
+// supported set, releases of unsupported minor versions.
+func (g *groot) availableRemote(perMinor, prerelease, binaryOnly bool, goos, goarch string, supported map[string]bool) error {
+	rels, err := g.releases()
+	if err != nil {
+		return err
+	}
+
+	byVersion := make(map[string]godl.Release, len(rels))
+	var versions []string
+	for _, rel := range rels {
+		if !rel.Stable && !prerelease {
+			continue
+		}
+		if _, ok := rel.Archive(goos, goarch); binaryOnly && !ok {
+			continue
+		}
+		if supported != nil && eol(rel.Version, supported) {
+			continue
+		}
+		byVersion[rel.Version] = rel
+		versions = append(versions, rel.Version)
+	}
+	sortTags(versions)
+	if perMinor {
+		versions = latestPerMinor(versions)
+	}
+
+	installed := make(map[string]bool)
+	names, err := g.installed()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, name := range names {
+		installed[name] = true
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, v := range versions {
+		rel := byVersion[v]
+
+		stability := "stable"
+		if !rel.Stable {
+			stability = "unstable"
+		}
+
+		size := "no binary for " + goos + "/" + goarch
+		if f, ok := rel.Archive(goos, goarch); ok {
+			size = formatSize(f.Size)
+		}
+
+		status := ""
+		if installed[v] {
+			status = "installed"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v, stability, size, status)
+	}
+	return w.Flush()
+}
+
+// withBinary returns the tags that have a binary release for
+// goos/goarch according to the go.dev metadata.
+func (g *groot) withBinary(tags []string, goos, goarch string) ([]string, error) {
+	rels, err := g.releases()
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]bool)
+	for _, rel := range rels {
+		if _, ok := rel.Archive(goos, goarch); ok {
+			published[rel.Version] = true
+		}
+	}
+
+	var filtered []string
+	for _, tag := range tags {
+		if published[tag] {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered, nil
+}
+
+// remoteTags lists the release tags of the Go repository with
+// ls-remote, without needing a local clone.
+func (g *groot) remoteTags() ([]string, error) {
+	err := g.requireExecGit("listing remote tags")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := g.context()
+	if timeout := parseDuration("network_timeout", g.config.NetworkTimeout, 0); timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stderr bytes.Buffer
+	repo := g.repoURL()
+	cmd := gitCommand(ctx, "ls-remote", "--tags", repo, "refs/tags/go*")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("listing tags of %s: %v", repo, err)
+	}
+
+	tags := parseRemoteTags(string(out))
+	sortTags(tags)
+	return tags, nil
+}
+
+// parseRemoteTags returns the tag names in ls-remote output. Annotated
+// tags are listed twice, the second time peeled with a ^{} suffix.
+func parseRemoteTags(out string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		tag := strings.TrimSuffix(strings.TrimPrefix(fields[1], "refs/tags/"), "^{}")
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// formatSize formats n bytes for display.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func latest(fs *flag.FlagSet, g *groot) func(args []string) error {
+	fs.BoolVar(&g.refresh, "refresh", false, "revalidate cached release metadata")
+	remote := fs.Bool("remote", false, "use releases from go.dev instead of the local clone")
+	prerelease := fs.Bool("prerelease", false, "consider betas and release candidates")
+	return func(args []string) error {
+		v, err := g.latest(*remote || g.useGoDev(), *prerelease)
+		if err != nil {
+			return err
+		}
+		fmt.Println(v)
+		return nil
+	}
+}
+
+// latest returns the newest stable release, or the newest release
+// including prereleases if prerelease is set. Releases come from
+// go.dev if remote is set and the bare repo's tags otherwise.
+func (g *groot) latest(remote, prerelease bool) (string, error) {
+	var versions []string
+	if remote {
+		rels, err := g.releases()
+		if err != nil {
+			return "", err
+		}
+		for _, rel := range rels {
+			if rel.Stable || prerelease {
+				versions = append(versions, rel.Version)
+			}
+		}
+	} else {
+		tags, err := g.tags()
+		if err != nil {
+			return "", err
+		}
+		for _, tag := range tags {
+			if v, ok := parseVersion(tag); ok && (v.stable() || prerelease) {
+				versions = append(versions, tag)
+			}
+		}
+	}
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no releases found")
+	}
+	sortTags(versions)
+	return versions[len(versions)-1], nil
+}
+
+// latestPrerelease returns the newest prerelease of the release in
+// progress. With binary the releases published on go.dev are
+// considered, otherwise the tags of the repository, listed remotely
+// so a new tag needn't have been fetched yet.
+func (g *groot) latestPrerelease(binary bool) (string, error) {
+	var tags []string
+	if binary {
+		rels, err := g.releases()
+		if err != nil {
+			return "", err
+		}
+		for _, rel := range rels {
+			tags = append(tags, rel.Version)
+		}
+	} else {
+		var err error
+		tags, err = g.remoteTags()
+		if err != nil {
+			warnln("Using the tags of the local clone:", err)
+			tags, err = g.tags()
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return latestPrerelease(tags)
+}
+
+// bootstrapArchive returns the binary release used as the bootstrap
+// toolchain for goos/goarch according to the go.dev metadata: the
+// pinned binaryRelease if it was published for the platform, the
+// oldest stable release that was otherwise.
+func (g *groot) bootstrapArchive(goos, goarch string) (godl.File, error) {
+	rels, err := g.releases()
+	if err != nil {
+		return godl.File{}, err
+	}
+
+	var versions []string
+	archives := make(map[string]godl.File)
+	for _, rel := range rels {
+		f, ok := rel.Archive(goos, goarch)
+		if !ok || !rel.Stable {
+			continue
+		}
+		if rel.Version == "go"+binaryRelease {
+			return f, nil
+		}
+		versions = append(versions, rel.Version)
+		archives[rel.Version] = f
+	}
+	if len(versions) == 0 {
+		return godl.File{}, fmt.Errorf("no binary release for %s/%s", goos, goarch)
+	}
+	sortTags(versions)
+	return archives[versions[0]], nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func remove(fs *flag.FlagSet, g *groot) func(args []string) error {
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	var filters labelFilters
+	fs.Var(&filters, "label", "remove the versions whose labels match `filter`, as for list; repeatable")
+	return func(args []string) error {
+		names := args
+		if len(names) == 0 == (len(filters) == 0) {
+			return errUsage
+		}
+
+		if len(filters) > 0 {
+			installed, err := g.installed()
+			if err != nil {
+				return err
+			}
+			// Archived snapshots have no directory.
+			snaps, err := g.snapshots()
+			if err != nil {
+				return err
+			}
+			for _, snap := range snaps {
+				if snap.archived {
+					installed = append(installed, snap.name)
+				}
+			}
+			s, err := g.loadState()
+			if err != nil {
+				return err
+			}
+			for _, name := range installed {
+				if filters.match(s.Installs[name]) {
+					names = append(names, name)
+				}
+			}
+			if len(names) == 0 {
+				fmt.Println("No versions match")
+				return nil
+			}
+		}
+		for i, name := range names {
+			if !g.exists(name) && g.exists(normalizeTag(name)) {
+				name = normalizeTag(name)
+				names[i] = name
+			}
+			if !g.exists(name) {
+				return g.notInstalled(name)
+			}
+		}
+
+		if !*yes && !confirm("Remove "+strings.Join(names, ", ")+"?") {
+			fmt.Println("Nothing was removed")
+			return exitStatus(exitError)
+		}
+
+		active, err := g.activeVersion()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			err := g.removeVersion(name)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Removed", name)
+			if name == active {
+				infoln("Deactivating", name)
+				err = g.deactivate()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// removeOldest removes the oldest installed versions until at most
+// keep remain. Releases are ordered by version, and other builds such
+// as tip count as newest. Snapshots are left to update --keep. The
+// active version, the preferred bootstrap, and just, the version that
+// was just added, are never removed.
+func (g *groot) removeOldest(keep int, just string) error {
+	names, err := g.installed()
+	if err != nil {
+		return err
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+	s, err := g.loadState()
+	if err != nil {
+		return err
+	}
+
+	var versions []string
+	tags := make(map[string]string)
+	for _, name := range names {
+		if isSnapshot(name) {
+			continue
+		}
+		inst, err := g.installInfo(name)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, name)
+		tags[name] = inst.Tag
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, iok := parseVersion(tags[versions[i]])
+		vj, jok := parseVersion(tags[versions[j]])
+		return iok && (!jok || vi.less(vj))
+	})
+
+	excess := len(versions) - keep
+	for _, name := range versions {
+		if excess <= 0 {
+			break
+		}
+		if name == active || name == just || name == s.PreferredBootstrap {
+			continue
+		}
+		infof("Removing %s to keep %d versions\n", name, keep)
+		err = g.removeVersion(name)
+		if err != nil {
+			return err
+		}
+		excess--
+	}
+	return nil
+}
+
+// removeVersion deletes the installed version name, along with its
+// worktree registration, branch, manifest, and state, or for a tip
+// snapshot, its archive. The active link is left alone, callers
+// decide what to do about it.
+func (g *groot) removeVersion(name string) error {
+	dir := g.versionDir(name)
+
+	inst, err := g.installInfo(name)
+	if err != nil {
+		return err
+	}
+
+	makeWritable(dir)
+	err = os.RemoveAll(longPath(dir))
+	if err != nil {
+		return err
+	}
+	err = os.Remove(g.snapshotArchive(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if inst.Kind == kindSource {
+		err = g.backend().removeWorktree(dir, "groot."+name)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range []string{g.manifestPath(name), g.buildLogPath(name), g.testLogPath(name), g.testResultPath(name), g.testResultPath(name) + ".lock"} {
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return g.updateState(func(s *state) error {
+		delete(s.Installs, name)
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRemoveArchivedSnapshot checks that remove deletes the archive of
+// a snapshot, by name and by label.
+func TestRemoveArchivedSnapshot(t *testing.T) {
+	home := newTestHome(t)
+	g := &groot{paths: legacyPaths(home)}
+	snaps := map[string]map[string]string{
+		"tip-2024-03-01": nil,
+		"tip-2024-03-02": {"temp": "true"},
+		"tip-2024-03-03": nil,
+	}
+	for name, labels := range snaps {
+		writeTestFile(t, g.snapshotArchive(name), "", 0644)
+		labels := labels
+		err := g.updateState(func(s *state) error {
+			s.Installs[name] = &installState{Tag: tipTag, Kind: kindSource, Labels: labels}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustRunGroot(t, home, "remove", "--yes", "tip-2024-03-01")
+	if _, err := os.Stat(g.snapshotArchive("tip-2024-03-01")); !os.IsNotExist(err) {
+		t.Errorf("the archive of tip-2024-03-01 remains: %v", err)
+	}
+	if _, _, code := runGroot(t, "--home", home, "remove", "--yes", "tip-2024-03-01"); code == 0 {
+		t.Errorf("removing tip-2024-03-01 again succeeded")
+	}
+
+	stdout := mustRunGroot(t, home, "remove", "--yes", "--label", "temp=true")
+	if !strings.Contains(stdout, "Removed tip-2024-03-02") {
+		t.Errorf("remove --label printed %q, want tip-2024-03-02 removed", stdout)
+	}
+
+	finfos, err := ioutil.ReadDir(filepath.Join(home, snapshotArchiveDir))
+	if err != nil || len(finfos) != 1 || finfos[0].Name() != "tip-2024-03-03.tar.gz" {
+		t.Errorf("snapshot archives left: %v, %v; want only tip-2024-03-03", finfos, err)
+	}
+	s, err := g.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"tip-2024-03-01", "tip-2024-03-02"} {
+		if _, ok := s.Installs[name]; ok {
+			t.Errorf("state still records %s", name)
+		}
+	}
+}
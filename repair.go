@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// usesClone lists the commands that run git against the bare repo.
+var usesClone = map[string]bool{
+	"add":       true,
+	"available": true,
+	"prune":     true,
+	"rebuild":   true,
+	"rename":    true,
+	"update":    true,
+	"verify":    true,
+}
+
+// checkClone returns an error if the bare repo exists but isn't a
+// repository, as left by an init that died while cloning, so commands
+// don't fail with git's "not a git repository" at some later step.
+func (g *groot) checkClone() error {
+	if !g.cloned() {
+		return nil
+	}
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(g.paths.git, name)); err != nil {
+			return fmt.Errorf("%s isn't a valid git repository, probably because an earlier init was interrupted while cloning.\n"+
+				"Run `groot repair --reclone` to clone it again keeping the installed versions, or `groot init --force` to start over.", g.paths.git)
+		}
+	}
+	return nil
+}
+
+func repair(fs *flag.FlagSet, g *groot) func(args []string) error {
+	reclone := fs.Bool("reclone", false, "clone the Go repository again and re-link the installed versions to it")
+	fs.StringVar(&g.sharedBare, "bare-dir-reuse", os.Getenv("GROOT_SHARED_BARE"), "borrow objects from the shared bare repo at `path`")
+	return func(args []string) error {
+		if !*reclone || fs.NArg() != 0 {
+			return errUsage
+		}
+
+		relinked, err := g.reclone()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cloned %s again and re-linked %d worktrees\n", g.repoURL(), relinked)
+		return nil
+	}
+}
+
+// reclone replaces the bare repo with a new clone and registers the
+// worktrees of the installed versions with it, returning how many
+// were. The old repo is kept until the clone succeeds.
+func (g *groot) reclone() (int, error) {
+	old := g.paths.git + ".old"
+	err := os.RemoveAll(old)
+	if err != nil {
+		return 0, err
+	}
+	if g.cloned() {
+		err = os.Rename(g.paths.git, old)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	repo := g.repoURL()
+	err = g.backend().clone(repo, g.paths.git, g.sharedBare)
+	if err != nil {
+		os.RemoveAll(g.paths.git)
+		if _, statErr := os.Stat(old); statErr == nil {
+			os.Rename(old, g.paths.git)
+		}
+		return 0, fmt.Errorf("cloning %s: %v", repo, err)
+	}
+	g.invalidateTags()
+
+	relinked := 0
+	if _, ok := g.backend().(*execGit); ok {
+		names, err := g.installed()
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			dir := g.versionDir(name)
+			if finfo, err := os.Stat(filepath.Join(dir, ".git")); err != nil || finfo.IsDir() {
+				// Not a worktree.
+				continue
+			}
+			err := g.relinkWorktree(name, old)
+			if err != nil {
+				log.Printf("%s: %v; remove it and add it again", name, err)
+				continue
+			}
+			relinked++
+		}
+	}
+
+	os.RemoveAll(old)
+	return relinked, nil
+}
+
+// relinkWorktree registers the existing worktree of name with the bare
+// repo, on its branch at the commit it was checked out at, without
+// touching the files. old is the replaced repo, which may still know
+// the commit.
+func (g *groot) relinkWorktree(name, old string) error {
+	dir := g.versionDir(name)
+	branch := "groot." + name
+	inst, err := g.installInfo(name)
+	if err != nil {
+		return err
+	}
+
+	commit := g.worktreeCommit(dir, branch, inst, old)
+	if commit == "" {
+		return fmt.Errorf("can't determine the commit %s was checked out at", dir)
+	}
+	infof("Re-linking %s at %s\n", name, commit[:10])
+
+	err = g.git("branch", "--force", branch, commit)
+	if err != nil {
+		return err
+	}
+
+	// Recreate the worktree's administrative files under the name its
+	// .git file refers to, then let git fix up the paths.
+	id := name
+	if data, err := ioutil.ReadFile(filepath.Join(dir, ".git")); err == nil {
+		gitdir := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+		id = filepath.Base(gitdir)
+	}
+	admin := filepath.Join(g.paths.git, "worktrees", id)
+	err = mkdirAll(admin)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{
+		"HEAD":      "ref: refs/heads/" + branch,
+		"commondir": filepath.Join("..", ".."),
+		"gitdir":    filepath.Join(dir, ".git"),
+	}
+	for file, content := range files {
+		err := ioutil.WriteFile(filepath.Join(admin, file), []byte(content+"\n"), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	err = g.git("worktree", "repair", dir)
+	if err != nil {
+		return err
+	}
+
+	// The index was lost with the old repo, rebuild it from the commit.
+	err = g.runGit("-C", dir, "reset", "--quiet")
+	if err != nil {
+		return err
+	}
+	if inst.Minimal || sparseCheckout(dir) {
+		err = g.setSparse(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return g.lockWorktree(dir)
+}
+
+// worktreeCommit returns the commit the worktree dir was checked out
+// at, if it can be found in the new clone: from the branch in the old
+// repo, the tag the version was built from, or the commit recorded in
+// the VERSION.cache of a build of tip.
+func (g *groot) worktreeCommit(dir, branch string, inst installState, old string) string {
+	var candidates []string
+	if rev := readRef(old, "refs/heads/"+branch); rev != "" {
+		candidates = append(candidates, rev)
+	}
+	if _, ok := parseVersion(inst.Tag); ok {
+		candidates = append(candidates, inst.ref())
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "VERSION.cache")); err == nil {
+		// For example "devel go1.23-a1b2c3d4e5 Mon May 6 10:00:00 2024 +0000".
+		for _, field := range strings.Fields(string(data)) {
+			if i := strings.LastIndex(field, "-"); i >= 0 && strings.HasPrefix(field, "go") {
+				candidates = append(candidates, field[i+1:])
+			}
+		}
+	}
+
+	for _, rev := range candidates {
+		out, err := g.gitOutput("rev-parse", "--quiet", "--verify", rev+"^{commit}")
+		if err == nil {
+			return strings.TrimSpace(out)
+		}
+	}
+	return ""
+}
+
+// readRef reads ref from the repo gitDir without running git, which
+// may not work in a damaged repo. It returns "" if ref isn't found.
+func readRef(gitDir, ref string) string {
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		rev := strings.TrimSpace(string(data))
+		if isObjectID(rev) {
+			return rev
+		}
+	}
+
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref && isObjectID(fields[0]) {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// isObjectID reports whether s is a full SHA-1 object ID.
+func isObjectID(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
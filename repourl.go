@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultRepoURL = "https://go.googlesource.com/go"
+
+// repoURL returns the URL the Go repository is cloned and fetched
+// from: the one init was given with --repo-url or GROOT_REPO_URL, or
+// defaultRepoURL.
+func (g *groot) repoURL() string {
+	s, err := g.loadState()
+	if err != nil || s.RepoURL == "" {
+		return defaultRepoURL
+	}
+	return s.RepoURL
+}
+
+// scpLikeRE matches git's scp-like syntax for SSH URLs,
+// [user@]host:path.
+var scpLikeRE = regexp.MustCompile(`^(?:[\w.-]+@)?[\w.-]+:.+$`)
+
+// checkRepoURL returns an error if u doesn't look like something git
+// can clone: a URL with a scheme git supports, an scp-like SSH
+// address, or the path of an existing local repository.
+func checkRepoURL(u string) error {
+	if filepath.IsAbs(u) {
+		if _, err := os.Stat(u); err != nil {
+			return fmt.Errorf("invalid repo URL %q: %v", u, err)
+		}
+		return nil
+	}
+	if strings.Contains(u, "://") {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("invalid repo URL: %v", err)
+		}
+		switch parsed.Scheme {
+		case "https", "http", "ssh", "git":
+			if parsed.Host == "" {
+				return fmt.Errorf("invalid repo URL %q: no host", u)
+			}
+			return nil
+		case "file":
+			return nil
+		}
+		return fmt.Errorf("invalid repo URL %q: use an https, ssh, git, or file URL", u)
+	}
+	if scpLikeRE.MatchString(u) {
+		return nil
+	}
+	return fmt.Errorf("invalid repo URL %q: use an https, ssh, git, or file URL, user@host:path, or the absolute path of a repository", u)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func reset(fs *flag.FlagSet, g *groot) func(args []string) error {
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	purge := fs.Bool("purge", false, "also remove the config file")
+	return func(args []string) error {
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+
+		targets, err := g.resetTargets(*purge)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			fmt.Println("Nothing to remove")
+			return nil
+		}
+
+		if !*yes {
+			fmt.Println("This removes every installed version, the Go repository clone, and the bootstrap:")
+			for _, path := range targets {
+				fmt.Println("  " + path)
+			}
+			if !*purge {
+				fmt.Println("The config file is kept.")
+			}
+			if !confirm("Continue?") {
+				fmt.Println("Nothing was removed")
+				return exitStatus(exitError)
+			}
+		}
+
+		for _, path := range targets {
+			debugln("Removing", path)
+			makeWritable(path)
+			err := os.RemoveAll(longPath(path))
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Println("Removed everything; run `groot init` to start over")
+		return nil
+	}
+}
+
+// resetTargets returns everything reset removes: the contents of the
+// groot directories and the active link, but the config file only if
+// purge is set.
+func (g *groot) resetTargets(purge bool) ([]string, error) {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] && (purge || path != g.paths.config) {
+			seen[path] = true
+			targets = append(targets, path)
+		}
+	}
+
+	// The active link is outside the groot directory in shared mode.
+	if _, err := os.Lstat(g.paths.active); err == nil {
+		add(g.paths.active)
+	}
+	if _, err := os.Stat(g.paths.config); err == nil {
+		add(g.paths.config)
+	}
+	for _, dir := range []string{g.paths.base, g.paths.state, g.paths.cache} {
+		if seen[dir] {
+			// Inside the groot directory, so already removed.
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			add(filepath.Join(dir, e.Name()))
+		}
+	}
+	return targets, nil
+}
+
+// makeWritable adds write permission to the files under path that were
+// made read-only by a build or archive, so they can be removed.
+func makeWritable(path string) {
+	filepath.Walk(longPath(path), func(path string, finfo os.FileInfo, err error) error {
+		if err == nil && finfo.Mode()&os.ModeSymlink == 0 && finfo.Mode().Perm()&0200 == 0 {
+			os.Chmod(path, finfo.Mode().Perm()|0200)
+		}
+		return nil
+	})
+}
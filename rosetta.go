@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// translated reports whether groot is an amd64 binary running
+// under Rosetta on Apple Silicon.
+func translated() bool {
+	if runtime.GOOS != "darwin" || runtime.GOARCH != "amd64" {
+		return false
+	}
+	out, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "1"
+}
+
+// warnTranslated warns that amd64 toolchains will be installed
+// when running under Rosetta without an --arch override.
+func (g *groot) warnTranslated() {
+	if g.arch == "" && translated() {
+		warnln("WARNING: groot is running under Rosetta, so amd64 toolchains will be installed and run translated.\n" +
+			"Use --arch arm64 to install native toolchains.")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Modes of the directories and state files groot creates. Shared
+// installations make them accessible to the owning group.
+var (
+	stateDirMode  os.FileMode = 0700
+	stateFileMode os.FileMode = 0600
+)
+
+// mutatesShared lists the commands that write to the shared
+// groot directory. activate and deactivate only change the
+// per-user active link.
+var mutatesShared = map[string]bool{
+	"add":     true,
+	"clean":   true,
+	"init":    true,
+	"label":   true,
+	"note":    true,
+	"prune":   true,
+	"rebuild": true,
+	"remove":  true,
+	"repair":  true,
+	"reset":   true,
+	"update":  true,
+	"upgrade": true,
+}
+
+// setShared configures shared installation mode. The shared directory
+// holds the toolchains, while each user's active version is recorded at
+// userActive so activate doesn't require write access to it.
+func (g *groot) setShared(userActive string) error {
+	g.shared = true
+	g.paths.active = userActive
+
+	mode := 0770 | os.ModeSetgid
+	if g.config.DirMode != "" {
+		m, err := strconv.ParseUint(g.config.DirMode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid dir_mode %q in %s: %v", g.config.DirMode, g.paths.config, err)
+		}
+		mode = os.FileMode(m) & os.ModePerm
+		if m&02000 != 0 {
+			mode |= os.ModeSetgid
+		}
+	}
+	stateDirMode = mode
+	stateFileMode = mode.Perm() &^ 0111
+
+	// Keep worktrees and build output group-accessible too.
+	setUmask(int(^mode.Perm() & 0077))
+	return nil
+}
+
+// mkdirAll creates dir and any missing parents with stateDirMode.
+// The mode is applied explicitly so it isn't reduced by the umask.
+func mkdirAll(dir string) error {
+	_, err := os.Stat(dir)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = mkdirAll(filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+
+	err = os.Mkdir(dir, stateDirMode.Perm())
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chmod(dir, stateDirMode)
+}
+
+// checkWritable returns an error describing how to get access
+// if dir, or the nearest existing parent, isn't writable.
+func checkWritable(dir string) error {
+	for {
+		_, err := os.Stat(dir)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	f, err := ioutil.TempFile(dir, ".groot-write-check")
+	if err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil
+	}
+	if !os.IsPermission(err) {
+		return err
+	}
+
+	if group := dirGroup(dir); group != "" {
+		return fmt.Errorf("%s is not writable; run as a user in group %s or with sudo", dir, group)
+	}
+	return fmt.Errorf("%s is not writable; run as a user with write access or with sudo", dir)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// dirGroup returns the name of the group owning dir, if known.
+func dirGroup(dir string) string { return "" }
+
+func setUmask(mask int) {}
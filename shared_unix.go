@@ -0,0 +1,35 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// dirGroup returns the name of the group owning dir, if known.
+func dirGroup(dir string) string {
+	finfo, err := os.Stat(dir)
+	if err != nil {
+		return ""
+	}
+	st, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	gid := strconv.FormatUint(uint64(st.Gid), 10)
+	group, err := user.LookupGroupId(gid)
+	if err != nil {
+		return gid
+	}
+	return group.Name
+}
+
+// setUmask sets the process umask, which also applies to
+// the git and make.bash subprocesses groot runs.
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
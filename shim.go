@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// shimMarker is written to a shim bin directory to record
+// which version it points to.
+const shimMarker = ".groot-active"
+
+// writeShims populates dir with scripts that exec each
+// executable in the version's bin directory.
+func writeShims(dir, tag, bin string) error {
+	finfos, err := ioutil.ReadDir(bin)
+	if err != nil {
+		return err
+	}
+
+	err = mkdirAll(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, finfo := range finfos {
+		if finfo.IsDir() {
+			continue
+		}
+
+		target := filepath.Join(bin, finfo.Name())
+		name, script := shimScript(finfo.Name(), target)
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, shimMarker), []byte(tag+"\n"), 0644)
+}
+
+func shimScript(name, target string) (string, string) {
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".cmd"
+		return name, fmt.Sprintf("@\"%s\" %%*\r\n", target)
+	}
+	return name, fmt.Sprintf("#!/bin/sh\nexec \"%s\" \"$@\"\n", target)
+}
+
+// generationsDir holds the shim generations of shim_generations mode,
+// beside the active link since that's per user in shared mode.
+func (g *groot) generationsDir() string {
+	return filepath.Join(filepath.Dir(g.paths.active), ".bin-generations")
+}
+
+// newShimGeneration writes a new directory of shims for tag and
+// returns it, removing the generations left from before the last boot.
+// Generations are never rewritten, so a path resolved through the
+// active link keeps running the same version until the process that
+// resolved it restarts, even if another version is activated meanwhile.
+func (g *groot) newShimGeneration(tag, bin string) (string, error) {
+	dir := g.generationsDir()
+	g.pruneShimGenerations()
+
+	gen := filepath.Join(dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), tag))
+	err := writeShims(gen, tag, bin)
+	if err != nil {
+		os.RemoveAll(gen)
+		return "", err
+	}
+	return gen, nil
+}
+
+// pruneShimGenerations removes the generations created before the last
+// boot, which no running process can refer to. Where the boot time
+// isn't known, generations a day old are removed instead.
+func (g *groot) pruneShimGenerations() {
+	cutoff, ok := bootTime()
+	if !ok {
+		cutoff = time.Now().Add(-24 * time.Hour)
+	}
+	active, _ := os.Readlink(g.paths.active)
+
+	finfos, err := ioutil.ReadDir(g.generationsDir())
+	if err != nil {
+		return
+	}
+	for _, finfo := range finfos {
+		path := filepath.Join(g.generationsDir(), finfo.Name())
+		if finfo.ModTime().Before(cutoff) && path != active {
+			debugln("Removing shim generation", path)
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// bootTime returns when the system booted, where that can be read
+// cheaply, which is on Linux.
+func bootTime() (time.Time, bool) {
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err == nil {
+				return time.Unix(sec, 0), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// readShimMarker returns the version a shim directory points to.
+func readShimMarker(dir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, shimMarker))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s is not managed by groot", dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// symlinkUnsupported reports whether err indicates the
+// filesystem or user isn't able to create symlinks.
+func symlinkUnsupported(err error) bool {
+	if os.IsPermission(err) {
+		return true
+	}
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	const errorPrivilegeNotHeld = 1314 // Windows
+	return errno == syscall.EPERM || errno == errorPrivilegeNotHeld
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestShimGenerations activates two versions in shim_generations mode
+// and checks that a path resolved through bin before the second
+// activation still runs the first version.
+func TestShimGenerations(t *testing.T) {
+	home := newTestHome(t)
+	writeTestFile(t, filepath.Join(home, "config.json"), `{"shim_generations": true}`, 0644)
+	goVersion := func(goCmd string) string {
+		t.Helper()
+		out, err := exec.Command(goCmd, "version").Output()
+		if err != nil {
+			t.Fatalf("%s version: %v", goCmd, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	active := filepath.Join(home, "bin")
+
+	mustRunGroot(t, home, "add", "go1.21.0")
+	mustRunGroot(t, home, "add", "go1.22.0")
+
+	mustRunGroot(t, home, "activate", "go1.21.0")
+	first, err := os.Readlink(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, err := readShimMarker(first); err != nil || tag != "go1.21.0" {
+		t.Fatalf("bin links to %s, marked %q, %v; want a generation of go1.21.0", first, tag, err)
+	}
+	// What a shell would have cached for `go`.
+	resolved, err := filepath.EvalSymlinks(filepath.Join(active, "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustRunGroot(t, home, "activate", "go1.22.0")
+	second, err := os.Readlink(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatalf("activating go1.22.0 reused the generation %s", first)
+	}
+	if got, want := goVersion(resolved), "go version go1.21.0 linux/amd64"; got != want {
+		t.Errorf("the first generation's go reports %q, want %q", got, want)
+	}
+	if got, want := goVersion(filepath.Join(active, "go")), "go version go1.22.0 linux/amd64"; got != want {
+		t.Errorf("bin/go reports %q, want %q", got, want)
+	}
+
+	// Activating the first version again makes a new generation
+	// rather than rewriting the old one.
+	mustRunGroot(t, home, "activate", "go1.21.0")
+	third, err := os.Readlink(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || third == second {
+		t.Errorf("activating go1.21.0 again reused the generation %s", third)
+	}
+	finfos, err := ioutil.ReadDir(filepath.Join(home, ".bin-generations"))
+	if err != nil || len(finfos) != 3 {
+		t.Errorf("%d generations, %v; want 3", len(finfos), err)
+	}
+}
+
+// TestActivateLocalOnly checks that activate --local-only prints the
+// environment without touching bin.
+func TestActivateLocalOnly(t *testing.T) {
+	home := newTestHome(t)
+	active := filepath.Join(home, "bin")
+
+	mustRunGroot(t, home, "add", "go1.21.0")
+	mustRunGroot(t, home, "add", "go1.22.0")
+
+	// With nothing active, bin isn't created.
+	out := mustRunGroot(t, home, "activate", "--local-only", "go1.22.0")
+	if !strings.Contains(out, filepath.Join(home, "go1.22.0", "bin")) {
+		t.Errorf("activate --local-only printed\n%s\nwant the environment of go1.22.0", out)
+	}
+	if _, err := os.Lstat(active); !os.IsNotExist(err) {
+		t.Errorf("activate --local-only created bin: %v", err)
+	}
+
+	mustRunGroot(t, home, "activate", "go1.21.0")
+	before, err := os.Readlink(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRunGroot(t, home, "activate", "--local-only", "go1.22.0")
+	after, err := os.Readlink(active)
+	if err != nil || after != before {
+		t.Errorf("after activate --local-only, bin links to %s, %v; want %s", after, err, before)
+	}
+	if got := strings.TrimSpace(mustRunGroot(t, home, "current")); !strings.HasPrefix(got, "go1.21.0") {
+		t.Errorf("current = %q, want go1.21.0", got)
+	}
+}
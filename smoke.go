@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// smokeLogFile is where the output of the smoke test is written,
+// in the version directory.
+const smokeLogFile = ".groot-smoke.log"
+
+const smokeTimeout = 5 * time.Minute
+
+const smokeProgram = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello from groot")
+}
+`
+
+// smokeTest checks that the toolchain of name, installed as inst,
+// works: make.bash can succeed and an extraction complete yet leave a
+// broken toolchain, after a bootstrap problem or a full disk. It runs
+// go version, checks go env GOROOT, and builds and runs a hello world
+// program with a build cache of its own, logging the output to
+// smokeLogFile.
+func (g *groot) smokeTest(name string, inst installState) error {
+	dir := g.versionDir(name)
+	logFile, err := os.Create(filepath.Join(dir, smokeLogFile))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	tmp, err := ioutil.TempDir("", "groot-smoke-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	err = ioutil.WriteFile(filepath.Join(tmp, "hello.go"), []byte(smokeProgram), 0644)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(g.context(), smokeTimeout)
+	defer cancel()
+	run := func(args ...string) (string, error) {
+		fmt.Fprintf(logFile, "$ go %s\n", strings.Join(args, " "))
+		var out bytes.Buffer
+		cmd := g.goCmdContext(ctx, name, args...)
+		cmd.Dir = tmp
+		cmd.Env = smokeEnv(cmd.Env, tmp)
+		if want := inst.goroot(dir); want != dir {
+			// Built for another location, it only runs here with GOROOT set.
+			cmd.Env = append(cmd.Env, "GOROOT="+dir)
+		}
+		cmd.Stdout = io.MultiWriter(&out, logFile)
+		cmd.Stderr = logFile
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", smokeTimeout)
+		}
+		if err != nil {
+			fmt.Fprintln(logFile, err)
+			return "", fmt.Errorf("go %s: %v", strings.Join(args, " "), err)
+		}
+		return out.String(), nil
+	}
+
+	debugln("Smoke testing", name)
+	out, err := run("version")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(out, "go version ") {
+		return fmt.Errorf("go version printed %q", strings.TrimSpace(out))
+	}
+
+	err = g.checkGOROOTIs(name, inst.goroot(dir))
+	if err != nil {
+		fmt.Fprintln(logFile, err)
+		return err
+	}
+
+	out, err = run("run", "hello.go")
+	if err != nil {
+		return err
+	}
+	if want := "hello from groot\n"; out != want {
+		return fmt.Errorf("hello world printed %q, not %q", out, want)
+	}
+	return nil
+}
+
+// smokeEnv returns the environment of the smoke test, base without
+// the user's Go settings so they can't make it pass or fail.
+func smokeEnv(base []string, tmp string) []string {
+	var env []string
+	for _, kv := range base {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "GOROOT", "GOPATH", "GOCACHE", "GOFLAGS", "GOTOOLCHAIN", "GO111MODULE", "GOOS", "GOARCH":
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env,
+		"GOPATH="+filepath.Join(tmp, "gopath"),
+		"GOCACHE="+filepath.Join(tmp, "cache"),
+		// Go 1.21 and later could otherwise switch to another toolchain.
+		"GOTOOLCHAIN=local",
+	)
+}
+
+// smokeFailure records that name failed the smoke test with err and
+// returns the error to report, pointing at the log.
+func (g *groot) smokeFailure(name string, inst installState, err error) error {
+	inst.Failed = err.Error()
+	if rerr := g.recordInstall(name, inst); rerr != nil {
+		warnln("Recording the failed install:", rerr)
+	}
+	return fmt.Errorf("%s was installed but failed its smoke test: %v\n"+
+		"The install is kept for inspection and marked failed; see %s", name, err, filepath.Join(g.versionDir(name), smokeLogFile))
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotPrefix starts the name of every archived tip build,
+// followed by the date it was built, e.g. tip-2024-05-03.
+const snapshotPrefix = tipTag + "-"
+
+// snapshotArchiveDir is the directory under the versions directory
+// holding snapshots stored with --archive.
+const snapshotArchiveDir = ".snapshots"
+
+// isSnapshot reports whether name is a tip snapshot.
+func isSnapshot(name string) bool {
+	return strings.HasPrefix(name, snapshotPrefix)
+}
+
+func (g *groot) snapshotArchive(name string) string {
+	return filepath.Join(g.paths.base, snapshotArchiveDir, name+".tar.gz")
+}
+
+// snapshot is an archived tip build.
+type snapshot struct {
+	name     string
+	archived bool // stored as a tarball rather than a directory
+}
+
+// snapshots returns the tip snapshots, oldest first.
+func (g *groot) snapshots() ([]snapshot, error) {
+	names, err := g.installed()
+	if err != nil {
+		return nil, err
+	}
+
+	var snaps []snapshot
+	for _, name := range names {
+		if isSnapshot(name) {
+			snaps = append(snaps, snapshot{name: name})
+		}
+	}
+
+	finfos, err := ioutil.ReadDir(filepath.Join(g.paths.base, snapshotArchiveDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, finfo := range finfos {
+		name := strings.TrimSuffix(finfo.Name(), ".tar.gz")
+		if _, err := os.Stat(longPath(g.versionDir(name))); err == nil {
+			// Already extracted.
+			continue
+		}
+		if isSnapshot(name) && name != finfo.Name() {
+			snaps = append(snaps, snapshot{name: name, archived: true})
+		}
+	}
+
+	// Dates sort lexically.
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].name < snaps[j].name })
+	return snaps, nil
+}
+
+// snapshotName returns an unused snapshot name for a build from t.
+func (g *groot) snapshotName(t time.Time) string {
+	base := snapshotPrefix + t.Format("2006-01-02")
+	name := base
+	for i := 2; g.exists(name); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
+
+// exists reports whether name is installed, either as a directory
+// or an archived snapshot.
+func (g *groot) exists(name string) bool {
+	if _, err := os.Stat(longPath(g.versionDir(name))); err == nil {
+		return true
+	}
+	_, err := os.Stat(g.snapshotArchive(name))
+	return err == nil
+}
+
+func update(fs *flag.FlagSet, g *groot) func(args []string) error {
+	keep := fs.Int("keep", 0, "keep the previous tip build and up to `n` snapshots in total")
+	archive := fs.Bool("archive", false, "with --keep, store snapshots as tarballs")
+	yes := fs.Bool("yes", false, "fast-forward and rebuild a branch-based install without asking")
+	rebuild := fs.Bool("rebuild", false, "fast-forward a branch-based install and rebuild it in place, even if it's up to date")
+	return func(args []string) error {
+		var err error
+		if len(args) > 1 || (*keep > 0 || *archive) && (len(args) == 0 || args[0] != tipTag) ||
+			*rebuild && (len(args) == 0 || args[0] == tipTag) {
+			return errUsage
+		}
+		var inst installState
+		if len(args) == 1 && args[0] != tipTag {
+			if !g.exists(args[0]) {
+				return g.notInstalled(args[0])
+			}
+			inst, err = g.installInfo(args[0])
+			if err != nil {
+				return err
+			}
+			if !tracksRef(args[0], inst) {
+				return fmt.Errorf("%s isn't a git worktree built from a branch or tag, so it can't be updated", args[0])
+			}
+		}
+
+		err = g.ensureClone()
+		if err != nil {
+			return err
+		}
+
+		err = g.fetch()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(args) == 0:
+			drifts, err := g.refDrifts()
+			if err != nil {
+				debugln(err)
+			}
+			for _, d := range drifts {
+				if d.behind > 0 || d.ahead > 0 || d.tagMoved {
+					fmt.Println(d)
+				}
+			}
+		case args[0] == tipTag:
+			err = g.updateTip(*keep, *archive)
+		case inst.Provenance.Source == sourceTag && *rebuild:
+			err = fmt.Errorf("%s is built from the tag %s, which doesn't move; use `groot rebuild %s` to rebuild it", args[0], inst.Provenance.Ref, args[0])
+		case inst.Provenance.Source == sourceTag:
+			var d refDrift
+			d, err = g.refDrift(args[0], inst)
+			if err == nil && d.tagMoved {
+				err = fmt.Errorf("%v", d)
+			} else if err == nil {
+				fmt.Printf("%s is built from the tag %s, which doesn't move; nothing to update\n", args[0], d.ref)
+			}
+		default:
+			err = g.updateBranch(args[0], inst, *yes || *rebuild, *rebuild)
+		}
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// fetch updates the branches and tags of the bare repo.
+func (g *groot) fetch() error {
+	err := g.backend().fetch(g.repoURL())
+	if err != nil {
+		return err
+	}
+	return g.invalidateTags()
+}
+
+// updateTip rebuilds tip at the current master. If keep is greater
+// than zero the previous build is kept as a snapshot and snapshots
+// beyond the newest keep are removed.
+func (g *groot) updateTip(keep int, archive bool) error {
+	dir := g.versionDir(tipTag)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%s isn't installed; run `groot add %s`", tipTag, tipTag)
+	}
+
+	inst, err := g.installInfo(tipTag)
+	if err != nil {
+		return err
+	}
+	if inst.Detached {
+		return fmt.Errorf("%s was detached from git and can't be updated in place; use `groot add --force %s`", tipTag, tipTag)
+	}
+
+	if keep > 0 {
+		built := inst.Installed
+		if built.IsZero() {
+			built = time.Now()
+		}
+		name := g.snapshotName(built)
+		err = g.snapshot(dir, name, archive)
+		if err != nil {
+			return fmt.Errorf("saving snapshot %s: %v", name, err)
+		}
+		snap := inst
+		snap.Installed = built
+		err = g.updateState(func(s *state) error {
+			s.Installs[name] = &snap
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		infoln("Saved previous", tipTag, "build as", name)
+	}
+
+	err = g.backend().resetWorktree(dir, "master")
+	if err != nil {
+		return err
+	}
+
+	err = g.build(tipTag, inst.options())
+	if err != nil {
+		return err
+	}
+
+	if keep > 0 {
+		return g.pruneSnapshots(keep)
+	}
+	return nil
+}
+
+// snapshot copies the build in dir to the snapshot name. The copy
+// is a plain GOROOT, detached from the bare repo.
+func (g *groot) snapshot(dir, name string, archive bool) error {
+	if archive {
+		return writeSnapshotArchive(dir, g.snapshotArchive(name))
+	}
+	return copyTree(dir, g.versionDir(name))
+}
+
+// skipSnapshot reports whether rel, a path relative to the root of
+// a worktree, is left out of snapshots.
+func skipSnapshot(rel string) bool {
+	// The .git file links the worktree to the bare repo.
+	return rel == ".git" || rel == goGitHead
+}
+
+// copyTree copies the regular files and directories under src to dst.
+func copyTree(src, dst string) error {
+	var dirs dirTimes
+	err := filepath.Walk(src, func(path string, finfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if skipSnapshot(rel) {
+			return nil
+		}
+		target := longPath(filepath.Join(dst, rel))
+
+		switch {
+		case finfo.IsDir():
+			err := mkdirExtracted(target, finfo.Mode())
+			if err != nil {
+				return err
+			}
+			dirs.add(target, finfo.ModTime())
+		case finfo.Mode().IsRegular():
+			f, err := os.Open(longPath(path))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, finfo.Mode().Perm(), finfo.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return dirs.apply()
+}
+
+// writeSnapshotArchive writes the tarball of dir to path. The
+// tarball is written to a temporary file first so an interrupted
+// update doesn't leave a truncated snapshot behind.
+func writeSnapshotArchive(dir, path string) error {
+	err := mkdirAll(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = writeTarGz(f, dir)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(f.Name(), stateFileMode)
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// writeTarGz writes the regular files and directories under dir to w,
+// in the layout of a binary release so it can be read by extractTarGz.
+func writeTarGz(w io.Writer, dir string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := filepath.Walk(dir, func(path string, finfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || skipSnapshot(rel) || !finfo.IsDir() && !finfo.Mode().IsRegular() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(finfo, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = "go/" + filepath.ToSlash(rel)
+		if finfo.IsDir() {
+			hdr.Name += "/"
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil || finfo.IsDir() {
+			return err
+		}
+
+		f, err := os.Open(longPath(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// restoreSnapshot extracts name if it's an archived snapshot
+// that hasn't been extracted yet.
+func (g *groot) restoreSnapshot(name string) error {
+	if !isSnapshot(name) {
+		return nil
+	}
+	if _, err := os.Stat(longPath(g.versionDir(name))); err == nil {
+		return nil
+	}
+
+	f, err := os.Open(g.snapshotArchive(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	infoln("Extracting snapshot", name)
+	return extractTarGz(f, g.versionDir(name))
+}
+
+// pruneSnapshots removes the oldest snapshots so that at most keep
+// remain. The active version is never removed.
+func (g *groot) pruneSnapshots(keep int) error {
+	snaps, err := g.snapshots()
+	if err != nil {
+		return err
+	}
+	active, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(snaps)-keep; i++ {
+		name := snaps[i].name
+		if name == active {
+			continue
+		}
+
+		infoln("Removing snapshot", name)
+		err := os.RemoveAll(longPath(g.versionDir(name)))
+		if err != nil {
+			return err
+		}
+		err = os.Remove(g.snapshotArchive(name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err = g.updateState(func(s *state) error {
+			delete(s.Installs, name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Install kinds.
+const (
+	kindSource = "source"
+	kindBinary = "binary"
+)
+
+// state is groot's record of installed versions, kept in
+// state.json in the state directory.
+type state struct {
+	Installs map[string]*installState `json:"installs,omitempty"`
+
+	// DefaultBootstrap is the downloaded bootstrap toolchain used
+	// when a build doesn't choose one, .binary if empty.
+	DefaultBootstrap string `json:"default_bootstrap,omitempty"`
+
+	// PreferredBootstrap is an installed version used as the
+	// bootstrap in preference to the downloaded ones.
+	PreferredBootstrap string `json:"preferred_bootstrap,omitempty"`
+
+	// BinaryOnly is set by init --binary-only, which skips the
+	// bootstrap and clone until a version is built from source.
+	BinaryOnly bool `json:"binary_only,omitempty"`
+
+	// RepoURL is the mirror of the Go repository given to init, which
+	// is cloned and fetched instead of defaultRepoURL.
+	RepoURL string `json:"repo_url,omitempty"`
+}
+
+// installState records how a version was installed.
+type installState struct {
+	Tag       string    `json:"tag"`
+	Kind      string    `json:"kind"`
+	Installed time.Time `json:"installed"`
+
+	// Build settings of source installs, used to rebuild them.
+	Minimal   bool     `json:"minimal,omitempty"`
+	Bootstrap string   `json:"bootstrap,omitempty"`
+	Env       []string `json:"env,omitempty"`
+
+	// GOROOTFinal is the GOROOT_FINAL the version was built with, for
+	// installing it elsewhere.
+	GOROOTFinal string `json:"goroot_final,omitempty"`
+
+	// Experiment is the GOEXPERIMENT set the version was built with.
+	Experiment string `json:"experiment,omitempty"`
+
+	// BootstrapVersion is the version of the bootstrap toolchain
+	// that built a source install.
+	BootstrapVersion string `json:"bootstrap_version,omitempty"`
+
+	// Detached source installs are plain directories, no longer
+	// worktrees, built from Commit.
+	Detached bool   `json:"detached,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+
+	// Provenance is where the install came from.
+	Provenance *provenance `json:"provenance,omitempty"`
+
+	// Failed is why the install failed the smoke test run after it
+	// was built or extracted; activate refuses it unless forced.
+	Failed string `json:"failed,omitempty"`
+
+	// NotBuilt source installs were only checked out, by add
+	// --no-build, and have no go command until they're built.
+	NotBuilt bool `json:"not_built,omitempty"`
+
+	// Labels and Note are set by the user to keep track of installs.
+	Labels map[string]string `json:"labels,omitempty"`
+	Note   string            `json:"note,omitempty"`
+}
+
+// ref returns the git revision inst was checked out from.
+func (inst installState) ref() string {
+	return buildOptions{tag: inst.Tag}.ref()
+}
+
+// options returns the options inst was built with. Variant settings
+// such as GOAMD64 are carried in the environment.
+func (inst installState) options() buildOptions {
+	return buildOptions{
+		tag:       inst.Tag,
+		bootstrap: inst.Bootstrap,
+		extraEnv:  inst.Env,
+		minimal:   inst.Minimal,
+
+		gorootFinal: inst.GOROOTFinal,
+	}
+}
+
+func (g *groot) statePath() string {
+	return filepath.Join(g.paths.state, "state.json")
+}
+
+func (g *groot) loadState() (*state, error) {
+	s := new(state)
+	err := readJSONFile(g.statePath(), s)
+	if s.Installs == nil {
+		s.Installs = make(map[string]*installState)
+	}
+	return s, err
+}
+
+// updateState applies fn to the state file under an exclusive lock.
+func (g *groot) updateState(fn func(*state) error) error {
+	s := new(state)
+	return updateJSONFile(g.statePath(), s, func() error {
+		if s.Installs == nil {
+			s.Installs = make(map[string]*installState)
+		}
+		return fn(s)
+	})
+}
+
+// recordInstall adds an installed version to the state file.
+func (g *groot) recordInstall(name string, inst installState) error {
+	inst.Installed = time.Now()
+	return g.updateState(func(s *state) error {
+		s.Installs[name] = &inst
+		return nil
+	})
+}
+
+// installInfo returns what's recorded about an installed version.
+// Versions installed before state was recorded are inferred from
+// their directory.
+func (g *groot) installInfo(name string) (installState, error) {
+	s, err := g.loadState()
+	if err != nil {
+		return installState{}, err
+	}
+	if inst, ok := s.Installs[name]; ok {
+		i := *inst
+		g.backfillProvenance(&i, true)
+		return i, nil
+	}
+
+	inst := installState{Tag: name, Kind: kindBinary}
+	for _, marker := range []string{".git", goGitHead} {
+		if _, err := os.Stat(filepath.Join(g.versionDir(name), marker)); err == nil {
+			inst.Kind = kindSource
+		}
+	}
+	g.backfillProvenance(&inst, false)
+	return inst, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// State files under the groot directory may be read and written by
+// concurrent groot invocations. Writes go to a temporary file that's
+// renamed into place so readers never observe a partial file, and
+// access is coordinated with a lock on a sibling ".lock" file (the
+// state file itself is replaced on every write, so it can't hold the lock).
+
+// writeFileAtomic replaces path with data.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// withFileLock runs fn while holding a lock associated with path.
+func withFileLock(path string, exclusive bool, fn func() error) error {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, stateFileMode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Allow other users of a shared installation to take the lock,
+	// this fails harmlessly if the lock file is owned by someone else.
+	f.Chmod(stateFileMode)
+
+	err = lockFile(f, exclusive)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
+
+// readFileShared reads path while holding a shared lock. A missing
+// file is reported as os.IsNotExist without creating a lock file.
+func readFileShared(path string) ([]byte, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = withFileLock(path, false, func() error {
+		var err error
+		data, err = ioutil.ReadFile(path)
+		return err
+	})
+	return data, err
+}
+
+// readJSONFile decodes path into v. v is left unchanged if
+// path doesn't exist.
+func readJSONFile(path string, v interface{}) error {
+	data, err := readFileShared(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONFile encodes v to path, holding an exclusive lock.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = mkdirAll(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(path, true, func() error {
+		return writeFileAtomic(path, append(data, '\n'), stateFileMode)
+	})
+}
+
+// updateJSONFile decodes path into v, calls fn to modify it,
+// and writes v back, holding an exclusive lock throughout.
+func updateJSONFile(path string, v interface{}, fn func() error) error {
+	err := mkdirAll(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(path, true, func() error {
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, v)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		err = fn()
+		if err != nil {
+			return err
+		}
+
+		data, err = json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, append(data, '\n'), stateFileMode)
+	})
+}
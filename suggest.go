@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// notInstalled returns the error for a spec that doesn't match an
+// installed version, suggesting the closest one and listing them all.
+func (g *groot) notInstalled(spec string) error {
+	names, err := g.installed()
+	if err != nil || len(names) == 0 {
+		return fmt.Errorf("%s isn't installed; no versions are installed, see `groot add`", spec)
+	}
+	if s := closestVersion(spec, names); s != "" {
+		return fmt.Errorf("%s isn't installed; did you mean %s? Installed: %s", spec, s, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("%s isn't installed. Installed: %s", spec, strings.Join(names, ", "))
+}
+
+// closestVersion returns the name among names that spec most likely
+// meant, or "" if none is close. Releases are compared by version, so
+// go1.8 suggests go1.9 rather than go1.18, and builds of the same
+// release with other options, such as go1.21-goamd64v3, come first;
+// other names are compared by edit distance.
+func closestVersion(spec string, names []string) string {
+	wv, wok := parseVersion(normalizeTag(spec))
+
+	best, bestDist := "", -1
+	for _, name := range names {
+		dist := -1
+		v, ok := parseVersion(name)
+		switch {
+		case wok && strings.HasPrefix(name, wv.String()+"-"):
+			dist = 0
+		case wok && ok:
+			dist = versionDistance(wv, v)
+		default:
+			if d := levenshtein(spec, name); d <= 2 && d < len(spec) {
+				dist = d
+			}
+		}
+		if dist < 0 || bestDist >= 0 && dist > bestDist {
+			continue
+		}
+		// Ties go to the newer release.
+		if dist == bestDist {
+			if b, bok := parseVersion(best); !ok || !bok || !b.less(v) {
+				continue
+			}
+		}
+		best, bestDist = name, dist
+	}
+	return best
+}
+
+// versionDistance returns how far apart releases v and w are, or -1
+// if they're too far apart to be mistaken for each other.
+func versionDistance(v, w version) int {
+	abs := func(n int) int {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	minor := abs(v.minor - w.minor)
+	if v.major != w.major || minor > 2 {
+		return -1
+	}
+	dist := minor*1000 + abs(v.patch-w.patch)*10
+	if v.pre != w.pre || v.preNum != w.preNum {
+		dist++
+	}
+	return dist
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vcabbage/groot/internal/godl"
+)
+
+// supportedMinors is the number of minor release lines that receive
+// security fixes upstream: the newest two.
+const supportedMinors = 2
+
+// eolNote is shown next to versions of unsupported release lines.
+const eolNote = "EOL — no longer receives security updates"
+
+// supportedLines returns the newest supportedMinors minor release lines
+// with a stable release among tags, such as "go1.22" and "go1.21".
+func supportedLines(tags []string) map[string]bool {
+	newest := latestPerMinor(tags)
+	lines := make(map[string]bool)
+	for i := len(newest) - 1; i >= 0 && len(lines) < supportedMinors; i-- {
+		v, _ := parseVersion(newest[i])
+		lines[v.minorLine()] = true
+	}
+	return lines
+}
+
+// supportWindow returns the supported minor release lines. The current
+// releases listed on go.dev are preferred, since they reflect any
+// irregular support decisions, falling back to the newest lines of the
+// known releases.
+func (g *groot) supportWindow() (map[string]bool, error) {
+	body, err := g.fetchCached(godl.CurrentURL, "current.json")
+	if err == nil {
+		var rels []godl.Release
+		rels, err = godl.Parse(body)
+		lines := make(map[string]bool)
+		for _, rel := range rels {
+			if v, ok := parseVersion(rel.Version); ok && rel.Stable {
+				lines[v.minorLine()] = true
+			}
+		}
+		if err == nil && len(lines) > 0 {
+			return lines, nil
+		}
+	}
+	debugln("Deriving the supported releases from the known releases:", err)
+
+	tags, err := g.knownReleases()
+	if err != nil {
+		return nil, err
+	}
+	lines := supportedLines(tags)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no releases found")
+	}
+	return lines, nil
+}
+
+// knownReleases returns the stable releases from go.dev or the tags of
+// the bare repo, as for latest, in ascending order.
+func (g *groot) knownReleases() ([]string, error) {
+	var tags []string
+	if g.useGoDev() {
+		rels, err := g.releases()
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range rels {
+			tags = append(tags, rel.Version)
+		}
+	} else {
+		var err error
+		tags, err = g.tags()
+		if err != nil {
+			return nil, err
+		}
+	}
+	tags = stableTags(tags)
+	sortTags(tags)
+	return tags, nil
+}
+
+// eol reports whether tag is a release of a minor line older than the
+// supported ones. Newer lines, such as a release candidate's, and
+// builds that aren't releases are never EOL.
+func eol(tag string, supported map[string]bool) bool {
+	v, ok := parseVersion(tag)
+	if !ok || supported[v.minorLine()] {
+		return false
+	}
+	for line := range supported {
+		if s, ok := parseVersion(line); ok && v.less(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// newerPatch returns the newest stable release of tag's minor line if
+// it's newer than tag, or "".
+func newerPatch(tag string, releases []string) string {
+	v, ok := parseVersion(tag)
+	if !ok {
+		return ""
+	}
+	newest := ""
+	for _, rel := range releases {
+		r, ok := parseVersion(rel)
+		if ok && r.stable() && r.minorLine() == v.minorLine() && v.less(r) {
+			newest = rel
+			v = r
+		}
+	}
+	return newest
+}
+
+func status(g *groot, _ []string) error {
+	active, err := g.activeVersion()
+	if err != nil {
+		return err
+	}
+	supported, err := g.supportWindow()
+	if err != nil {
+		warnln("Determining the supported releases:", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if _, target := g.danglingActive(); target != "" {
+		fmt.Fprintf(w, "Active:\t%s, missing\n", active)
+		fmt.Fprintf(w, "\t%v\n", g.danglingError(target))
+	} else if active == "" {
+		fmt.Fprintln(w, "Active:\tnone")
+	} else {
+		inst, err := g.installInfo(active)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Active:\t%s\n", active)
+		if p, local, err := g.localVersion(); err == nil && p != "" && local != active {
+			fmt.Fprintf(w, "\t%s requests %s; run `groot activate` to switch\n", p, local)
+		}
+		if eol(inst.Tag, supported) {
+			fmt.Fprintf(w, "\t%s\n", eolNote)
+		}
+		if releases, err := g.knownReleases(); err == nil {
+			if patch := newerPatch(inst.Tag, releases); patch != "" {
+				fmt.Fprintf(w, "\t%s is available; run `groot upgrade`\n", patch)
+			}
+		}
+	}
+
+	names, err := g.installed()
+	if err != nil {
+		return err
+	}
+	var old []string
+	for _, name := range names {
+		inst, err := g.installInfo(name)
+		if err == nil && eol(inst.Tag, supported) {
+			old = append(old, name)
+		}
+	}
+	fmt.Fprintf(w, "Installed:\t%d versions\n", len(names))
+	if len(old) > 0 {
+		fmt.Fprintf(w, "\t%s: %s\n", eolNote, strings.Join(old, ", "))
+	}
+	for _, d := range g.fetchedRefDrifts(names) {
+		switch {
+		case d.tagMoved:
+			fmt.Fprintf(w, "\t%s\n", d)
+		case d.behind > 0:
+			fmt.Fprintf(w, "\t%s; run `groot update %s`\n", d, d.name)
+		case d.ahead > 0:
+			fmt.Fprintf(w, "\t%s\n", d)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// upgrade installs the newest patch release of the active version's
+// minor line, the same way the active version was installed, and
+// activates it. With --check it only reports whether there is one.
+func upgrade(fs *flag.FlagSet, g *groot) func(args []string) error {
+	fs.BoolVar(&g.refresh, "refresh", false, "revalidate cached release metadata")
+	check := fs.Bool("check", false, "only report whether a newer patch release is available")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		active, err := g.activeVersion()
+		if err != nil {
+			return err
+		}
+		if active == "" {
+			return fmt.Errorf("no version is active; use `groot activate` first")
+		}
+		inst, err := g.installInfo(active)
+		if err != nil {
+			return err
+		}
+		v, ok := parseVersion(inst.Tag)
+		if !ok {
+			fmt.Println(active, "isn't a release; use `groot update` to update it")
+			return nil
+		}
+
+		releases, err := g.knownReleases()
+		if err != nil {
+			return err
+		}
+		patch := newerPatch(inst.Tag, releases)
+		if patch == "" {
+			fmt.Println(active, "is the newest patch release of", v.minorLine())
+			if supported, err := g.supportWindow(); err == nil && eol(inst.Tag, supported) {
+				fmt.Printf("%s is %s; consider a newer release\n", v.minorLine(), eolNote)
+			}
+			return nil
+		}
+		if *check {
+			fmt.Printf("%s is available, the active version is %s; run `groot upgrade`\n", patch, active)
+			return nil
+		}
+
+		var addArgs []string
+		if inst.Kind == kindBinary {
+			addArgs = append(addArgs, "--binary")
+		}
+		if !g.exists(patch) {
+			err = g.invoke("add", add, append(addArgs, patch)...)
+			if err != nil {
+				return err
+			}
+		}
+		err = g.activate(patch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Upgraded from %s to %s\n", active, patch)
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// testCmd runs the tests of an installed version's own tree with its
+// own go command, or the whole of run.bash or all.bash.
+func testCmd(fs *flag.FlagSet, g *groot) func(args []string) error {
+	full := fs.Bool("full", false, "run run.bash instead of go test")
+	all := fs.Bool("all", false, "run all.bash, which also rebuilds the version")
+	timeout := fs.Duration("timeout", 0, "abort the tests after `duration`")
+	return func(args []string) error {
+		// Everything after -- is passed to go test.
+		var goFlags []string
+		for i, arg := range args {
+			if arg == "--" {
+				args, goFlags = args[:i], args[i+1:]
+				break
+			}
+		}
+
+		if len(args) < 1 || (*full || *all) && (len(args) > 1 || len(goFlags) > 0) || *full && *all {
+			return errUsage
+		}
+
+		name, err := g.resolveInstalled(args[0], false)
+		if err != nil {
+			return err
+		}
+		inst, err := g.installInfo(name)
+		if err != nil {
+			return err
+		}
+
+		var command []string
+		switch {
+		case *full:
+			command = []string{"./run.bash", "--no-rebuild"}
+		case *all:
+			command = []string{"./all.bash"}
+		default:
+			packages := args[1:]
+			if len(packages) == 0 {
+				packages = []string{"std"}
+			}
+			command = append(append([]string{"go", "test"}, goFlags...), packages...)
+		}
+
+		// run.bash also runs the tests in the test directory.
+		if (*full || *all) && sparseCheckout(g.versionDir(name)) {
+			err = g.densify(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = g.runTestCommand(name, inst.Env, *timeout, command...)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name, "passed")
+		return nil
+	}
+}
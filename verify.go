@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// manifestEntry records a file of a binary install.
+type manifestEntry struct {
+	Path   string `json:"path"` // slash separated, relative to the version directory
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func (g *groot) manifestPath(name string) string {
+	return filepath.Join(g.paths.state, "manifests", name+".json")
+}
+
+// writeManifest records every file of an installed version so
+// verify can detect missing or modified files.
+func (g *groot) writeManifest(name string) error {
+	dir := g.versionDir(name)
+
+	var manifest []manifestEntry
+	err := filepath.Walk(dir, func(path string, finfo os.FileInfo, err error) error {
+		if err != nil || !finfo.Mode().IsRegular() {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		manifest = append(manifest, manifestEntry{
+			Path:   filepath.ToSlash(rel),
+			Size:   finfo.Size(),
+			SHA256: hash,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeJSONFile(g.manifestPath(name), manifest)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyCheck is a single check run by verify.
+type verifyCheck struct {
+	name string
+	run  func(g *groot, name string, inst installState) error
+}
+
+func verify(g *groot, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	name := args[0]
+
+	if _, err := os.Stat(g.versionDir(name)); err != nil {
+		return err
+	}
+
+	inst, err := g.installInfo(name)
+	if err != nil {
+		return err
+	}
+
+	checks := []verifyCheck{
+		{"executables", verifyExecutables},
+		{"go version", verifyGoVersion},
+		{"GOROOT", verifyGOROOT},
+	}
+	if inst.Experiment != "" {
+		checks = append(checks, verifyCheck{"experiments", verifyExperiments})
+	}
+	if inst.Kind == kindSource && !inst.Detached {
+		checks = append(checks, verifyCheck{"worktree HEAD", verifyHead})
+	} else {
+		checks = append(checks, verifyCheck{"manifest", verifyManifest})
+	}
+
+	failed := 0
+	for _, check := range checks {
+		err := check.run(g, name, inst)
+		if err != nil {
+			failed++
+			fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Printf("[ ok ] %s\n", check.name)
+	}
+
+	if failed > 0 {
+		if inst.Kind == kindSource {
+			fmt.Printf("%s is damaged; remove %s and rebuild it with `groot add %s`.\n", name, g.versionDir(name), inst.Tag)
+		} else {
+			fmt.Printf("%s is damaged; remove %s and download it again with `groot add --binary %s`.\n", name, g.versionDir(name), inst.Tag)
+		}
+		return exitStatus(exitError)
+	}
+	return nil
+}
+
+func exeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// checkExecutable returns an error if path isn't an executable file.
+func checkExecutable(path string) error {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !finfo.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if runtime.GOOS != "windows" && finfo.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+func verifyExecutables(g *groot, name string, _ installState) error {
+	dir := g.versionDir(name)
+	for _, bin := range []string{"go", "gofmt"} {
+		err := checkExecutable(filepath.Join(dir, "bin", exeName(bin)))
+		if err != nil {
+			return err
+		}
+	}
+
+	tools, err := filepath.Glob(filepath.Join(dir, "pkg", "tool", "*", "*"))
+	if err != nil {
+		return err
+	}
+	if len(tools) == 0 {
+		return fmt.Errorf("no tools in %s", filepath.Join(dir, "pkg", "tool"))
+	}
+	for _, tool := range tools {
+		err := checkExecutable(tool)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyGoVersion(g *groot, name string, inst installState) error {
+	reported, err := goVersion(g.versionDir(name))
+	if err != nil {
+		return fmt.Errorf("running go version: %v", err)
+	}
+
+	if _, ok := parseVersion(inst.Tag); ok {
+		if reported != inst.Tag {
+			return fmt.Errorf("reports %s, expected %s", reported, inst.Tag)
+		}
+		return nil
+	}
+
+	// Development builds report "devel <commit> ...".
+	if inst.Kind == kindSource && reported == "devel" {
+		out, err := g.goCmd(name, "version").Output()
+		if err != nil {
+			return err
+		}
+		head := inst.Commit
+		if !inst.Detached {
+			head, err = g.backend().head(g.versionDir(name))
+			if err != nil {
+				return err
+			}
+		}
+		if len(head) >= 10 && !strings.Contains(string(out), head[:10]) {
+			return fmt.Errorf("reports %q, expected commit %s", strings.TrimSpace(string(out)), head[:10])
+		}
+	}
+	return nil
+}
+
+func worktreeHead(dir string) (string, error) {
+	out, err := gitCommand(context.Background(), "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("reading HEAD of %s: %v", dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func verifyHead(g *groot, name string, inst installState) error {
+	head, err := g.backend().head(g.versionDir(name))
+	if err != nil {
+		return err
+	}
+
+	// The ref it was checked out from is what pristine means.
+	ref := inst.ref()
+	if inst.Provenance != nil && inst.Provenance.Ref != "" {
+		ref = inst.Provenance.Ref
+	}
+	want, err := g.backend().revParse(ref)
+	if err != nil {
+		return err
+	}
+
+	if head != want {
+		return fmt.Errorf("HEAD is %s, %s is %s", head, ref, want)
+	}
+	return nil
+}
+
+func verifyManifest(g *groot, name string, inst installState) error {
+	if _, err := os.Stat(g.manifestPath(name)); os.IsNotExist(err) {
+		return fmt.Errorf("no manifest was recorded for %s", name)
+	}
+
+	var manifest []manifestEntry
+	err := readJSONFile(g.manifestPath(name), &manifest)
+	if err != nil {
+		return err
+	}
+
+	dir := g.versionDir(name)
+	var problems []string
+	for _, entry := range manifest {
+		path := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		finfo, err := os.Stat(path)
+		switch {
+		case err != nil && isRegenerable(entry.Path, inst.Tag):
+			// Removed by clean.
+			continue
+		case err != nil:
+			problems = append(problems, entry.Path+" is missing")
+			continue
+		case finfo.Size() != entry.Size:
+			problems = append(problems, entry.Path+" has changed size")
+			continue
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		if hash != entry.SHA256 {
+			problems = append(problems, entry.Path+" has been modified")
+		}
+	}
+
+	if len(problems) > 0 {
+		const max = 10
+		if len(problems) > max {
+			problems = append(problems[:max], fmt.Sprintf("and %d more", len(problems)-max))
+		}
+		return fmt.Errorf("%d of %d files differ:\n  %s", len(problems), len(manifest), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// verifyExperiments checks the experiments reported by go version,
+// such as "go1.22.1 X:rangefunc linux/amd64", match the recorded ones.
+func verifyExperiments(g *groot, name string, inst installState) error {
+	out, err := g.goCmd(name, "version").Output()
+	if err != nil {
+		return err
+	}
+
+	reported := make(map[string]bool)
+	for _, field := range strings.Fields(string(out)) {
+		if strings.HasPrefix(field, "X:") {
+			for _, exp := range strings.Split(field[len("X:"):], ",") {
+				reported[exp] = true
+			}
+		}
+	}
+	for _, exp := range strings.Split(inst.Experiment, ",") {
+		if !reported[exp] {
+			return fmt.Errorf("built with GOEXPERIMENT=%s but go version reports %q", inst.Experiment, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+func verifyGOROOT(g *groot, name string, _ installState) error {
+	return g.checkGOROOT(name)
+}
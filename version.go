@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed Go release tag, such as go1.9.2 or go1.21rc1.
+type version struct {
+	major, minor, patch int
+	pre                 string // "beta", "rc", or empty for stable releases
+	preNum              int
+}
+
+var versionRE = regexp.MustCompile(`^go(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:(beta|rc)(\d+))?$`)
+
+// parseVersion parses a Go release tag. ok is false if tag
+// isn't a release tag.
+func parseVersion(tag string) (v version, ok bool) {
+	m := versionRE.FindStringSubmatch(tag)
+	if m == nil {
+		return v, false
+	}
+
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return version{
+		major:  atoi(m[1]),
+		minor:  atoi(m[2]),
+		patch:  atoi(m[3]),
+		pre:    m[4],
+		preNum: atoi(m[5]),
+	}, true
+}
+
+func (v version) stable() bool { return v.pre == "" }
+
+// minorLine returns the release line of v, e.g. "go1.21".
+func (v version) minorLine() string {
+	return fmt.Sprintf("go%d.%d", v.major, v.minor)
+}
+
+// less reports whether v precedes w. Prereleases precede
+// the release they lead up to, betas precede rcs.
+func (v version) less(w version) bool {
+	switch {
+	case v.major != w.major:
+		return v.major < w.major
+	case v.minor != w.minor:
+		return v.minor < w.minor
+	case v.patch != w.patch:
+		return v.patch < w.patch
+	case v.pre != w.pre:
+		// "" (stable) sorts last, "beta" < "rc".
+		if v.pre == "" || w.pre == "" {
+			return w.pre == ""
+		}
+		return v.pre < w.pre
+	}
+	return v.preNum < w.preNum
+}
+
+// String returns the tag of v.
+func (v version) String() string {
+	s := fmt.Sprintf("go%d", v.major)
+	if v.minor != 0 || v.patch != 0 || v.major != 1 {
+		s += fmt.Sprintf(".%d", v.minor)
+	}
+	if v.patch != 0 {
+		s += fmt.Sprintf(".%d", v.patch)
+	}
+	if v.pre != "" {
+		s += fmt.Sprintf("%s%d", v.pre, v.preNum)
+	}
+	return s
+}
+
+// bootstrapMinimum returns the oldest Go release able to bootstrap
+// target. ok is false if target doesn't use a Go bootstrap.
+func bootstrapMinimum(target version) (v version, ok bool) {
+	switch {
+	case target.major != 1 || target.minor < 5:
+		// Built with a C compiler.
+		return v, false
+	case target.minor < 20:
+		return version{major: 1, minor: 4}, true
+	case target.minor < 22:
+		return version{major: 1, minor: 17, patch: 13}, true
+	}
+	// From go1.22, the release from two minors earlier, as of its
+	// sixth patch.
+	return version{major: 1, minor: target.minor - target.minor%2 - 2, patch: 6}, true
+}
+
+// sortTags sorts release tags in ascending version order.
+// Tags that aren't release tags sort first, lexically.
+func sortTags(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		vi, iok := parseVersion(tags[i])
+		vj, jok := parseVersion(tags[j])
+		switch {
+		case iok && jok:
+			return vi.less(vj)
+		case !iok && !jok:
+			return tags[i] < tags[j]
+		}
+		return jok
+	})
+}
+
+// latestPerMinor returns the newest stable tag of each minor
+// release line, in ascending order.
+func latestPerMinor(tags []string) []string {
+	latest := make(map[string]version)
+	names := make(map[string]string)
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || !v.stable() {
+			continue
+		}
+		line := v.minorLine()
+		if cur, ok := latest[line]; !ok || cur.less(v) {
+			latest[line] = v
+			names[line] = tag
+		}
+	}
+
+	var result []string
+	for _, tag := range names {
+		result = append(result, tag)
+	}
+	sortTags(result)
+	return result
+}
+
+// latestPrerelease returns the newest beta or release candidate among
+// tags of the newest release still in progress, that is, whose final
+// release isn't among tags.
+func latestPrerelease(tags []string) (string, error) {
+	released := make(map[string]bool)
+	var newest version
+	for _, tag := range tags {
+		if v, ok := parseVersion(tag); ok && v.stable() {
+			released[v.minorLine()] = true
+			if newest.less(v) {
+				newest = v
+			}
+		}
+	}
+
+	var pre []string
+	for _, tag := range tags {
+		if v, ok := parseVersion(tag); ok && !v.stable() && !released[v.minorLine()] {
+			pre = append(pre, tag)
+		}
+	}
+	if len(pre) == 0 {
+		return "", fmt.Errorf("no release is in progress; the newest release is %s", newest)
+	}
+	sortTags(pre)
+	return pre[len(pre)-1], nil
+}
+
+// stableTags returns the tags of tags that are stable releases.
+func stableTags(tags []string) []string {
+	var stable []string
+	for _, tag := range tags {
+		if v, ok := parseVersion(tag); ok && v.stable() {
+			stable = append(stable, tag)
+		}
+	}
+	return stable
+}
+
+// normalizeTag returns the release tag spec refers to in any of the
+// forms in use, such as "1.22.1", "v1.22.1", or "go1.22.1" with
+// surrounding whitespace, or the trimmed spec unchanged if it isn't a
+// version, such as tip or a custom name.
+func normalizeTag(spec string) string {
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "\ufeff"))
+	for _, prefix := range []string{"go", "v", ""} {
+		if strings.HasPrefix(spec, prefix) {
+			if tag := "go" + strings.TrimPrefix(spec, prefix); versionRE.MatchString(tag) {
+				return tag
+			}
+		}
+	}
+	return spec
+}
+
+// resolveTag returns the tag of tags that spec refers to: spec itself
+// if it's one of them, so custom names are never normalized, or else
+// the release spec names in any form normalizeTag accepts, or the
+// newest release spec is a prefix of, so "1.21" or "go1.21" resolve
+// to the newest go1.21.x. A full version such as "1.20.0" matches the
+// tag go1.20, which has no ".0". A prerelease spec names in full
+// always matches; otherwise prereleases are only considered if
+// prerelease is set.
+func resolveTag(spec string, tags []string, prerelease bool) (string, error) {
+	for _, tag := range tags {
+		if tag == spec {
+			return tag, nil
+		}
+	}
+
+	want := normalizeTag(spec)
+	for _, tag := range tags {
+		if tag == want {
+			return tag, nil
+		}
+	}
+	if wv, ok := parseVersion(want); ok && strings.Count(want, ".") == 2 {
+		for _, tag := range tags {
+			if v, ok := parseVersion(tag); ok && v == wv {
+				return tag, nil
+			}
+		}
+	}
+
+	var matches, unstable []string
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || tag != want && !strings.HasPrefix(tag, want+".") && !strings.HasPrefix(tag, want+"beta") && !strings.HasPrefix(tag, want+"rc") {
+			continue
+		}
+		if !v.stable() && !prerelease {
+			unstable = append(unstable, tag)
+			continue
+		}
+		matches = append(matches, tag)
+	}
+
+	if len(matches) == 0 {
+		if len(unstable) > 0 {
+			sortTags(unstable)
+			return "", fmt.Errorf("no stable version matches %q; use --prerelease to consider %s", spec, strings.Join(unstable, ", "))
+		}
+		return "", fmt.Errorf("no version matches %q", spec)
+	}
+	sortTags(matches)
+	return matches[len(matches)-1], nil
+}
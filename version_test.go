@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"go1.22.1", "go1.22.1"},
+		{"1.22.1", "go1.22.1"},
+		{"v1.22.1", "go1.22.1"},
+		{" go1.22.1\r\n", "go1.22.1"},
+		{"\ufeffgo1.22.1", "go1.22.1"},
+		{"\ufeff1.22.1\n", "go1.22.1"},
+		{"1.21", "go1.21"},
+		{"1.21rc2", "go1.21rc2"},
+		{"v1.22beta1", "go1.22beta1"},
+		{"1.20.0", "go1.20.0"},
+
+		// Custom names and other non-versions stay as they are.
+		{"tip", "tip"},
+		{"tip-2024-05-03", "tip-2024-05-03"},
+		{"mygo", "mygo"},
+		{"go1.22.1-race", "go1.22.1-race"},
+		{"go1.13-exp-rangefunc", "go1.13-exp-rangefunc"},
+		{"vendor", "vendor"},
+		{"1.22.x", "1.22.x"},
+		{"  mygo  ", "mygo"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTag(tt.spec); got != tt.want {
+			t.Errorf("normalizeTag(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTag(t *testing.T) {
+	tags := []string{
+		"go1.20", "go1.20.1", "go1.21rc2", "go1.21.0", "go1.21.1",
+		"go1.22.1", "go1.23rc1", "mygo", "go1.22.1-race",
+	}
+	tests := []struct {
+		spec       string
+		prerelease bool
+		want       string // empty if an error containing err is expected
+		err        string
+	}{
+		{spec: "go1.22.1", want: "go1.22.1"},
+		{spec: "1.22.1", want: "go1.22.1"},
+		{spec: "v1.22.1", want: "go1.22.1"},
+		{spec: " go1.22.1\r\n", want: "go1.22.1"},
+		{spec: "\ufeff1.22.1", want: "go1.22.1"},
+		{spec: "1.20.0", want: "go1.20"},
+		{spec: "go1.20.0", want: "go1.20"},
+		// go1.20 is a tag itself, which wins over its patches.
+		{spec: "1.20", want: "go1.20"},
+		{spec: "1.21", want: "go1.21.1"},
+		{spec: "go1.21", want: "go1.21.1"},
+		{spec: "1.21rc2", want: "go1.21rc2"},
+		{spec: "1.23", err: "use --prerelease to consider go1.23rc1"},
+		{spec: "1.23", prerelease: true, want: "go1.23rc1"},
+		{spec: "1.19", err: `no version matches "1.19"`},
+
+		// Custom names match only exactly.
+		{spec: "mygo", want: "mygo"},
+		{spec: "go1.22.1-race", want: "go1.22.1-race"},
+		{spec: "1.22.1-race", err: `no version matches "1.22.1-race"`},
+	}
+	for _, tt := range tests {
+		got, err := resolveTag(tt.spec, tags, tt.prerelease)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveTag(%q, %v) = %q, %v; want error containing %q", tt.spec, tt.prerelease, got, err, tt.err)
+			}
+		case err != nil || got != tt.want:
+			t.Errorf("resolveTag(%q, %v) = %q, %v; want %q", tt.spec, tt.prerelease, got, err, tt.want)
+		}
+	}
+}
+
+func TestGoVersionSpec(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{"go1.22.1\n", "go1.22.1"},
+		{"go1.22.1", "go1.22.1"},
+		{" go1.22.1\r\n", "go1.22.1"},
+		{"\ufeffgo1.22.1\n", "go1.22.1"},
+		{"1.22.1\r\n\r\n", "1.22.1"},
+		{"# pinned for CI\n\n  v1.22.1  \n", "v1.22.1"},
+		{"\n\ngo1.21.0\ngo1.20\n", "go1.21.0"},
+		{"mygo\n", "mygo"},
+		{"# nothing here\n", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := goVersionSpec(tt.data); got != tt.want {
+			t.Errorf("goVersionSpec(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
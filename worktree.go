@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// worktreeLockReason marks the worktrees groot manages, so git
+// maintenance in the bare repo doesn't prune them.
+const worktreeLockReason = "managed by groot"
+
+// worktree is a worktree registered in the bare repo.
+type worktree struct {
+	path   string
+	branch string // without refs/heads/
+	locked bool
+}
+
+// worktrees returns the worktrees registered in the bare repo,
+// excluding the bare repo itself.
+func (g *groot) worktrees() ([]worktree, error) {
+	// Other backends check out plain directories.
+	if _, ok := g.backend().(*execGit); !ok {
+		return nil, nil
+	}
+
+	out, err := g.gitOutput("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	var wts []worktree
+	var cur *worktree
+	for _, line := range strings.Split(out, "\n") {
+		key, value := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			key, value = line[:i], line[i+1:]
+		}
+		switch key {
+		case "worktree":
+			wts = append(wts, worktree{path: value})
+			cur = &wts[len(wts)-1]
+		case "branch":
+			cur.branch = strings.TrimPrefix(value, "refs/heads/")
+		case "locked":
+			cur.locked = true
+		case "bare":
+			wts = wts[:len(wts)-1]
+			cur = nil
+		}
+	}
+	return wts, nil
+}
+
+func (g *groot) lockWorktree(dir string) error {
+	return g.git("worktree", "lock", "--reason", worktreeLockReason, dir)
+}
+
+// unlockWorktree unlocks the worktree at dir if it's locked.
+func (g *groot) unlockWorktree(dir string) error {
+	wts, err := g.worktrees()
+	if err != nil {
+		return err
+	}
+	for _, wt := range wts {
+		if wt.locked && samePath(wt.path, dir) {
+			return g.git("worktree", "unlock", wt.path)
+		}
+	}
+	return nil
+}
+
+// detachWorktree converts the worktree of the built version name to
+// a plain directory, removing its git metadata and branch, and returns
+// the commit it was built from.
+func (g *groot) detachWorktree(name string) (string, error) {
+	dir := g.versionDir(name)
+	commit, err := g.backend().head(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, marker := range []string{".git", goGitHead} {
+		err = os.RemoveAll(filepath.Join(dir, marker))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = g.backend().removeWorktree(dir, "groot."+name)
+	if err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// drift is an inconsistency between groot's versions directory, the
+// worktrees registered in the bare repo, the state file, and the
+// active link.
+type drift struct {
+	name    string
+	problem string
+	fix     func(g *groot) error
+}
+
+// findDrift returns the inconsistencies that prune reconciles.
+func (g *groot) findDrift() ([]drift, error) {
+	var drifts []drift
+
+	wts, err := g.worktrees()
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool)
+	for _, wt := range wts {
+		wt := wt
+		name := strings.TrimPrefix(wt.branch, "groot.")
+		if name == wt.branch || !samePath(filepath.Dir(wt.path), g.paths.base) {
+			// Not one of ours.
+			continue
+		}
+		registered[name] = true
+
+		if _, err := os.Stat(wt.path); os.IsNotExist(err) {
+			drifts = append(drifts, drift{name, "worktree is registered but " + wt.path + " is missing", func(g *groot) error {
+				return g.forgetWorktree(name, wt)
+			}})
+			continue
+		}
+		if !wt.locked {
+			drifts = append(drifts, drift{name, "worktree isn't locked against pruning", func(g *groot) error {
+				return g.lockWorktree(wt.path)
+			}})
+		}
+	}
+
+	names, err := g.installed()
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]bool)
+	for _, name := range names {
+		installed[name] = true
+		dir := g.versionDir(name)
+		finfo, err := os.Stat(filepath.Join(dir, ".git"))
+		if err != nil || finfo.IsDir() || registered[name] {
+			continue
+		}
+		drifts = append(drifts, drift{name, dir + " isn't a registered worktree", func(g *groot) error {
+			err := g.git("worktree", "repair", dir)
+			if err != nil {
+				return fmt.Errorf("repairing worktree: %v; remove %s and add it again", err, dir)
+			}
+			return g.lockWorktree(dir)
+		}})
+	}
+
+	s, err := g.loadState()
+	if err != nil {
+		return nil, err
+	}
+	for name := range s.Installs {
+		if installed[name] || isSnapshot(name) && g.exists(name) {
+			continue
+		}
+		name := name
+		drifts = append(drifts, drift{name, "recorded as installed but " + g.versionDir(name) + " is missing", func(g *groot) error {
+			return g.updateState(func(s *state) error {
+				delete(s.Installs, name)
+				return nil
+			})
+		}})
+	}
+
+	if name, target := g.danglingActive(); target != "" {
+		drifts = append(drifts, drift{name, "active version is missing", func(g *groot) error {
+			infoln("Deactivating", name)
+			return g.deactivate()
+		}})
+	}
+	return drifts, nil
+}
+
+// forgetWorktree removes what's left of the version name after its
+// worktree directory was deleted.
+func (g *groot) forgetWorktree(name string, wt worktree) error {
+	if wt.locked {
+		err := g.git("worktree", "unlock", wt.path)
+		if err != nil {
+			return err
+		}
+	}
+	err := g.git("worktree", "prune")
+	if err != nil {
+		return err
+	}
+	err = g.git("branch", "-D", wt.branch)
+	if err != nil {
+		return err
+	}
+	return g.updateState(func(s *state) error {
+		delete(s.Installs, name)
+		return nil
+	})
+}
+
+func prune(g *groot, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	drifts, err := g.findDrift()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, d := range drifts {
+		fmt.Printf("%s: %s\n", d.name, d.problem)
+		err := d.fix(g)
+		if err != nil {
+			failed++
+			fmt.Printf("  %v\n", err)
+		}
+	}
+	if failed > 0 {
+		return exitStatus(exitError)
+	}
+	return nil
+}
+
+func checkWorktrees(g *groot) (string, error) {
+	if !g.initialized() {
+		return "not initialized", nil
+	}
+	if !g.cloned() {
+		return "not cloned (binary-only)", nil
+	}
+	drifts, err := g.findDrift()
+	if err != nil {
+		return "", err
+	}
+	if len(drifts) > 0 {
+		var problems []string
+		for _, d := range drifts {
+			problems = append(problems, d.name+": "+d.problem)
+		}
+		return "", fmt.Errorf("%s; run `groot prune` to reconcile", strings.Join(problems, "; "))
+	}
+	return "consistent", nil
+}